@@ -3,13 +3,18 @@ package main
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"log/slog"
 	"net/http"
+	"net/url"
 	"os"
 	"os/exec"
 	"path"
+	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
 	"text/tabwriter"
@@ -64,14 +69,14 @@ func definitions(cfg config.Config, client *http.Client) error {
 	return nil
 }
 
-func get(cfg config.Config, client *http.Client, typ string, args ...string) error {
+func get(cfg config.Config, client *http.Client, query url.Values, typ string, args ...string) error {
 	var name *string
 	if len(args) == 1 {
 		n := args[0]
 		name = &n
 	}
 
-	body, err := getResourceBody(cfg, client, typ, name)
+	body, err := getResourceBody(cfg, client, typ, name, query)
 	if err != nil {
 		return err
 	}
@@ -121,7 +126,53 @@ func get(cfg config.Config, client *http.Client, typ string, args ...string) err
 	return nil
 }
 
-func getResourceBody(cfg config.Config, client *http.Client, typ string, name *string) (io.ReadCloser, error) {
+func watch(cfg config.Config, client *http.Client, query url.Values, typ string, args ...string) error {
+	query.Set("watch", "true")
+
+	body, err := getResourceBody(cfg, client, typ, nil, query)
+	if err != nil {
+		return err
+	}
+
+	defer body.Close()
+
+	enc, err := encoder(cfg, func(e *api.Event) [][]string {
+		return [][]string{{string(e.Type), e.Resource.Metadata.Namespace, e.Resource.Metadata.Name}}
+	},
+		"EVENT", "NAMESPACE", "NAME",
+	)
+	if err != nil {
+		return err
+	}
+
+	dec := encoding.NewJSONDecoder[api.Event](body)
+	for {
+		event, err := dec.Decode()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return enc.Flush()
+			}
+
+			return fmt.Errorf("decoding event: %w", err)
+		}
+
+		// filter by name client side
+		if len(args) > 0 && !slices.Contains(args, event.Resource.Metadata.Name) {
+			continue
+		}
+
+		if err := enc.Encode(event); err != nil {
+			return err
+		}
+
+		// flush each event as they arrive
+		if err := enc.Flush(); err != nil {
+			return err
+		}
+	}
+}
+
+func getResourceBody(cfg config.Config, client *http.Client, typ string, name *string, query url.Values) (io.ReadCloser, error) {
 	group, version, kind, err := getGVK(cfg, client, typ)
 	if err != nil {
 		return nil, fmt.Errorf("get: %w", err)
@@ -139,6 +190,10 @@ func getResourceBody(cfg config.Config, client *http.Client, typ string, name *s
 		endpoint += "/" + *name
 	}
 
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+
 	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
 	if err != nil {
 		return nil, err
@@ -156,8 +211,21 @@ func getResourceBody(cfg config.Config, client *http.Client, typ string, name *s
 	return resp.Body, nil
 }
 
-func edit(cfg config.Config, client *http.Client, typ, name string) (err error) {
-	body, err := getResourceBody(cfg, client, typ, &name)
+// edit opens the named resource in the configured editor and applies the result.
+// When a proposal is supplied, the resource is read from the head of the proposal
+// and the result amends it.
+func edit(cfg config.Config, client *http.Client, proposal, typ, name string) (err error) {
+	var query url.Values
+	if proposal != "" {
+		head, err := proposalHead(cfg, client, proposal)
+		if err != nil {
+			return err
+		}
+
+		query = url.Values{"revision": []string{head}}
+	}
+
+	body, err := getResourceBody(cfg, client, typ, &name, query)
 	if err != nil {
 		return err
 	}
@@ -202,24 +270,130 @@ func edit(cfg config.Config, client *http.Client, typ, name string) (err error)
 		return err
 	}
 
-	fi, err := os.Open(f.Name())
+	edited, err := readResourcesFile(f.Name())
 	if err != nil {
 		return err
 	}
 
-	return apply(cfg, client, fi)
+	query = nil
+	if proposal != "" {
+		query = url.Values{"proposal": []string{proposal}}
+	}
+
+	return apply(cfg, client, edited, query)
+}
+
+// readResources decodes all the resources found at the provided source.
+// The source is either - for STDIN, a file or a directory.
+// Files and STDIN may contain a stream of multiple resources.
+// Directories are walked recursively for files with a .json extension.
+func readResources(source string) ([]*core.Resource, error) {
+	if source == "-" {
+		return decodeResources(os.Stdin)
+	}
+
+	info, err := os.Stat(source)
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.IsDir() {
+		return readResourcesFile(source)
+	}
+
+	var resources []*core.Resource
+	if err := filepath.WalkDir(source, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() || filepath.Ext(p) != ".json" {
+			return nil
+		}
+
+		found, err := readResourcesFile(p)
+		if err != nil {
+			return err
+		}
+
+		resources = append(resources, found...)
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return resources, nil
+}
+
+func readResourcesFile(name string) ([]*core.Resource, error) {
+	fi, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	defer fi.Close()
+
+	resources, err := decodeResources(fi)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	return resources, nil
 }
 
-func apply(cfg config.Config, client *http.Client, rd io.Reader) (err error) {
-	buf := &bytes.Buffer{}
+func decodeResources(rd io.Reader) ([]*core.Resource, error) {
+	return encoding.DecodeAll[core.Resource](encoding.NewJSONDecoder[core.Resource](rd))
+}
+
+// apply puts the provided resources.
+// A single resource is put directly while many resources are
+// applied together as a batch which results in a single proposal.
+func apply(cfg config.Config, client *http.Client, resources []*core.Resource, query url.Values) error {
+	switch len(resources) {
+	case 0:
+		return errors.New("no resources found to apply")
+	case 1:
+		return put(cfg, client, resources[0], query)
+	}
+
+	defs, err := getDefintionsByAPIVersionKind(cfg, client)
+	if err != nil {
+		return err
+	}
+
+	var batch api.Batch
+	for _, resource := range resources {
+		gvk := path.Join(resource.APIVersion, resource.Kind)
+		if _, ok := defs[gvk]; !ok {
+			return fmt.Errorf("unexpected resource kind: %q", gvk)
+		}
+
+		batch.Operations = append(batch.Operations, api.Operation{
+			Type:     api.OperationTypePut,
+			Resource: resource,
+		})
+	}
+
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return err
+	}
+
+	endpoint := cfg.Address() + "/apis/batch"
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
 
-	resource, err := encoding.
-		NewJSONDecoder[core.Resource](io.TeeReader(rd, buf)).
-		Decode()
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
 	if err != nil {
 		return err
 	}
 
+	return propose(cfg, client, req)
+}
+
+func put(cfg config.Config, client *http.Client, resource *core.Resource, query url.Values) error {
 	defs, err := getDefintionsByAPIVersionKind(cfg, client)
 	if err != nil {
 		return err
@@ -231,6 +405,11 @@ func apply(cfg config.Config, client *http.Client, rd io.Reader) (err error) {
 		return fmt.Errorf("unexpected resource kind: %q", gvk)
 	}
 
+	body, err := json.Marshal(resource)
+	if err != nil {
+		return err
+	}
+
 	group, version, _ := strings.Cut(resource.APIVersion, "/")
 	endpoint := fmt.Sprintf("%s/apis/%s/%s/namespaces/%s/%s/%s",
 		cfg.Address(),
@@ -241,7 +420,11 @@ func apply(cfg config.Config, client *http.Client, rd io.Reader) (err error) {
 		resource.Metadata.Name,
 	)
 
-	req, err := http.NewRequest(http.MethodPut, endpoint, buf)
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequest(http.MethodPut, endpoint, bytes.NewReader(body))
 	if err != nil {
 		return err
 	}
@@ -249,7 +432,7 @@ func apply(cfg config.Config, client *http.Client, rd io.Reader) (err error) {
 	return propose(cfg, client, req)
 }
 
-func del(cfg config.Config, client *http.Client, typ, name string) error {
+func del(cfg config.Config, client *http.Client, query url.Values, typ, name string) error {
 	group, version, kind, err := getGVK(cfg, client, typ)
 	if err != nil {
 		return fmt.Errorf("get: %w", err)
@@ -264,6 +447,10 @@ func del(cfg config.Config, client *http.Client, typ, name string) error {
 		name,
 	)
 
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+
 	req, err := http.NewRequest(http.MethodDelete, endpoint, nil)
 	if err != nil {
 		return err
@@ -284,6 +471,13 @@ func propose(cfg config.Config, client *http.Client, req *http.Request) error {
 		slog.Debug("No change was generated by proposal")
 
 		return nil
+	case http.StatusConflict:
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return fmt.Errorf("reading conflict response body: %w", err)
+		}
+
+		return fmt.Errorf("resource has changed since it was read (fetch it again and retry): %s", strings.TrimSpace(string(body)))
 	default:
 		body, err := io.ReadAll(resp.Body)
 		if err != nil {
@@ -305,9 +499,20 @@ func propose(cfg config.Config, client *http.Client, req *http.Request) error {
 		return err
 	}
 
+	// dry-run results are printed as the diff of the change
+	if req.URL.Query().Get("dryRun") == "true" {
+		_, err := fmt.Fprint(os.Stdout, result.Diff)
+		return err
+	}
+
 	enc, err := encoder(cfg, func(r *api.Result) [][]string {
-		return [][]string{{r.ID.String(), r.Proposal.Source, r.Proposal.URL}}
-	}, "ID", "SOURCE", "URL")
+		// direct results are committed without a proposal
+		if r.Proposal == nil {
+			return [][]string{{r.ID.String(), "", "", r.Commit}}
+		}
+
+		return [][]string{{r.ID.String(), r.Proposal.Source, r.Proposal.URL, r.Commit}}
+	}, "ID", "SOURCE", "URL", "COMMIT")
 	if err != nil {
 		return err
 	}
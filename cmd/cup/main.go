@@ -6,8 +6,10 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
+	"net/url"
 	"os"
 	"path"
+	"strconv"
 
 	"github.com/urfave/cli/v2"
 	"go.flipt.io/cup/cmd/cup/config"
@@ -75,14 +77,48 @@ func main() {
 				Name:     "get",
 				Category: "resource",
 				Usage:    "Get one or more resources",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:    "watch",
+						Aliases: []string{"w"},
+						Usage:   "Watch for changes to the requested resource kind",
+					},
+					&cli.StringFlag{
+						Name:  "revision",
+						Usage: "Branch, tag or commit SHA to read resources from",
+					},
+					&cli.StringFlag{
+						Name:    "selector",
+						Aliases: []string{"l"},
+						Usage:   "Label selector to filter on (e.g. -l key1=value1,key2 in (a,b))",
+					},
+				},
 				Action: func(ctx *cli.Context) error {
 					cfg, err := config.Parse(ctx)
 					if err != nil {
 						return err
 					}
 
+					query := url.Values{}
+					if rev := ctx.String("revision"); rev != "" {
+						query.Set("revision", rev)
+					}
+
+					if selector := ctx.String("selector"); selector != "" {
+						query.Set("labelSelector", selector)
+					}
+
+					if ctx.Bool("watch") {
+						return watch(cfg,
+							http.DefaultClient,
+							query,
+							ctx.Args().First(),
+							ctx.Args().Tail()...)
+					}
+
 					return get(cfg,
 						http.DefaultClient,
+						query,
 						ctx.Args().First(),
 						ctx.Args().Tail()...)
 				},
@@ -90,14 +126,17 @@ func main() {
 			{
 				Name:     "apply",
 				Category: "resource",
-				Usage:    "Put a resource from file on stdin",
+				Usage:    "Put resources from a file, directory or stdin",
 				Flags: []cli.Flag{
 					&cli.StringFlag{
 						Name:        "f",
 						Value:       "-",
-						Usage:       "Path to the resource being applied",
+						Usage:       "Path to the file or directory of resources being applied",
 						DefaultText: "(- STDIN)",
 					},
+					dryRunFlag(),
+					proposalFlag(),
+					autoMergeFlag(),
 				},
 				Action: func(ctx *cli.Context) error {
 					cfg, err := config.Parse(ctx)
@@ -105,17 +144,15 @@ func main() {
 						return err
 					}
 
-					rd := os.Stdin
-					if source := ctx.String("f"); source != "-" {
-						rd, err = os.Open(source)
-						if err != nil {
-							return err
-						}
+					resources, err := readResources(ctx.String("f"))
+					if err != nil {
+						return err
 					}
 
 					return apply(cfg,
 						http.DefaultClient,
-						rd,
+						resources,
+						updateQuery(ctx),
 					)
 				},
 			},
@@ -124,6 +161,9 @@ func main() {
 				Category:  "resource",
 				Usage:     "Edit a resource",
 				ArgsUsage: "<type> <name>",
+				Flags: []cli.Flag{
+					proposalFlag(),
+				},
 				Action: func(ctx *cli.Context) error {
 					cfg, err := config.Parse(ctx)
 					if err != nil {
@@ -136,6 +176,7 @@ func main() {
 
 					return edit(cfg,
 						http.DefaultClient,
+						ctx.String("proposal"),
 						ctx.Args().Get(0),
 						ctx.Args().Get(1))
 				},
@@ -145,6 +186,11 @@ func main() {
 				Category:  "resource",
 				Usage:     "Delete a resource",
 				ArgsUsage: "<type> <name>",
+				Flags: []cli.Flag{
+					dryRunFlag(),
+					proposalFlag(),
+					autoMergeFlag(),
+				},
 				Action: func(ctx *cli.Context) error {
 					cfg, err := config.Parse(ctx)
 					if err != nil {
@@ -157,10 +203,12 @@ func main() {
 
 					return del(cfg,
 						http.DefaultClient,
+						updateQuery(ctx),
 						ctx.Args().Get(0),
 						ctx.Args().Get(1))
 				},
 			},
+			proposalsCommand(),
 		},
 	}
 
@@ -170,6 +218,46 @@ func main() {
 	}
 }
 
+func dryRunFlag() cli.Flag {
+	return &cli.BoolFlag{
+		Name:  "dry-run",
+		Usage: "Print the diff of the change without proposing it",
+	}
+}
+
+func autoMergeFlag() cli.Flag {
+	return &cli.BoolFlag{
+		Name:  "auto-merge",
+		Usage: "Merge the proposal once its required checks pass",
+	}
+}
+
+func proposalFlag() cli.Flag {
+	return &cli.StringFlag{
+		Name:  "proposal",
+		Usage: "ID of an open proposal to amend instead of opening a new one",
+	}
+}
+
+// updateQuery returns the query parameters which request a dry-run, the proposal
+// to amend and its automatic merge when the respective flags have been supplied.
+func updateQuery(ctx *cli.Context) url.Values {
+	query := url.Values{}
+	if ctx.Bool("dry-run") {
+		query.Set("dryRun", "true")
+	}
+
+	if proposal := ctx.String("proposal"); proposal != "" {
+		query.Set("proposal", proposal)
+	}
+
+	if ctx.IsSet("auto-merge") {
+		query.Set("autoMerge", strconv.FormatBool(ctx.Bool("auto-merge")))
+	}
+
+	return query
+}
+
 func ensureConfigDir() (string, error) {
 	dir, err := os.UserConfigDir()
 	if err != nil {
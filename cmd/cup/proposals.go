@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+	"go.flipt.io/cup/cmd/cup/config"
+	"go.flipt.io/cup/pkg/api"
+	"go.flipt.io/cup/pkg/encoding"
+)
+
+func proposalsCommand() *cli.Command {
+	return &cli.Command{
+		Name:     "proposals",
+		Aliases:  []string{"props"},
+		Category: "proposal",
+		Usage:    "Manage the proposals made for changes to resources",
+		Subcommands: []*cli.Command{
+			{
+				Name:  "list",
+				Usage: "List the open proposals",
+				Action: func(ctx *cli.Context) error {
+					cfg, err := config.Parse(ctx)
+					if err != nil {
+						return err
+					}
+
+					return listProposals(cfg, http.DefaultClient)
+				},
+			},
+			{
+				Name:      "get",
+				Usage:     "Get a proposal",
+				ArgsUsage: "<id>",
+				Action: proposalAction(func(cfg config.Config, id string) error {
+					return getProposal(cfg, http.DefaultClient, id)
+				}),
+			},
+			{
+				Name:      "merge",
+				Usage:     "Merge a proposal",
+				ArgsUsage: "<id>",
+				Action: proposalAction(func(cfg config.Config, id string) error {
+					return transitionProposal(cfg, http.DefaultClient, id, "merge")
+				}),
+			},
+			{
+				Name:      "close",
+				Usage:     "Close a proposal without merging",
+				ArgsUsage: "<id>",
+				Action: proposalAction(func(cfg config.Config, id string) error {
+					return transitionProposal(cfg, http.DefaultClient, id, "close")
+				}),
+			},
+		},
+	}
+}
+
+func proposalAction(fn func(config.Config, string) error) cli.ActionFunc {
+	return func(ctx *cli.Context) error {
+		cfg, err := config.Parse(ctx)
+		if err != nil {
+			return err
+		}
+
+		if l := ctx.Args().Len(); l != 1 {
+			return fmt.Errorf("expected 1 argument, found %d", l)
+		}
+
+		return fn(cfg, ctx.Args().First())
+	}
+}
+
+func listProposals(cfg config.Config, client *http.Client) error {
+	return printProposals(cfg, client, cfg.Address()+"/proposals")
+}
+
+func getProposal(cfg config.Config, client *http.Client, id string) error {
+	return printProposals(cfg, client, cfg.Address()+"/proposals/"+id)
+}
+
+func printProposals(cfg config.Config, client *http.Client, endpoint string) error {
+	resp, err := client.Get(endpoint)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+	}()
+
+	if err := checkProposalResponse(resp, http.StatusOK); err != nil {
+		return err
+	}
+
+	proposals, err := encoding.DecodeAll[api.Proposal](encoding.NewJSONDecoder[api.Proposal](resp.Body))
+	if err != nil {
+		return fmt.Errorf("decoding proposals: %w", err)
+	}
+
+	enc, err := encoder(cfg, func(p *api.Proposal) [][]string {
+		status := string(p.Status)
+		if len(p.Conflicts) > 0 {
+			status += " (conflicted)"
+		}
+
+		if p.AutoMerge != nil && p.Status == api.ProposalStatusOpen {
+			status += fmt.Sprintf(" (auto-merge %s)", p.AutoMerge.State)
+		}
+
+		return [][]string{{p.ID.String(), status, p.URL, strings.Join(p.Resources, ",")}}
+	}, "ID", "STATUS", "URL", "RESOURCES")
+	if err != nil {
+		return err
+	}
+
+	defer enc.Flush()
+
+	for _, proposal := range proposals {
+		if err := enc.Encode(proposal); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// proposalHead returns the branch containing the changes for the identified proposal.
+func proposalHead(cfg config.Config, client *http.Client, id string) (string, error) {
+	resp, err := client.Get(cfg.Address() + "/proposals/" + id)
+	if err != nil {
+		return "", err
+	}
+
+	defer func() {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+	}()
+
+	if err := checkProposalResponse(resp, http.StatusOK); err != nil {
+		return "", err
+	}
+
+	proposal, err := encoding.NewJSONDecoder[api.Proposal](resp.Body).Decode()
+	if err != nil {
+		return "", fmt.Errorf("decoding proposal: %w", err)
+	}
+
+	if proposal.Status != api.ProposalStatusOpen {
+		return "", fmt.Errorf("proposal %s is %s", id, proposal.Status)
+	}
+
+	return proposal.Head, nil
+}
+
+func transitionProposal(cfg config.Config, client *http.Client, id, action string) error {
+	resp, err := client.Post(fmt.Sprintf("%s/proposals/%s/%s", cfg.Address(), id, action), "", nil)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+	}()
+
+	return checkProposalResponse(resp, http.StatusNoContent)
+}
+
+func checkProposalResponse(resp *http.Response, expected int) error {
+	if resp.StatusCode == expected {
+		return nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("reading unexpected response body: %w", err)
+	}
+
+	return fmt.Errorf("unexpected status: %q: %s", resp.Status, strings.TrimSpace(string(body)))
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"time"
+
+	"go.flipt.io/cup/pkg/api"
+	apiconfig "go.flipt.io/cup/pkg/api/config"
+	"go.flipt.io/cup/pkg/config"
+)
+
+// resources loads the definitions, controllers and bindings served by the API,
+// either from the resources directory or from a path within a source.
+type resources struct {
+	cfg *config.Config
+	// src is the source the resources are loaded from at rev.
+	// They are loaded from the local filesystem when nil.
+	src api.Source
+	rev string
+	// digest identifies the contents of the resources last loaded.
+	digest string
+}
+
+// load parses the resources when they have changed since the last call to load.
+// It returns a nil configuration when they are unchanged.
+func (r *resources) load(ctx context.Context) (apiConfig *api.Configuration, err error) {
+	read := func(dir fs.FS) error {
+		digest, err := apiconfig.Digest(dir)
+		if err != nil || digest == r.digest {
+			return err
+		}
+
+		// the digest is recorded before parsing, so that invalid resources are only reported once
+		r.digest = digest
+
+		apiConfig, err = apiconfig.Load(ctx, r.cfg, dir)
+		return err
+	}
+
+	if r.src == nil {
+		err = read(os.DirFS(r.cfg.API.Resources))
+		return
+	}
+
+	err = r.src.View(ctx, r.rev, func(f fs.FS) error {
+		dir, err := fs.Sub(f, path.Clean(r.cfg.API.Resources))
+		if err != nil {
+			return err
+		}
+
+		return read(dir)
+	})
+
+	return
+}
+
+// watch reloads the server whenever the resources change, until the context is done.
+// Resources loaded from a source which can be watched are reloaded whenever its revision moves,
+// otherwise they are checked on each poll interval.
+// Invalid resources are logged and the server continues to serve the last good configuration.
+func (r *resources) watch(ctx context.Context, srv *api.Server) {
+	interval := r.cfg.API.ResourcesPollInterval
+	if interval <= 0 {
+		return
+	}
+
+	var (
+		ch   = make(chan struct{}, 1)
+		tick <-chan time.Time
+	)
+
+	if src, ok := r.src.(api.WatchableSource); ok {
+		src.Subscribe(ctx, r.rev, ch)
+	} else {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick:
+		case <-ch:
+		}
+
+		apiConfig, err := r.load(ctx)
+		if err != nil {
+			slog.Error("Loading resources, continuing to serve the last good configuration", "error", err)
+			continue
+		}
+
+		if apiConfig == nil {
+			continue
+		}
+
+		if err := srv.Reload(apiConfig); err != nil {
+			slog.Error("Reloading resources, continuing to serve the last good configuration", "error", err)
+			continue
+		}
+
+		slog.Info("Reloaded resources", "digest", r.digest)
+	}
+}
@@ -6,86 +6,103 @@ import (
 	"log/slog"
 	"net"
 	"net/http"
+	"os"
+	"slices"
 
 	"code.gitea.io/sdk/gitea"
-	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
 	"github.com/google/go-github/v53/github"
+	"github.com/xanzy/go-gitlab"
 	"go.flipt.io/cup/pkg/api"
-	apiconfig "go.flipt.io/cup/pkg/api/config"
 	"go.flipt.io/cup/pkg/config"
+	"go.flipt.io/cup/pkg/containers"
 	"go.flipt.io/cup/pkg/source/git"
+	"go.flipt.io/cup/pkg/source/git/auth"
+	scmbitbucket "go.flipt.io/cup/pkg/source/git/scm/bitbucket"
 	scmgitea "go.flipt.io/cup/pkg/source/git/scm/gitea"
 	scmgithub "go.flipt.io/cup/pkg/source/git/scm/github"
+	scmgitlab "go.flipt.io/cup/pkg/source/git/scm/gitlab"
+	"go.flipt.io/cup/pkg/source/git/signing"
 	"go.flipt.io/cup/pkg/source/local"
 	"tailscale.com/tsnet"
 )
 
-func serve(ctx context.Context, cfg *config.Config) error {
-	var fs api.Source
+func serve(ctx context.Context, cfg *config.Config) (err error) {
+	if err := cfg.API.LoadSources(); err != nil {
+		return err
+	}
 
-	src := cfg.API.Source
-	switch src.Type {
-	case "git":
-		gitURL, err := config.ParseGitURL(src.Git.URL)
+	var (
+		res       = &resources{cfg: cfg}
+		apiConfig = &api.Configuration{}
+		// built are the sources constructed before the remainder, by name
+		built = map[string]api.Source{}
+	)
+
+	if name := cfg.API.ResourcesSource; name != "" {
+		// the resources are loaded from the source before any other source is constructed,
+		// so that the base branches of their bindings are known
+		src, err := sourceConfig(cfg, name)
 		if err != nil {
-			return err
+			return fmt.Errorf("resources source: %w", err)
 		}
 
-		user, pass := gitURL.Credentials()
-		owner, repo, err := gitURL.OwnerRepo()
-		if err != nil {
-			return err
+		srcCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		res.rev = src.Git.Branch
+		if res.src, err = newSource(srcCtx, apiConfig, name, src); err != nil {
+			return fmt.Errorf("resources source: %w", err)
 		}
 
-		var scm git.SCM
-		switch src.Git.SCM {
-		case "gitea":
-			client, err := gitea.NewClient(gitURL.Host(), gitea.SetBasicAuth(user, pass))
-			if err != nil {
-				return err
-			}
+		if apiConfig, err = res.load(ctx); err != nil {
+			return err
+		}
 
-			scm = scmgitea.New(client, owner, repo)
-		case "github":
-			tp := github.BasicAuthTransport{
-				Username: user,
-				Password: pass,
-			}
+		// a source limited to a single branch is constructed again
+		// in order to fetch the branches of the bindings which target it
+		if src.Git.SingleBranch && len(baseBranches(apiConfig, name, src.Git.Branch)) > 1 {
+			cancel()
 
-			client := github.NewClient(tp.Client())
-			// resolve actual user name from API
-			user, _, err := client.Users.Get(ctx, "")
-			if err != nil {
-				return err
+			if res.src, err = newSource(ctx, apiConfig, name, src); err != nil {
+				return fmt.Errorf("resources source: %w", err)
 			}
-
-			scm = scmgithub.New(client, owner, repo, user.GetName())
-		default:
-			return fmt.Errorf("scm type not supported: %q", src.Git.SCM)
 		}
 
-		fs, err = git.NewSource(ctx, scm, gitURL.String(), git.WithAuth(
-			&githttp.BasicAuth{
-				Username: user,
-				Password: pass,
-			},
-		))
-		if err != nil {
-			return err
-		}
+		built[name] = res.src
+	} else if apiConfig, err = res.load(ctx); err != nil {
+		return err
+	}
 
-		slog.Debug("Configured Git Source", "url", gitURL, "scm", src.Git.SCM)
-	case "local":
-		fs = local.New(src.Local.Path)
+	source := func(name string, src config.Source) (api.Source, error) {
+		if fs, ok := built[name]; ok {
+			return fs, nil
+		}
 
-		slog.Debug("Configured Local Source", "path", src.Local.Path)
+		return newSource(ctx, apiConfig, name, src)
 	}
 
-	apiConfig, err := apiconfig.New(ctx, cfg)
+	fs, err := source(api.DefaultSource, cfg.API.Source)
 	if err != nil {
 		return err
 	}
 
+	sources := containers.MapStore[string, *api.NamedSource]{}
+
+	for name, src := range cfg.API.Sources {
+		named, err := source(name, src)
+		if err != nil {
+			return fmt.Errorf("source %q: %w", name, err)
+		}
+
+		sources[name] = &api.NamedSource{
+			Source:        named,
+			Revision:      src.Git.Branch,
+			WebhookSecret: src.Git.WebhookSecret,
+		}
+	}
+
+	apiConfig.Sources = sources
+
 	var listener net.Listener
 	if cfg.Tailscale.Hostname == "" {
 		listener, err = net.Listen("tcp", cfg.API.Address)
@@ -118,6 +135,244 @@ func serve(ctx context.Context, cfg *config.Config) error {
 		return err
 	}
 
+	go res.watch(ctx, srv)
+
 	slog.Info("Listening...", "address", listener.Addr())
 	return http.Serve(listener, srv)
 }
+
+// newSource constructs the named source from configuration.
+// The base branches of the bindings which target the source are fetched
+// when limited to a single branch.
+func newSource(ctx context.Context, apiConfig *api.Configuration, name string, src config.Source) (fs api.Source, err error) {
+	switch src.Type {
+	case "git":
+		gitURL, err := config.ParseGitURL(src.Git.URL)
+		if err != nil {
+			return nil, err
+		}
+
+		creds, err := auth.Resolve(ctx, src.Git.Auth, gitURL)
+		if err != nil {
+			return nil, err
+		}
+
+		user, pass, method := creds.Username, creds.Secret, creds.Method
+
+		// SCM API clients authenticate each request with the current token,
+		// which is read again from token files and credential helpers
+		apiClient := &http.Client{Transport: creds.Transport("Authorization", "Bearer ")}
+		if user != "" {
+			apiClient = &http.Client{Transport: creds.BasicTransport()}
+		}
+
+		var scm git.SCM
+		switch src.Git.SCM {
+		case "gitea":
+			owner, repo, err := gitURL.OwnerRepo()
+			if err != nil {
+				return nil, err
+			}
+
+			// without a username the secret is used as an access token
+			httpClient := &http.Client{Transport: creds.Transport("Authorization", "token ")}
+			if user != "" {
+				httpClient = apiClient
+			}
+
+			client, err := gitea.NewClient(gitURL.Host(), gitea.SetHTTPClient(httpClient))
+			if err != nil {
+				return nil, err
+			}
+
+			scm = scmgitea.New(client, owner, repo)
+		case "github":
+			owner, repo, err := gitURL.OwnerRepo()
+			if err != nil {
+				return nil, err
+			}
+
+			// repositories hosted anywhere but github.com are on GitHub Enterprise Server
+			// which serves its API from the same host
+			baseURL, enterprise := auth.GitHubAPIURL, gitURL.Hostname() != "github.com"
+			if enterprise {
+				baseURL = gitURL.Host() + "/api/v3/"
+			}
+
+			httpClient := apiClient
+			if src.Git.Auth.GitHubApp.ID != 0 {
+				app, err := githubApp(src.Git.Auth.GitHubApp, owner, repo, baseURL)
+				if err != nil {
+					return nil, err
+				}
+
+				httpClient = app.Client()
+
+				// installation tokens authenticate pushes over HTTPS as well as the API
+				if gitURL.Scheme != "ssh" {
+					method = app.AuthMethod()
+				}
+			}
+
+			client := github.NewClient(httpClient)
+			if enterprise {
+				client, err = github.NewEnterpriseClient(baseURL, gitURL.Host()+"/api/uploads/", httpClient)
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			scm = scmgithub.New(client, owner, repo)
+		case "gitlab":
+			owner, repo, err := gitURL.OwnerRepo()
+			if err != nil {
+				return nil, err
+			}
+
+			// the password is expected to be a personal, group or project access token
+			client, err := gitlab.NewClient(pass,
+				gitlab.WithBaseURL(gitURL.Host()),
+				gitlab.WithHTTPClient(&http.Client{Transport: creds.Transport("PRIVATE-TOKEN", "")}),
+			)
+			if err != nil {
+				return nil, err
+			}
+
+			scm = scmgitlab.New(client, owner, repo)
+		case "bitbucket":
+			owner, repo, err := gitURL.OwnerRepo()
+			if err != nil {
+				return nil, err
+			}
+
+			// repositories on bitbucket.org use the Cloud API
+			// otherwise the host is assumed to be Bitbucket Server
+			flavor, baseURL := scmbitbucket.Server, gitURL.Host()
+			if gitURL.Hostname() == "bitbucket.org" {
+				flavor, baseURL = scmbitbucket.Cloud, scmbitbucket.CloudURL
+			}
+
+			opt := scmbitbucket.WithToken(pass)
+			if user != "" {
+				opt = scmbitbucket.WithBasicAuth(user, pass)
+			}
+
+			scm = scmbitbucket.New(flavor, baseURL, owner, repo, opt, scmbitbucket.WithHTTPClient(apiClient))
+		case "none":
+			// plain Git remotes without an SCM API only support
+			// bindings which commit directly to the base branch
+		default:
+			return nil, fmt.Errorf("scm type not supported: %q", src.Git.SCM)
+		}
+
+		opts := []containers.Option[git.Source]{
+			git.WithAuth(method),
+			git.WithPollInterval(src.Git.PollInterval),
+			git.WithCacheDir(src.Git.CacheDir),
+			git.WithDepth(src.Git.Depth),
+			git.WithTemplates(src.Git.Templates),
+		}
+
+		signer, err := signing.Load(src.Git.Signing)
+		if err != nil {
+			return nil, err
+		}
+
+		if signer != nil {
+			opts = append(opts, git.WithSigner(signer))
+		}
+
+		if src.Git.SingleBranch {
+			opts = append(opts, git.WithBranches(baseBranches(apiConfig, name, src.Git.Branch)...))
+		}
+
+		fs, err = git.NewSource(ctx, scm, gitURL.String(), opts...)
+		if err != nil {
+			return nil, err
+		}
+
+		slog.Debug("Configured Git Source", "source", name, "url", gitURL.Redacted(), "scm", src.Git.SCM, "poll_interval", src.Git.PollInterval)
+	case "local":
+		if !src.Local.Git {
+			fs = local.New(src.Local.Path)
+
+			slog.Debug("Configured Local Source", "source", name, "path", src.Local.Path)
+			return fs, nil
+		}
+
+		opts := []containers.Option[git.Source]{
+			git.WithPollInterval(src.Git.PollInterval),
+			git.WithTemplates(src.Git.Templates),
+		}
+
+		signer, err := signing.Load(src.Git.Signing)
+		if err != nil {
+			return nil, err
+		}
+
+		if signer != nil {
+			opts = append(opts, git.WithSigner(signer))
+		}
+
+		fs, err = git.NewLocalSource(ctx, src.Local.Path, opts...)
+		if err != nil {
+			return nil, err
+		}
+
+		slog.Debug("Configured Local Git Source", "source", name, "path", src.Local.Path, "poll_interval", src.Git.PollInterval)
+	default:
+		return nil, fmt.Errorf("source type not supported: %q", src.Type)
+	}
+
+	return fs, nil
+}
+
+// sourceConfig returns the configuration of the named source.
+func sourceConfig(cfg *config.Config, name string) (config.Source, error) {
+	if name == api.DefaultSource {
+		return cfg.API.Source, nil
+	}
+
+	src, ok := cfg.API.Sources[name]
+	if !ok {
+		return config.Source{}, fmt.Errorf("unknown source %q", name)
+	}
+
+	return src, nil
+}
+
+// baseBranches returns the distinct base branches of the named source
+// with the provided default branch and the bindings which target it.
+func baseBranches(cfg *api.Configuration, name, branch string) []string {
+	branches := []string{"main"}
+	if branch != "" {
+		branches[0] = branch
+	}
+
+	for _, binding := range cfg.Bindings {
+		if api.SourceName(binding) != name {
+			continue
+		}
+
+		if branch := binding.Spec.Branch; branch != "" && !slices.Contains(branches, branch) {
+			branches = append(branches, branch)
+		}
+	}
+
+	return branches
+}
+
+// githubApp constructs a GitHub App installation token source from configuration
+// which mints tokens using the GitHub API served from baseURL.
+func githubApp(cfg config.GitHubApp, owner, repo, baseURL string) (*auth.GitHubApp, error) {
+	key, err := os.ReadFile(cfg.PrivateKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("reading github app private key: %w", err)
+	}
+
+	return auth.NewGitHubApp(cfg.ID, key,
+		auth.WithInstallationID(cfg.InstallationID),
+		auth.WithRepository(owner, repo),
+		auth.WithBaseURL(baseURL),
+	)
+}
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"go.flipt.io/cup/pkg/api/core"
+)
+
+// Annotations on the resources in a request which configure the details of the resulting proposal.
+// Lists are comma separated and are added to those configured on the binding.
+// They apply to the request only and so are never persisted with the resource.
+const (
+	AnnotationReviewers     = "cup.flipt.io/reviewers"
+	AnnotationTeamReviewers = "cup.flipt.io/team-reviewers"
+	AnnotationLabels        = "cup.flipt.io/labels"
+	AnnotationAssignees     = "cup.flipt.io/assignees"
+	AnnotationMilestone     = "cup.flipt.io/milestone"
+	AnnotationDraft         = "cup.flipt.io/draft"
+)
+
+var proposalAnnotations = []string{
+	AnnotationReviewers,
+	AnnotationTeamReviewers,
+	AnnotationLabels,
+	AnnotationAssignees,
+	AnnotationMilestone,
+	AnnotationDraft,
+}
+
+// proposalDetails returns the details configured by the binding combined with those
+// requested via the annotations of the resources being changed.
+// The proposal annotations are removed from the resources.
+func proposalDetails(binding *core.Binding, changes ...Change) (core.ProposalDetails, error) {
+	details := binding.Spec.Proposal
+
+	// the binding details are copied so that they are never modified
+	details.Reviewers = slices.Clone(details.Reviewers)
+	details.TeamReviewers = slices.Clone(details.TeamReviewers)
+	details.Labels = slices.Clone(details.Labels)
+	details.Assignees = slices.Clone(details.Assignees)
+
+	for _, change := range changes {
+		if change.Resource == nil {
+			continue
+		}
+
+		annotations := change.Resource.Metadata.Annotations
+
+		details.Reviewers = appendList(details.Reviewers, annotations[AnnotationReviewers])
+		details.TeamReviewers = appendList(details.TeamReviewers, annotations[AnnotationTeamReviewers])
+		details.Labels = appendList(details.Labels, annotations[AnnotationLabels])
+		details.Assignees = appendList(details.Assignees, annotations[AnnotationAssignees])
+
+		if milestone := strings.TrimSpace(annotations[AnnotationMilestone]); milestone != "" {
+			details.Milestone = milestone
+		}
+
+		if v, ok := annotations[AnnotationDraft]; ok {
+			draft, err := strconv.ParseBool(v)
+			if err != nil {
+				return details, fmt.Errorf("%w: annotation %q: %v", errInvalidRequest, AnnotationDraft, err)
+			}
+
+			details.Draft = draft
+		}
+
+		for _, key := range proposalAnnotations {
+			delete(annotations, key)
+		}
+	}
+
+	return details, nil
+}
+
+// appendList appends each distinct value from the comma separated list to values.
+func appendList(values []string, list string) []string {
+	for _, v := range strings.Split(list, ",") {
+		if v = strings.TrimSpace(v); v != "" && !slices.Contains(values, v) {
+			values = append(values, v)
+		}
+	}
+
+	return values
+}
@@ -0,0 +1,238 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/xeipuuv/gojsonschema"
+	"go.flipt.io/cup/pkg/api/core"
+	"go.flipt.io/cup/pkg/controllers"
+)
+
+// OperationType identifies the kind of change made by an Operation.
+type OperationType string
+
+const (
+	// OperationTypePut creates or replaces a resource.
+	OperationTypePut = OperationType("put")
+	// OperationTypeDelete removes a resource.
+	OperationTypeDelete = OperationType("delete")
+)
+
+// Operation is a single put or delete performed as part of a Batch.
+// Deletes only require the apiVersion, kind, namespace and name of the resource.
+type Operation struct {
+	Type     OperationType  `json:"type"`
+	Resource *core.Resource `json:"resource"`
+}
+
+// Batch is a list of operations across kinds and bindings which are applied together
+// within a single call to Source.Update and so result in a single proposal.
+type Batch struct {
+	Operations []Operation `json:"operations"`
+}
+
+// endpoint is the controller and definition registered for a particular version of a kind.
+type endpoint struct {
+	cntl    Controller
+	binding *core.Binding
+	def     *core.ResourceDefinition
+	version string
+	schema  *gojsonschema.Schema
+	src     Source
+	rev     string
+}
+
+func (e *endpoint) request(namespace string) controllers.Request {
+	return controllers.Request{
+		Group:     e.def.Spec.Group,
+		Version:   e.version,
+		Kind:      e.def.Names.Kind,
+		Namespace: namespace,
+	}
+}
+
+// step is an operation from a batch paired with the endpoint which handles it.
+type step struct {
+	Operation
+	endpoint *endpoint
+}
+
+func (s step) id() string {
+	return path.Join(s.Resource.APIVersion, s.Resource.Kind, s.Resource.Metadata.Namespace, s.Resource.Metadata.Name)
+}
+
+// change describes the operation performed by the step for use within templates.
+func (s step) change() Change {
+	var resource *core.Resource
+	if s.Type == OperationTypePut {
+		resource = s.Resource
+	}
+
+	return Change{
+		Operation: s.Type,
+		Group:     s.endpoint.def.Spec.Group,
+		Version:   s.endpoint.version,
+		Kind:      s.endpoint.def.Names.Kind,
+		Namespace: s.Resource.Metadata.Namespace,
+		Name:      s.Resource.Metadata.Name,
+		Resource:  resource,
+	}
+}
+
+func (s step) get(ctx context.Context, f fs.FS) (*core.Resource, error) {
+	return s.endpoint.cntl.Get(ctx, &controllers.GetRequest{
+		Request: s.endpoint.request(s.Resource.Metadata.Namespace),
+		FS:      f,
+		Name:    s.Resource.Metadata.Name,
+	})
+}
+
+func (s step) apply(ctx context.Context, f controllers.FSConfig) error {
+	if s.Type == OperationTypeDelete {
+		return s.endpoint.cntl.Delete(ctx, &controllers.DeleteRequest{
+			Request:  s.endpoint.request(s.Resource.Metadata.Namespace),
+			FSConfig: f,
+			Name:     s.Resource.Metadata.Name,
+		})
+	}
+
+	return s.endpoint.cntl.Put(ctx, &controllers.PutRequest{
+		Request:  s.endpoint.request(s.Resource.Metadata.Namespace),
+		FSConfig: f,
+		Name:     s.Resource.Metadata.Name,
+		Resource: s.Resource,
+	})
+}
+
+// handleBatch applies all the operations in the requested batch in a single update.
+// All the operations must target resources bound to the same source and base revision
+// and must agree on whether changes are committed directly.
+// The change is described using the templates of the binding of the first operation.
+// It supports the same dryRun and proposal query parameters as single puts and deletes.
+func (s *Server) handleBatch(w http.ResponseWriter, r *http.Request) {
+	var batch Batch
+	if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	steps, err := s.plan(batch)
+	if err != nil {
+		writeResult(w, nil, err)
+		return
+	}
+
+	var (
+		first   = steps[0].endpoint
+		changes = make([]Change, 0, len(steps))
+		lines   = make([]string, 0, len(steps))
+	)
+
+	for _, step := range steps {
+		changes = append(changes, step.change())
+		lines = append(lines, fmt.Sprintf("- %s %s/%s %s/%s",
+			step.Type,
+			step.Resource.APIVersion, step.Resource.Kind,
+			step.Resource.Metadata.Namespace, step.Resource.Metadata.Name,
+		))
+	}
+
+	opts, err := s.updateOptions(r, first.src, first.binding, changes...)
+	if err != nil {
+		writeResult(w, nil, err)
+		return
+	}
+
+	for _, step := range steps {
+		// the expected version is never persisted as it is derived from the state of the source
+		expected := step.Resource.Metadata.ResourceVersion
+		step.Resource.Metadata.ResourceVersion = ""
+
+		opts = append(opts, WithPreconditions(Precondition{Version: expected, Get: step.get}))
+	}
+
+	message := fmt.Sprintf("feat: update %d resources\n\n%s", len(steps), strings.Join(lines, "\n"))
+
+	result, err := first.src.Update(r.Context(), first.rev, message, func(f controllers.FSConfig) error {
+		for _, step := range steps {
+			if err := step.apply(r.Context(), f); err != nil {
+				return fmt.Errorf("%s %s: %w", step.Type, step.id(), err)
+			}
+		}
+
+		return nil
+	}, opts...)
+
+	writeResult(w, result, err)
+}
+
+// plan validates each operation in the batch and resolves the endpoint which handles it.
+func (s *Server) plan(batch Batch) ([]step, error) {
+	if len(batch.Operations) == 0 {
+		return nil, fmt.Errorf("%w: batch contains no operations", errInvalidRequest)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	steps := make([]step, 0, len(batch.Operations))
+	for i, op := range batch.Operations {
+		if op.Resource == nil {
+			return nil, fmt.Errorf("%w: operation %d: missing resource", errInvalidRequest, i)
+		}
+
+		gvk := path.Join(op.Resource.APIVersion, op.Resource.Kind)
+		endpoint, ok := s.endpoints[gvk]
+		if !ok {
+			return nil, fmt.Errorf("%w: operation %d: unexpected resource kind: %q", errInvalidRequest, i, gvk)
+		}
+
+		switch op.Type {
+		case OperationTypePut:
+			data, err := json.Marshal(op.Resource)
+			if err != nil {
+				return nil, err
+			}
+
+			res, err := endpoint.schema.Validate(gojsonschema.NewBytesLoader(data))
+			if err != nil {
+				return nil, err
+			}
+
+			if !res.Valid() {
+				return nil, fmt.Errorf("%w: operation %d: %v", errInvalidRequest, i, res.Errors())
+			}
+		case OperationTypeDelete:
+		default:
+			return nil, fmt.Errorf("%w: operation %d: unexpected type: %q", errInvalidRequest, i, op.Type)
+		}
+
+		if len(steps) > 0 {
+			first := steps[0].endpoint
+			if source := SourceName(endpoint.binding); source != SourceName(first.binding) {
+				return nil, fmt.Errorf("%w: operation %d: targets source %q not %q",
+					errInvalidRequest, i, source, SourceName(first.binding))
+			}
+
+			if endpoint.rev != first.rev {
+				return nil, fmt.Errorf("%w: operation %d: targets revision %q not %q",
+					errInvalidRequest, i, endpoint.rev, first.rev)
+			}
+
+			if endpoint.binding.Spec.Direct != first.binding.Spec.Direct {
+				return nil, fmt.Errorf("%w: operation %d: cannot combine direct and proposed changes",
+					errInvalidRequest, i)
+			}
+		}
+
+		steps = append(steps, step{Operation: op, endpoint: endpoint})
+	}
+
+	return steps, nil
+}
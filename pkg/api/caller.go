@@ -0,0 +1,56 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"go.flipt.io/cup/pkg/api/tailscale"
+)
+
+// Caller identifies the user on whose behalf a request is made.
+type Caller struct {
+	Name  string
+	Email string
+}
+
+// String returns the caller in the "Name <email>" form used by Git.
+func (c *Caller) String() string {
+	return fmt.Sprintf("%s <%s>", c.Name, c.Email)
+}
+
+type callerCtxKey struct{}
+
+// WithCaller returns a copy of ctx which carries the provided caller.
+func WithCaller(ctx context.Context, caller *Caller) context.Context {
+	return context.WithValue(ctx, callerCtxKey{}, caller)
+}
+
+// CallerFromContext returns the caller carried by ctx (if any).
+func CallerFromContext(ctx context.Context) (*Caller, bool) {
+	caller, ok := ctx.Value(callerCtxKey{}).(*Caller)
+	return caller, ok && caller != nil
+}
+
+// tailscaleCaller adds the Tailscale identity established by [tailscale.AddWhoIs]
+// to the request context as the caller.
+func tailscaleCaller(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		who := tailscale.WhoIs(r.Context())
+		if who == nil || who.UserProfile == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		caller := &Caller{
+			Name:  who.UserProfile.DisplayName,
+			Email: who.UserProfile.LoginName,
+		}
+
+		if caller.Name == "" {
+			caller.Name = caller.Email
+		}
+
+		next.ServeHTTP(w, r.WithContext(WithCaller(r.Context(), caller)))
+	})
+}
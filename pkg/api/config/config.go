@@ -3,6 +3,8 @@ package config
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -20,15 +22,23 @@ import (
 	"go.flipt.io/cup/pkg/controllers/wasm"
 )
 
+// New loads the configuration of the API from the resources directory.
 func New(ctx context.Context, cfg *config.Config) (*api.Configuration, error) {
+	return Load(ctx, cfg, os.DirFS(cfg.API.Resources))
+}
+
+// Load parses the definitions, controllers and bindings from the JSON resources in dir.
+// Any WASM controllers are read from their paths relative to dir.
+func Load(ctx context.Context, cfg *config.Config, dir fs.FS) (*api.Configuration, error) {
 	c := &api.Configuration{
-		Definitions: containers.MapStore[string, *core.ResourceDefinition]{},
-		Controllers: containers.MapStore[string, api.Controller]{},
-		Bindings:    containers.MapStore[string, *core.Binding]{},
+		Definitions:   containers.MapStore[string, *core.ResourceDefinition]{},
+		Controllers:   containers.MapStore[string, api.Controller]{},
+		Bindings:      containers.MapStore[string, *core.Binding]{},
+		Revision:      cfg.API.Source.Git.Branch,
+		WebhookSecret: cfg.API.Source.Git.WebhookSecret,
 	}
 
-	dir := os.DirFS(cfg.API.Resources)
-	return c, fs.WalkDir(dir, ".", func(p string, d fs.DirEntry, err error) (e error) {
+	err := fs.WalkDir(dir, ".", func(p string, d fs.DirEntry, err error) (e error) {
 		if err != nil {
 			return err
 		}
@@ -113,4 +123,36 @@ func New(ctx context.Context, cfg *config.Config) (*api.Configuration, error) {
 
 		return nil
 	})
+	if err != nil {
+		// the controllers built before the failure are never served
+		api.CloseControllers(ctx, c.Controllers)
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Digest returns a digest of the paths and contents of every file in dir.
+// It changes whenever a resource (or WASM controller) is added, modified or removed.
+func Digest(dir fs.FS) (string, error) {
+	h := sha256.New()
+	if err := fs.WalkDir(dir, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+
+		data, err := fs.ReadFile(dir, p)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(h, "%s\x00%d\x00", p, len(data))
+		h.Write(data)
+
+		return nil
+	}); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
 }
@@ -46,6 +46,9 @@ type NamespacedMetadata struct {
 	Name        string            `json:"name"`
 	Labels      map[string]string `json:"labels"`
 	Annotations map[string]string `json:"annotations"`
+	// ResourceVersion identifies the version of the source the resource was read from.
+	// It is populated by the server and is used to detect conflicting writes.
+	ResourceVersion string `json:"resourceVersion,omitempty"`
 }
 
 func (n NamespacedObject[T]) Validate() error {
@@ -38,4 +38,71 @@ type Binding Object[BindingSpec]
 type BindingSpec struct {
 	Resources  []string
 	Controller string
+	// Source is the name of the source the bound resources are served from.
+	// Bindings target the default source when empty.
+	Source string
+	// Branch overrides the default base branch of the source
+	// which the bound resources are served from and proposed against.
+	Branch string
+	// Direct commits changes to the bound resources straight to the base branch
+	// instead of opening a proposal on the SCM.
+	Direct bool
+	// Templates override the templates of the source which describe
+	// the changes made to the bound resources.
+	Templates Templates
+	// Proposal configures the reviewers, labels and other details
+	// of the proposals made for changes to the bound resources.
+	Proposal ProposalDetails
+	// AutoMerge merges the proposals made for changes to the bound resources
+	// once their required checks pass.
+	AutoMerge bool
+}
+
+// ProposalDetails are the reviewers, labels, assignees, milestone and draft status
+// set on a new proposal. Details which the SCM does not support are ignored.
+type ProposalDetails struct {
+	// Reviewers are the users requested to review the proposal.
+	Reviewers []string
+	// TeamReviewers are the teams requested to review the proposal.
+	TeamReviewers []string
+	// Labels are the names of the labels added to the proposal.
+	Labels []string
+	// Assignees are the users assigned to the proposal.
+	Assignees []string
+	// Milestone is the title of the milestone the proposal is added to.
+	Milestone string
+	// Draft opens the proposal as a draft.
+	Draft bool
+}
+
+// Templates are Go text/templates which render the branch, commit message,
+// title and body of a change.
+// Templates which are empty fall back to the defaults of the source.
+type Templates struct {
+	// Branch is the name of the branch a new proposal is pushed to.
+	Branch string
+	// Message is the commit message.
+	Message string
+	// Title is the title of a new proposal.
+	Title string
+	// Body is the description of a new proposal.
+	Body string
+}
+
+// Or returns the templates with any empty templates replaced by those of fallback.
+func (t Templates) Or(fallback Templates) Templates {
+	or := func(v, fallback string) string {
+		if v == "" {
+			return fallback
+		}
+
+		return v
+	}
+
+	return Templates{
+		Branch:  or(t.Branch, fallback.Branch),
+		Message: or(t.Message, fallback.Message),
+		Title:   or(t.Title, fallback.Title),
+		Body:    or(t.Body, fallback.Body),
+	}
 }
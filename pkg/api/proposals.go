@@ -0,0 +1,218 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/oklog/ulid/v2"
+	"golang.org/x/exp/maps"
+)
+
+// ErrProposalNotFound is returned when a requested proposal does not exist.
+var ErrProposalNotFound = errors.New("proposal not found")
+
+// ErrProposalNotOpen is returned when a proposal cannot be amended
+// because it has already been merged or closed.
+var ErrProposalNotOpen = errors.New("proposal not open")
+
+// ErrProposalBaseMismatch is returned when a proposal cannot be amended
+// because it targets a different base than the requested revision.
+var ErrProposalBaseMismatch = errors.New("proposal targets a different base")
+
+// ProposalStatus is the state of a proposal on the target SCM.
+type ProposalStatus string
+
+const (
+	// ProposalStatusOpen is a proposal which is awaiting review.
+	ProposalStatusOpen = ProposalStatus("open")
+	// ProposalStatusMerged is a proposal which has been merged into its base.
+	ProposalStatusMerged = ProposalStatus("merged")
+	// ProposalStatusClosed is a proposal which was closed without being merged.
+	ProposalStatusClosed = ProposalStatus("closed")
+)
+
+// AutoMergeState is the state of the automatic merge of a proposal.
+type AutoMergeState string
+
+const (
+	// AutoMergeStatePending is a proposal which is merged once its required checks pass.
+	AutoMergeStatePending = AutoMergeState("pending")
+	// AutoMergeStateMerged is a proposal which has been merged.
+	AutoMergeStateMerged = AutoMergeState("merged")
+	// AutoMergeStateFailed is a proposal which could not be merged, because either its
+	// checks failed or the merge itself was rejected.
+	// The proposal remains open and, when its checks are polled, it is still merged
+	// should they later pass.
+	AutoMergeStateFailed = AutoMergeState("failed")
+)
+
+// AutoMerge describes the automatic merge of a proposal once its required checks pass.
+type AutoMerge struct {
+	State AutoMergeState `json:"state"`
+	// Native is true when the merge is performed by the SCM itself,
+	// rather than by polling the status of the checks on the proposal.
+	Native bool `json:"native,omitempty"`
+	// Message describes why the proposal could not be merged.
+	Message string `json:"message,omitempty"`
+}
+
+// ProposalSource is an optional extension of Source.
+// Implementations expose the lifecycle of the proposals made by Update.
+type ProposalSource interface {
+	Source
+	// Proposals returns all the currently open proposals.
+	Proposals(context.Context) ([]*Proposal, error)
+	// Proposal returns the proposal identified by id in any state.
+	Proposal(_ context.Context, id ulid.ULID) (*Proposal, error)
+	// MergeProposal merges the proposal identified by id into its base.
+	MergeProposal(_ context.Context, id ulid.ULID) error
+	// CloseProposal closes the proposal identified by id without merging.
+	CloseProposal(_ context.Context, id ulid.ULID) error
+}
+
+// registerProposals adds the routes for managing the lifecycle of proposals.
+func (s *Server) registerProposals(mux chi.Router) {
+	mux.Route("/proposals", func(r chi.Router) {
+		r.Get("/", s.proposalsHandler(func(w http.ResponseWriter, r *http.Request, srcs []ProposalSource) error {
+			enc := json.NewEncoder(w)
+			for _, src := range srcs {
+				proposals, err := src.Proposals(r.Context())
+				if err != nil {
+					return err
+				}
+
+				for _, proposal := range proposals {
+					if err := enc.Encode(proposal); err != nil {
+						return err
+					}
+				}
+			}
+
+			return nil
+		}))
+
+		r.Get("/{id}", s.proposalHandler(func(w http.ResponseWriter, r *http.Request, src ProposalSource, id ulid.ULID) error {
+			proposal, err := src.Proposal(r.Context(), id)
+			if err != nil {
+				return err
+			}
+
+			return json.NewEncoder(w).Encode(proposal)
+		}))
+
+		r.Post("/{id}/merge", s.proposalHandler(func(w http.ResponseWriter, r *http.Request, src ProposalSource, id ulid.ULID) error {
+			if err := src.MergeProposal(r.Context(), id); err != nil {
+				return err
+			}
+
+			w.WriteHeader(http.StatusNoContent)
+			return nil
+		}))
+
+		r.Post("/{id}/close", s.proposalHandler(func(w http.ResponseWriter, r *http.Request, src ProposalSource, id ulid.ULID) error {
+			if err := src.CloseProposal(r.Context(), id); err != nil {
+				return err
+			}
+
+			w.WriteHeader(http.StatusNoContent)
+			return nil
+		}))
+	})
+}
+
+type proposalsFunc func(http.ResponseWriter, *http.Request, []ProposalSource) error
+
+// proposalsHandler adapts fn into a handler which is supplied with the sources which support
+// proposals and which writes appropriate responses for any errors.
+// The sources are restricted to the one named by the source query parameter (if any).
+func (s *Server) proposalsHandler(fn proposalsFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		srcs, err := s.proposalSources(r.URL.Query().Get("source"))
+		if err != nil {
+			http.Error(w, err.Error(), errorStatus(err))
+			return
+		}
+
+		if err := fn(w, r, srcs); err != nil {
+			http.Error(w, err.Error(), errorStatus(err))
+		}
+	}
+}
+
+type proposalFunc func(http.ResponseWriter, *http.Request, ProposalSource, ulid.ULID) error
+
+// proposalHandler is a proposalsHandler which additionally parses the proposal ID from the path
+// and supplies fn with the source the proposal was made on.
+func (s *Server) proposalHandler(fn proposalFunc) http.HandlerFunc {
+	return s.proposalsHandler(func(w http.ResponseWriter, r *http.Request, srcs []ProposalSource) error {
+		id, err := ulid.Parse(chi.URLParamFromCtx(r.Context(), "id"))
+		if err != nil {
+			http.Error(w, "invalid proposal id: "+err.Error(), http.StatusBadRequest)
+			return nil
+		}
+
+		src, err := findProposal(r.Context(), srcs, id)
+		if err != nil {
+			return err
+		}
+
+		return fn(w, r, src, id)
+	})
+}
+
+// proposalSources returns the sources which support proposals, ordered by name.
+// When name is not empty, only the named source is returned.
+func (s *Server) proposalSources(name string) (srcs []ProposalSource, _ error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	names := maps.Keys(s.sources)
+	slices.Sort(names)
+
+	if name != "" {
+		if _, ok := s.sources[name]; !ok {
+			return nil, fmt.Errorf("%w: unknown source %q", errInvalidRequest, name)
+		}
+
+		names = []string{name}
+	}
+
+	for _, name := range names {
+		if src, ok := s.sources[name].Source.(ProposalSource); ok {
+			srcs = append(srcs, src)
+		}
+	}
+
+	if len(srcs) == 0 {
+		return nil, fmt.Errorf("source does not support proposals: %w", errors.ErrUnsupported)
+	}
+
+	return srcs, nil
+}
+
+// findProposal returns the source which made the proposal identified by id.
+// A single source is returned as is, leaving it to report whether the proposal exists.
+func findProposal(ctx context.Context, srcs []ProposalSource, id ulid.ULID) (ProposalSource, error) {
+	if len(srcs) == 1 {
+		return srcs[0], nil
+	}
+
+	for _, src := range srcs {
+		if _, err := src.Proposal(ctx, id); err != nil {
+			if errors.Is(err, ErrProposalNotFound) {
+				continue
+			}
+
+			return nil, err
+		}
+
+		return src, nil
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrProposalNotFound, id)
+}
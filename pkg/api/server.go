@@ -3,11 +3,14 @@ package api
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
 	"log/slog"
 	"net/http"
+	"path"
+	"strconv"
 	"sync"
 
 	"github.com/go-chi/chi/v5"
@@ -19,6 +22,7 @@ import (
 	"go.flipt.io/cup/pkg/api/tailscale"
 	"go.flipt.io/cup/pkg/containers"
 	"go.flipt.io/cup/pkg/controllers"
+	"go.flipt.io/cup/pkg/labels"
 )
 
 // ViewFunc is a function provided to Source.View.
@@ -34,11 +38,40 @@ type Result struct {
 	ID       ulid.ULID `json:"id"`
 	Empty    bool      `json:"empty"`
 	Proposal *Proposal `json:"proposal"`
+	// Diff is the unified diff of the change.
+	// It is only populated for dry-run updates.
+	Diff string `json:"diff,omitempty"`
+	// Commit is the SHA of the commit made to the base branch.
+	// It is only populated for direct updates.
+	Commit string `json:"commit,omitempty"`
+	// Applied is true when the change was made without awaiting review,
+	// either directly to the base branch or to the branch of an existing proposal.
+	Applied bool `json:"applied,omitempty"`
+	// DryRun is true when the change was only computed (as Diff) and was neither applied nor proposed.
+	DryRun bool `json:"dryRun,omitempty"`
 }
 
+// Proposal is a change which has been proposed for review on a target SCM.
 type Proposal struct {
-	Source string `json:"source"`
-	URL    string `json:"url"`
+	ID     ulid.ULID `json:"id"`
+	Source string    `json:"source"`
+	URL    string    `json:"url"`
+	// Status is the current state of the proposal.
+	Status ProposalStatus `json:"status,omitempty"`
+	// Head is the branch containing the proposed change.
+	Head string `json:"head,omitempty"`
+	// Base is the branch the change is proposed against.
+	Base string `json:"base,omitempty"`
+	// Resources identifies the resources affected by the proposal
+	// in the form <group>/<version>/<kind>/<namespace>/<name>.
+	Resources []string `json:"resources,omitempty"`
+	// Conflicts identifies the files changed on both the base and the proposal since
+	// it was made, which could not be automatically rebased or replayed.
+	// The proposal is conflicted when non-empty.
+	Conflicts []string `json:"conflicts,omitempty"`
+	// AutoMerge reports the progress of merging the proposal once its checks pass.
+	// It is nil when the proposal is not automatically merged.
+	AutoMerge *AutoMerge `json:"autoMerge,omitempty"`
 }
 
 // Source is the abstraction around a target source filesystem.
@@ -51,7 +84,104 @@ type Source interface {
 	// Update invokes the provided function with an FSConfig which can be written to
 	// Any writes performed to the target during the execution of fn will be added,
 	// comitted, pushed and proposed for review on a target SCM
-	Update(_ context.Context, revision, message string, fn UpdateFunc) (*Result, error)
+	Update(_ context.Context, revision, message string, fn UpdateFunc, opts ...containers.Option[UpdateOptions]) (*Result, error)
+}
+
+// UpdateOptions configures a call to Source.Update.
+type UpdateOptions struct {
+	// DryRun computes the change and returns it as a diff
+	// without pushing or proposing it.
+	DryRun bool
+	// Direct commits the change straight to the target revision
+	// instead of proposing it.
+	Direct bool
+	// Resources identifies the resources affected by the update
+	// in the form <group>/<version>/<kind>/<namespace>/<name>.
+	Resources []string
+	// Proposal identifies an existing open proposal to amend.
+	// When zero, a new proposal is made.
+	Proposal ulid.ULID
+	// Changes describe the operations performed on resources by the update.
+	// They are made available to the templates which describe the change.
+	Changes []Change
+	// Templates render the branch, commit message, title and body of the change.
+	// Empty templates fall back to those configured on the source.
+	Templates core.Templates
+	// ProposalDetails are the reviewers, labels and other details of a new proposal.
+	ProposalDetails core.ProposalDetails
+	// AutoMerge merges the proposal once its required checks pass.
+	AutoMerge bool
+	// Preconditions must hold for the state of the revision the update is applied to
+	// (see CheckPreconditions), otherwise the update fails with a conflict.
+	Preconditions []Precondition
+}
+
+// WithDryRun configures Update to compute and return the diff of the change
+// without pushing or proposing it.
+// Sources which cannot support this return an error wrapping errors.ErrUnsupported.
+func WithDryRun(dryRun bool) containers.Option[UpdateOptions] {
+	return func(o *UpdateOptions) {
+		o.DryRun = dryRun
+	}
+}
+
+// WithPreconditions adds preconditions which must hold for the state of the revision the update is applied to.
+func WithPreconditions(preconditions ...Precondition) containers.Option[UpdateOptions] {
+	return func(o *UpdateOptions) {
+		o.Preconditions = append(o.Preconditions, preconditions...)
+	}
+}
+
+// WithResources records the identifiers of the resources affected by the update.
+func WithResources(ids ...string) containers.Option[UpdateOptions] {
+	return func(o *UpdateOptions) {
+		o.Resources = append(o.Resources, ids...)
+	}
+}
+
+// WithDirect configures Update to commit the change directly to the target revision
+// without opening a proposal.
+func WithDirect(direct bool) containers.Option[UpdateOptions] {
+	return func(o *UpdateOptions) {
+		o.Direct = direct
+	}
+}
+
+// WithProposal configures Update to amend the existing proposal identified by id
+// with a new commit instead of making a new proposal.
+func WithProposal(id ulid.ULID) containers.Option[UpdateOptions] {
+	return func(o *UpdateOptions) {
+		o.Proposal = id
+	}
+}
+
+// WithChanges records the operations performed on resources by the update.
+func WithChanges(changes ...Change) containers.Option[UpdateOptions] {
+	return func(o *UpdateOptions) {
+		o.Changes = append(o.Changes, changes...)
+	}
+}
+
+// WithTemplates configures the templates which describe the change.
+func WithTemplates(templates core.Templates) containers.Option[UpdateOptions] {
+	return func(o *UpdateOptions) {
+		o.Templates = templates
+	}
+}
+
+// WithProposalDetails configures the reviewers, labels and other details of a new proposal.
+func WithProposalDetails(details core.ProposalDetails) containers.Option[UpdateOptions] {
+	return func(o *UpdateOptions) {
+		o.ProposalDetails = details
+	}
+}
+
+// WithAutoMerge configures Update to merge the proposal once its required checks pass.
+// Sources which cannot support this return an error wrapping errors.ErrUnsupported.
+func WithAutoMerge(autoMerge bool) containers.Option[UpdateOptions] {
+	return func(o *UpdateOptions) {
+		o.AutoMerge = autoMerge
+	}
 }
 
 // Controller is the core controller interface for handling interactions with a
@@ -68,6 +198,15 @@ type Configuration struct {
 	Controllers     containers.MapStore[string, Controller]
 	Bindings        containers.MapStore[string, *core.Binding]
 	TailscaleClient tailscale.Client
+	// Revision is the default revision (base branch) served and proposed against.
+	// It can be overridden per binding and defaults to "main" when empty.
+	Revision string
+	// WebhookSecret is used to verify webhooks received from the SCM.
+	// Webhooks are disabled when empty.
+	WebhookSecret string
+	// Sources are additional named sources which bindings can target
+	// alongside the default source provided to NewServer.
+	Sources containers.MapStore[string, *NamedSource]
 }
 
 // Server is the core api.Server for cupd.
@@ -75,66 +214,243 @@ type Configuration struct {
 type Server struct {
 	mu  sync.RWMutex
 	mux *chi.Mux
-	fs  Source
-	cfg *Configuration
-	rev string
+	// inflight tracks the requests served by mux, which must complete
+	// before the controllers it was built from are closed
+	inflight  *sync.WaitGroup
+	cfg       *Configuration
+	sources   containers.MapStore[string, *NamedSource]
+	endpoints map[string]*endpoint
 }
 
 // NewServer constructs and configures a new instance of *api.Server
 // It uses the provided controller and filesystem store to build and serve
 // requests for sources, definitions and resources.
+// The filesystem store is the default source, which is served alongside
+// the named sources of the configuration.
 func NewServer(fs Source, cfg *Configuration) (*Server, error) {
 	s := &Server{
-		mux: chi.NewMux(),
-		fs:  fs,
-		cfg: cfg,
-		rev: "main",
+		sources: containers.MapStore[string, *NamedSource]{},
+	}
+
+	sources := map[string]*NamedSource{
+		DefaultSource: {
+			Source:        fs,
+			Revision:      cfg.Revision,
+			WebhookSecret: cfg.WebhookSecret,
+		},
+	}
+
+	for name, src := range cfg.Sources {
+		if name == "" || name == DefaultSource {
+			return nil, fmt.Errorf("source name %q is reserved", name)
+		}
+
+		sources[name] = src
+	}
+
+	for name, src := range sources {
+		named := *src
+		if named.Revision == "" {
+			named.Revision = "main"
+		}
+
+		s.sources[name] = &named
+	}
+
+	if err := s.Reload(cfg); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// ServeHTTP delegates to the underlying chi.Mux router.
+// Requests are served by the router current at the time they are received,
+// which means in-flight requests (such as watches) are unaffected by a call to Reload.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	mux, inflight := s.mux, s.inflight
+	inflight.Add(1)
+	s.mu.RUnlock()
+
+	defer inflight.Done()
+
+	mux.ServeHTTP(w, r)
+}
+
+// closer is implemented by controllers which hold resources (such as a WASM runtime)
+// that are released once the controller has been replaced by a call to Reload.
+type closer interface {
+	Close(context.Context) error
+}
+
+// replayer is implemented by sources which retain the update functions of open proposals in order to replay them.
+// The update functions call the controllers they were made with, so they are discarded once those controllers
+// have been replaced by a call to Reload.
+type replayer interface {
+	DiscardReplays()
+}
+
+// Reload rebuilds the routes of the server from the definitions, controllers and bindings of cfg.
+// The remainder of the configuration (sources, revision, webhook secret and tailscale client)
+// is retained from the configuration the server was constructed with.
+// The new routes replace the existing ones atomically, once they have all been built.
+// Controllers which are replaced are closed once the requests served by the previous routes have completed
+// and the sources have discarded the updates they retained which may call them.
+// When cfg is invalid an error is returned, the controllers of cfg which are not already served are closed
+// and the server continues to serve the existing routes.
+func (s *Server) Reload(cfg *Configuration) error {
+	var current containers.MapStore[string, Controller]
+
+	s.mu.RLock()
+	next := *cfg
+	if s.cfg != nil {
+		next = *s.cfg
+		next.Definitions = cfg.Definitions
+		next.Controllers = cfg.Controllers
+		next.Bindings = cfg.Bindings
+		current = s.cfg.Controllers
+	}
+	s.mu.RUnlock()
+
+	mux, endpoints, err := s.routes(&next)
+	if err != nil {
+		// the controllers of the rejected configuration are never served
+		closeReplaced(&sync.WaitGroup{}, cfg.Controllers, current)
+		return err
 	}
 
-	s.mux.Use(logger.New(slog.Default().Handler()))
-	s.mux.Use(cors.AllowAll().Handler)
+	s.mu.Lock()
+	prev, inflight := s.cfg, s.inflight
+	s.cfg, s.mux, s.inflight, s.endpoints = &next, mux, &sync.WaitGroup{}, endpoints
+	s.mu.Unlock()
+
+	if prev != nil {
+		go func() {
+			inflight.Wait()
+
+			for _, src := range s.sources {
+				if r, ok := src.Source.(replayer); ok {
+					r.DiscardReplays()
+				}
+			}
+
+			closeReplaced(inflight, prev.Controllers, next.Controllers)
+		}()
+	}
+
+	return nil
+}
+
+// CloseControllers closes each of the provided controllers which holds resources (such as a WASM runtime).
+func CloseControllers(ctx context.Context, controllers containers.MapStore[string, Controller]) {
+	for name, cntl := range controllers {
+		c, ok := cntl.(closer)
+		if !ok {
+			continue
+		}
+
+		if err := c.Close(ctx); err != nil {
+			slog.Warn("Closing controller", "controller", name, "error", err)
+		}
+	}
+}
+
+// closeReplaced closes the controllers of prev which are not retained in next,
+// once the in-flight requests which may be using them have completed.
+func closeReplaced(inflight *sync.WaitGroup, prev, next containers.MapStore[string, Controller]) {
+	inflight.Wait()
+
+	retained := map[closer]bool{}
+	for _, cntl := range next {
+		if c, ok := cntl.(closer); ok {
+			retained[c] = true
+		}
+	}
+
+	for name, cntl := range prev {
+		c, ok := cntl.(closer)
+		if !ok || retained[c] {
+			continue
+		}
+
+		if err := c.Close(context.Background()); err != nil {
+			slog.Warn("Closing replaced controller", "controller", name, "error", err)
+		}
+	}
+}
+
+// routes builds a router and the endpoints it serves for the bindings of cfg.
+func (s *Server) routes(cfg *Configuration) (_ *chi.Mux, _ map[string]*endpoint, err error) {
+	// chi panics on invalid route patterns, which are derived from the definitions
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("registering routes: %v", r)
+		}
+	}()
+
+	var (
+		mux       = chi.NewMux()
+		endpoints = map[string]*endpoint{}
+	)
+
+	mux.Use(logger.New(slog.Default().Handler()))
+	mux.Use(cors.AllowAll().Handler)
 	if cfg.TailscaleClient != nil {
-		s.mux.Use(tailscale.AddWhoIs(cfg.TailscaleClient))
+		mux.Use(tailscale.AddWhoIs(cfg.TailscaleClient))
+		mux.Use(tailscaleCaller)
 	}
 
-	s.mux.Get("/apis", s.handleSourceDefinitions)
+	mux.Get("/apis", s.handleSourceDefinitions)
+	mux.Get("/apis/sources", s.handleSources)
+	mux.Post("/apis/batch", s.handleBatch)
+	s.registerProposals(mux)
+	s.registerWebhooks(mux)
 
 	for _, binding := range cfg.Bindings {
 		cntrl, err := cfg.Controllers.Get(binding.Spec.Controller)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
+		}
+
+		if _, err := s.sources.Get(SourceName(binding)); err != nil {
+			return nil, nil, fmt.Errorf("binding %q: source: %w", binding.Metadata.Name, err)
+		}
+
+		if err := ParseTemplates(binding.Spec.Templates); err != nil {
+			return nil, nil, fmt.Errorf("binding %q: %w", binding.Metadata.Name, err)
 		}
 
 		for _, resource := range binding.Spec.Resources {
 			def, err := cfg.Definitions.Get(resource)
 			if err != nil {
-				return nil, err
+				return nil, nil, err
 			}
 
 			for version := range def.Spec.Versions {
-				if err := s.register(cntrl, version, def); err != nil {
-					return nil, err
+				if err := s.register(mux, endpoints, cntrl, binding, version, def); err != nil {
+					return nil, nil, err
 				}
 			}
 		}
 	}
 
-	return s, nil
+	return mux, endpoints, nil
 }
 
-// ServeHTTP delegates to the underlying chi.Mux router.
-func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-
-	s.mux.ServeHTTP(w, r)
-}
+// register adds the routes for a controller and definition to the router
+// along with the endpoint which describes them.
+// The binding determines the source and base branch resources are read from and how changes are made.
+func (s *Server) register(mux chi.Router, endpoints map[string]*endpoint, cntl Controller, binding *core.Binding, version string, def *core.ResourceDefinition) error {
+	source, err := s.sources.Get(SourceName(binding))
+	if err != nil {
+		return err
+	}
 
-// register adds a new controller and definition with a particular filesystem to the server.
-// This may happen dynamically in the future, so it is guarded with a write lock.
-func (s *Server) register(cntl Controller, version string, def *core.ResourceDefinition) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	src, rev := source.Source, source.Revision
+	if binding.Spec.Branch != "" {
+		rev = binding.Spec.Branch
+	}
 
 	var (
 		prefix = fmt.Sprintf("/apis/%s/%s/namespaces/{ns}/%s", def.Spec.Group, version, def.Names.Plural)
@@ -148,18 +464,54 @@ func (s *Server) register(cntl Controller, version string, def *core.ResourceDef
 		return err
 	}
 
+	endpoints[path.Join(def.Spec.Group, version, def.Names.Kind)] = &endpoint{
+		cntl:    cntl,
+		binding: binding,
+		def:     def,
+		version: version,
+		schema:  schema,
+		src:     src,
+		rev:     rev,
+	}
+
 	// list kind
-	s.mux.Get(prefix, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if err := s.fs.View(r.Context(), s.rev, func(f fs.FS) error {
-			resources, err := cntl.List(r.Context(), &controllers.ListRequest{
+	mux.Get(prefix, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		selector, err := labels.Parse(r.URL.Query().Get("labelSelector"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		list := func(ctx context.Context, f fs.FS) ([]*core.Resource, error) {
+			resources, err := cntl.List(ctx, &controllers.ListRequest{
 				Request: controllers.Request{
 					Group:     def.Spec.Group,
 					Version:   version,
 					Kind:      def.Names.Kind,
 					Namespace: chi.URLParamFromCtx(r.Context(), "ns"),
 				},
-				FS: f,
+				FS:     f,
+				Labels: selector,
 			})
+			if err != nil {
+				return nil, err
+			}
+
+			for _, resource := range resources {
+				resource.Metadata.ResourceVersion = fsVersion(f)
+			}
+
+			return resources, nil
+		}
+
+		target := revision(r, rev)
+		if r.URL.Query().Get("watch") == "true" {
+			s.watch(w, r, src, target, list)
+			return
+		}
+
+		if err := src.View(r.Context(), target, func(f fs.FS) error {
+			resources, err := list(r.Context(), f)
 			if err != nil {
 				return err
 			}
@@ -173,15 +525,27 @@ func (s *Server) register(cntl Controller, version string, def *core.ResourceDef
 
 			return nil
 		}); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			http.Error(w, err.Error(), errorStatus(err))
 			return
 		}
 	}))
 
-	// get kind
-	s.mux.Get(named, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if err := s.fs.View(r.Context(), s.rev, func(f fs.FS) error {
-			resource, err := cntl.Get(r.Context(), &controllers.GetRequest{
+	// change describes the operation on the resource addressed by the request path
+	change := func(r *http.Request, op OperationType, resource *core.Resource) Change {
+		return Change{
+			Operation: op,
+			Group:     def.Spec.Group,
+			Version:   version,
+			Kind:      def.Names.Kind,
+			Namespace: chi.URLParamFromCtx(r.Context(), "ns"),
+			Name:      chi.URLParamFromCtx(r.Context(), "name"),
+			Resource:  resource,
+		}
+	}
+
+	get := func(r *http.Request) getFunc {
+		return func(ctx context.Context, f fs.FS) (*core.Resource, error) {
+			return cntl.Get(ctx, &controllers.GetRequest{
 				Request: controllers.Request{
 					Group:     def.Spec.Group,
 					Version:   version,
@@ -191,19 +555,31 @@ func (s *Server) register(cntl Controller, version string, def *core.ResourceDef
 				FS:   f,
 				Name: chi.URLParamFromCtx(r.Context(), "name"),
 			})
+		}
+	}
+
+	// get kind
+	mux.Get(named, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := src.View(r.Context(), revision(r, rev), func(f fs.FS) error {
+			resource, err := get(r)(r.Context(), f)
 			if err != nil {
 				return err
 			}
 
+			if v := fsVersion(f); v != "" {
+				resource.Metadata.ResourceVersion = v
+				w.Header().Set("ETag", fmt.Sprintf("%q", v))
+			}
+
 			return json.NewEncoder(w).Encode(resource)
 		}); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			http.Error(w, err.Error(), errorStatus(err))
 			return
 		}
 	}))
 
 	// put kind
-	s.mux.Put(named, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	mux.Put(named, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		data, err := io.ReadAll(r.Body)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
@@ -227,13 +603,30 @@ func (s *Server) register(cntl Controller, version string, def *core.ResourceDef
 			return
 		}
 
+		// the expected version is taken from If-Match and then the resource itself
+		// it is never persisted as it is derived from the state of the source
+		expected := ifMatch(r)
+		if expected == "" {
+			expected = resource.Metadata.ResourceVersion
+		}
+
+		resource.Metadata.ResourceVersion = ""
+
+		opts, err := s.updateOptions(r, src, binding, change(r, OperationTypePut, &resource))
+		if err != nil {
+			writeResult(w, nil, err)
+			return
+		}
+
+		opts = append(opts, WithPreconditions(Precondition{Version: expected, Get: get(r)}))
+
 		message := fmt.Sprintf(
 			"feat: update %s/%s %s/%s",
 			resource.APIVersion, resource.Kind,
 			resource.Metadata.Namespace, resource.Metadata.Name,
 		)
 
-		result, err := s.fs.Update(r.Context(), s.rev, message, func(f controllers.FSConfig) error {
+		result, err := src.Update(r.Context(), rev, message, func(f controllers.FSConfig) error {
 			return cntl.Put(r.Context(), &controllers.PutRequest{
 				Request: controllers.Request{
 					Group:     def.Spec.Group,
@@ -245,28 +638,13 @@ func (s *Server) register(cntl Controller, version string, def *core.ResourceDef
 				Name:     chi.URLParamFromCtx(r.Context(), "name"),
 				Resource: &resource,
 			})
-		})
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-
-		// result was empty and so no proposal or change was made
-		if result.Empty {
-			w.WriteHeader(http.StatusNoContent)
-			return
-		}
-
-		w.WriteHeader(http.StatusAccepted)
+		}, opts...)
 
-		if err := json.NewEncoder(w).Encode(result); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
+		writeResult(w, result, err)
 	}))
 
 	// delete kind
-	s.mux.Delete(named, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	mux.Delete(named, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		var (
 			namespace = chi.URLParamFromCtx(r.Context(), "ns")
 			name      = chi.URLParamFromCtx(r.Context(), "name")
@@ -277,7 +655,15 @@ func (s *Server) register(cntl Controller, version string, def *core.ResourceDef
 			)
 		)
 
-		result, err := s.fs.Update(r.Context(), s.rev, message, func(f controllers.FSConfig) error {
+		opts, err := s.updateOptions(r, src, binding, change(r, OperationTypeDelete, nil))
+		if err != nil {
+			writeResult(w, nil, err)
+			return
+		}
+
+		opts = append(opts, WithPreconditions(Precondition{Version: ifMatch(r), Get: get(r)}))
+
+		result, err := src.Update(r.Context(), rev, message, func(f controllers.FSConfig) error {
 			return cntl.Delete(r.Context(), &controllers.DeleteRequest{
 				Request: controllers.Request{
 					Group:     def.Spec.Group,
@@ -288,27 +674,145 @@ func (s *Server) register(cntl Controller, version string, def *core.ResourceDef
 				FSConfig: f,
 				Name:     name,
 			})
-		})
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
+		}, opts...)
 
-		// result was empty and so no proposal or change was made
-		if result.Empty {
-			w.WriteHeader(http.StatusNoContent)
-			return
-		}
+		writeResult(w, result, err)
+	}))
 
-		w.WriteHeader(http.StatusAccepted)
+	return nil
+}
 
-		if err := json.NewEncoder(w).Encode(result); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
+// revision returns the revision requested via the revision query parameter.
+// When not supplied it falls back to the provided default revision.
+func revision(r *http.Request, def string) string {
+	if rev := r.URL.Query().Get("revision"); rev != "" {
+		return rev
+	}
+
+	return def
+}
+
+// updateOptions returns the update options configured by the binding
+// and requested via the query parameters for the provided changes to resources.
+// Any proposal annotations are removed from the changed resources.
+// Proposals to amend must exist on the source.
+func (s *Server) updateOptions(r *http.Request, src Source, binding *core.Binding, changes ...Change) (opts []containers.Option[UpdateOptions], _ error) {
+	ids := make([]string, 0, len(changes))
+	for _, change := range changes {
+		ids = append(ids, change.String())
+	}
+
+	details, err := proposalDetails(binding, changes...)
+	if err != nil {
+		return nil, err
+	}
+
+	opts = append(opts,
+		WithResources(ids...),
+		WithChanges(changes...),
+		WithTemplates(binding.Spec.Templates),
+		WithProposalDetails(details),
+	)
+
+	if binding.Spec.Direct {
+		opts = append(opts, WithDirect(true))
+	}
+
+	query := r.URL.Query()
+	if query.Get("dryRun") == "true" {
+		opts = append(opts, WithDryRun(true))
+	}
+
+	autoMerge := binding.Spec.AutoMerge
+	if v := query.Get("autoMerge"); v != "" {
+		if autoMerge, err = strconv.ParseBool(v); err != nil {
+			return nil, fmt.Errorf("%w: autoMerge: %v", errInvalidRequest, err)
 		}
-	}))
+	}
 
-	return nil
+	if autoMerge && !binding.Spec.Direct {
+		opts = append(opts, WithAutoMerge(true))
+	}
+
+	param := query.Get("proposal")
+	if param == "" {
+		return opts, nil
+	}
+
+	if binding.Spec.Direct {
+		return nil, fmt.Errorf("%w: proposals cannot be amended for direct bindings", errInvalidRequest)
+	}
+
+	id, err := ulid.Parse(param)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid proposal id: %v", errInvalidRequest, err)
+	}
+
+	proposals, ok := src.(ProposalSource)
+	if !ok {
+		return nil, fmt.Errorf("amending proposal: %w", errors.ErrUnsupported)
+	}
+
+	if _, err := proposals.Proposal(r.Context(), id); err != nil {
+		return nil, err
+	}
+
+	return append(opts, WithProposal(id)), nil
+}
+
+// ErrRevisionNotFound is returned by sources when a requested revision cannot be resolved.
+var ErrRevisionNotFound = errors.New("revision not found")
+
+// ErrRevisionMoved is returned by sources when the revision being updated keeps moving
+// before the change can be made to it.
+var ErrRevisionMoved = errors.New("revision moved")
+
+// errInvalidRequest is returned when the parameters of a request are invalid.
+var errInvalidRequest = errors.New("invalid request")
+
+// errorStatus returns the HTTP status code which best describes err.
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, errInvalidRequest):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrProposalNotFound), errors.Is(err, ErrRevisionNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrProposalNotOpen), errors.Is(err, ErrProposalBaseMismatch),
+		errors.Is(err, ErrRevisionMoved), errors.Is(err, errConflict):
+		return http.StatusConflict
+	case errors.Is(err, errors.ErrUnsupported):
+		return http.StatusNotImplemented
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// writeResult writes the response for the result of a call to Source.Update.
+// Dry-run and applied results (direct updates and amendments) are returned with 200 OK,
+// new proposals with 202 Accepted
+// and empty results (where no change was made) with 204 No Content.
+func writeResult(w http.ResponseWriter, result *Result, err error) {
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+
+	// result was empty and so no proposal or change was made
+	if result.Empty {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	// changes which are proposed are accepted while they await review
+	if result.Applied || result.DryRun {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusAccepted)
+	}
+
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		slog.Error("Encoding result", "error", err)
+	}
 }
 
 func (s *Server) handleSourceDefinitions(w http.ResponseWriter, r *http.Request) {
@@ -2,17 +2,26 @@ package api_test
 
 import (
 	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"io"
+	"io/fs"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/go-git/go-billy/v5"
 	"github.com/go-git/go-billy/v5/memfs"
 	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/oklog/ulid/v2"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.flipt.io/cup/pkg/api"
@@ -20,7 +29,11 @@ import (
 	"go.flipt.io/cup/pkg/containers"
 	"go.flipt.io/cup/pkg/controllers/template"
 	"go.flipt.io/cup/pkg/encoding"
+	"go.flipt.io/cup/pkg/source/git"
+	memscm "go.flipt.io/cup/pkg/source/git/scm/mem"
 	"go.flipt.io/cup/pkg/source/mem"
+	"tailscale.com/client/tailscale/apitype"
+	"tailscale.com/tailcfg"
 )
 
 var (
@@ -142,6 +155,83 @@ func Test_Server_Get(t *testing.T) {
 	}, resource)
 }
 
+func Test_Server_Get_Revision(t *testing.T) {
+	fs := memfs.New()
+	fi, err := fs.Create("default/test.cup.flipt.io-v1alpha1-Resource-foo.json")
+	require.NoError(t, err)
+
+	_, err = io.Copy(fi, strings.NewReader(fooModifiedPayload))
+	require.NoError(t, err)
+	require.NoError(t, fi.Close())
+
+	fss := mem.New()
+	fss.AddFS("main", osfs.New("testdata"))
+	fss.AddFS("proposal", fs)
+
+	cntrl := template.New()
+	config := config(t, cntrl)
+
+	server, err := api.NewServer(fss, config)
+	require.NoError(t, err)
+
+	srv := httptest.NewServer(server)
+	t.Cleanup(srv.Close)
+
+	path := "/apis/test.cup.flipt.io/v1alpha1/namespaces/default/resources/foo?revision=proposal"
+	resp, err := http.Get(srv.URL + path)
+	require.NoError(t, err)
+
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var resource *core.Resource
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&resource))
+
+	assert.JSONEq(t, `{"foo":"bar"}`, string(resource.Spec))
+
+	t.Run("unknown revision", func(t *testing.T) {
+		for _, path := range []string{
+			"/apis/test.cup.flipt.io/v1alpha1/namespaces/default/resources/foo?revision=unknown",
+			"/apis/test.cup.flipt.io/v1alpha1/namespaces/default/resources?revision=unknown",
+		} {
+			resp, err := http.Get(srv.URL + path)
+			require.NoError(t, err)
+			require.NoError(t, resp.Body.Close())
+
+			assert.Equal(t, http.StatusNotFound, resp.StatusCode, path)
+		}
+	})
+}
+
+func Test_Server_Get_BindingBranch(t *testing.T) {
+	fss := mem.New()
+	fss.AddFS("production", osfs.New("testdata"))
+
+	cntrl := template.New()
+	config := config(t, cntrl)
+	config.Bindings["test"].Spec.Branch = "production"
+
+	server, err := api.NewServer(fss, config)
+	require.NoError(t, err)
+
+	srv := httptest.NewServer(server)
+	t.Cleanup(srv.Close)
+
+	path := "/apis/test.cup.flipt.io/v1alpha1/namespaces/default/resources/foo"
+	resp, err := http.Get(srv.URL + path)
+	require.NoError(t, err)
+
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var resource *core.Resource
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&resource))
+
+	assert.Equal(t, "foo", resource.Metadata.Name)
+}
+
 func Test_Server_List(t *testing.T) {
 	fss := mem.New()
 	fss.AddFS("main", osfs.New("testdata"))
@@ -198,6 +288,130 @@ func Test_Server_List(t *testing.T) {
 	}, resources)
 }
 
+func Test_Server_List_LabelSelector(t *testing.T) {
+	fss := mem.New()
+	fss.AddFS("main", osfs.New("testdata"))
+
+	cntrl := template.New()
+	config := config(t, cntrl)
+
+	server, err := api.NewServer(fss, config)
+	require.NoError(t, err)
+
+	srv := httptest.NewServer(server)
+	t.Cleanup(srv.Close)
+
+	for selector, expected := range map[string][]string{
+		"baz=bar":           {"bar"},
+		"bar":               {"foo"},
+		"!bar":              {"bar"},
+		"baz notin (bar)":   {"foo"},
+		"bar in (baz,qux)":  {"foo"},
+		"bar!=baz,baz!=bar": nil,
+	} {
+		t.Run(selector, func(t *testing.T) {
+			path := "/apis/test.cup.flipt.io/v1alpha1/namespaces/default/resources?labelSelector=" + url.QueryEscape(selector)
+			resp, err := http.Get(srv.URL + path)
+			require.NoError(t, err)
+
+			defer resp.Body.Close()
+
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+
+			resources, err := encoding.DecodeAll[core.Resource](encoding.NewJSONDecoder[core.Resource](resp.Body))
+			require.NoError(t, err)
+
+			var names []string
+			for _, r := range resources {
+				names = append(names, r.Metadata.Name)
+			}
+
+			assert.Equal(t, expected, names)
+		})
+	}
+
+	t.Run("invalid selector", func(t *testing.T) {
+		path := "/apis/test.cup.flipt.io/v1alpha1/namespaces/default/resources?labelSelector=" + url.QueryEscape("bar in (baz")
+		resp, err := http.Get(srv.URL + path)
+		require.NoError(t, err)
+
+		defer resp.Body.Close()
+
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+func Test_Server_List_Watch(t *testing.T) {
+	fss := mem.New()
+	fss.AddFS("main", osfs.New("testdata"))
+
+	cntrl := template.New()
+	config := config(t, cntrl)
+
+	server, err := api.NewServer(fss, config)
+	require.NoError(t, err)
+
+	srv := httptest.NewServer(server)
+	t.Cleanup(srv.Close)
+
+	path := "/apis/test.cup.flipt.io/v1alpha1/namespaces/default/resources?watch=true"
+	resp, err := http.Get(srv.URL + path)
+	require.NoError(t, err)
+
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	decoder := encoding.NewJSONDecoder[api.Event](resp.Body)
+	next := func() *api.Event {
+		t.Helper()
+
+		ev, err := decoder.Decode()
+		require.NoError(t, err)
+		return ev
+	}
+
+	for _, name := range []string{"bar", "foo"} {
+		ev := next()
+		assert.Equal(t, api.EventTypeAdded, ev.Type)
+		assert.Equal(t, name, ev.Resource.Metadata.Name)
+	}
+
+	// update foo, remove bar and add baz
+	fs := memfs.New()
+	for name, payload := range map[string]string{
+		"default/test.cup.flipt.io-v1alpha1-Resource-foo.json": fooModifiedPayload,
+		"default/test.cup.flipt.io-v1alpha1-Resource-baz.json": bazPayload,
+	} {
+		fi, err := fs.Create(name)
+		require.NoError(t, err)
+
+		_, err = io.Copy(fi, strings.NewReader(payload))
+		require.NoError(t, err)
+		require.NoError(t, fi.Close())
+	}
+
+	fss.AddFS("main", fs)
+
+	var (
+		ev    = next()
+		types = map[string]api.EventType{}
+	)
+
+	assert.Equal(t, api.EventTypeDeleted, ev.Type)
+	assert.Equal(t, "bar", ev.Resource.Metadata.Name)
+
+	for i := 0; i < 2; i++ {
+		ev := next()
+		types[ev.Resource.Metadata.Name] = ev.Type
+	}
+
+	assert.Equal(t, map[string]api.EventType{
+		"baz": api.EventTypeAdded,
+		"foo": api.EventTypeModified,
+	}, types)
+}
+
 func Test_Server_Put(t *testing.T) {
 	fs := memfs.New()
 	fss := mem.New()
@@ -245,64 +459,1199 @@ func Test_Server_Put(t *testing.T) {
 	assert.Equal(t, bazPayload, string(data))
 }
 
-func Test_Server_Delete(t *testing.T) {
-	var (
-		fs      = memfs.New()
-		fsPath  = "default/test.cup.flipt.io-v1alpha1-Resource-baz.json"
-		fi, err = fs.Create(fsPath)
-	)
+func Test_Server_Put_DryRun_Unsupported(t *testing.T) {
+	fss := mem.New()
+	fss.AddFS("main", memfs.New())
+
+	server, err := api.NewServer(fss, config(t, template.New()))
 	require.NoError(t, err)
 
-	io.Copy(fi, strings.NewReader(bazPayload))
-	_ = fi.Close()
+	srv := httptest.NewServer(server)
+	t.Cleanup(srv.Close)
+
+	path := "/apis/test.cup.flipt.io/v1alpha1/namespaces/default/resources/baz?dryRun=true"
+	req, err := http.NewRequest("PUT", srv.URL+path, strings.NewReader(bazPayload))
+	require.NoError(t, err)
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusNotImplemented, resp.StatusCode)
+}
+
+func Test_Server_Put_Status(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		result   api.Result
+		expected int
+	}{
+		{name: "proposed", result: api.Result{Proposal: &api.Proposal{Source: "mem"}}, expected: http.StatusAccepted},
+		{name: "applied", result: api.Result{Commit: "abc", Applied: true}, expected: http.StatusOK},
+		{name: "dry run", result: api.Result{Diff: "diff", DryRun: true}, expected: http.StatusOK},
+		{name: "empty", result: api.Result{Empty: true}, expected: http.StatusNoContent},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			fss := mem.New()
+			fss.AddFS("main", memfs.New())
+
+			server, err := api.NewServer(resultSource{fss, &test.result}, config(t, template.New()))
+			require.NoError(t, err)
+
+			srv := httptest.NewServer(server)
+			t.Cleanup(srv.Close)
+
+			path := "/apis/test.cup.flipt.io/v1alpha1/namespaces/default/resources/baz"
+			req, err := http.NewRequest("PUT", srv.URL+path, strings.NewReader(bazPayload))
+			require.NoError(t, err)
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+
+			defer resp.Body.Close()
+
+			assert.Equal(t, test.expected, resp.StatusCode)
+		})
+	}
+}
+
+func Test_Server_Put_ResourceVersion(t *testing.T) {
+	fooPath := "default/test.cup.flipt.io-v1alpha1-Resource-foo.json"
+
+	// main and unrelated contain the same version of foo
+	// whereas stale contains the original version from testdata
+	main, unrelated := memfs.New(), memfs.New()
+	for _, f := range []billy.Filesystem{main, unrelated} {
+		fi, err := f.Create(fooPath)
+		require.NoError(t, err)
+
+		_, err = io.Copy(fi, strings.NewReader(fooModifiedPayload))
+		require.NoError(t, err)
+		require.NoError(t, fi.Close())
+	}
 
 	fss := mem.New()
-	fss.AddFS("main", fs)
+	fss.AddFS("main", main)
+	fss.AddFS("unrelated", unrelated)
+	fss.AddFS("stale", osfs.New("testdata"))
 
-	cntrl := template.New()
-	config := config(t, cntrl)
+	server, err := api.NewServer(versionedSource{fss}, config(t, template.New()))
+	require.NoError(t, err)
 
-	server, err := api.NewServer(fss, config)
+	srv := httptest.NewServer(server)
+	t.Cleanup(srv.Close)
+
+	path := srv.URL + "/apis/test.cup.flipt.io/v1alpha1/namespaces/default/resources/foo"
+
+	t.Run("get returns the resource version", func(t *testing.T) {
+		resp, err := http.Get(path)
+		require.NoError(t, err)
+
+		defer resp.Body.Close()
+
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, `"main"`, resp.Header.Get("ETag"))
+
+		var resource *core.Resource
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&resource))
+		assert.Equal(t, "main", resource.Metadata.ResourceVersion)
+	})
+
+	for _, test := range []struct {
+		name            string
+		ifMatch         string
+		resourceVersion string
+		expected        int
+	}{
+		{name: "current version via If-Match", ifMatch: `"main"`, expected: http.StatusAccepted},
+		{name: "current version via metadata", resourceVersion: "main", expected: http.StatusAccepted},
+		{name: "resource unchanged since version", ifMatch: `"unrelated"`, expected: http.StatusAccepted},
+		{name: "resource changed since version", resourceVersion: "stale", expected: http.StatusConflict},
+		{name: "unknown version", ifMatch: `"unknown"`, expected: http.StatusConflict},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var resource core.Resource
+			require.NoError(t, json.Unmarshal([]byte(fooModifiedPayload), &resource))
+			resource.Metadata.ResourceVersion = test.resourceVersion
+
+			body, err := json.Marshal(resource)
+			require.NoError(t, err)
+
+			req, err := http.NewRequest("PUT", path, bytes.NewReader(body))
+			require.NoError(t, err)
+
+			if test.ifMatch != "" {
+				req.Header.Set("If-Match", test.ifMatch)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+
+			defer resp.Body.Close()
+
+			assert.Equal(t, test.expected, resp.StatusCode)
+		})
+	}
+
+	t.Run("resource version is not persisted", func(t *testing.T) {
+		fi, err := main.Open(fooPath)
+		require.NoError(t, err)
+
+		defer fi.Close()
+
+		data, err := io.ReadAll(fi)
+		require.NoError(t, err)
+		assert.NotContains(t, string(data), "resourceVersion")
+	})
+}
+
+func Test_Server_Proposals(t *testing.T) {
+	var (
+		ctx = context.Background()
+		scm = memscm.New()
+		src = proposalSource{Source: mem.New(), scm: scm}
+	)
+
+	var ids []ulid.ULID
+	for i := 0; i < 2; i++ {
+		id := ulid.Make()
+		_, err := scm.Propose(ctx, git.Proposal{ID: id, Head: git.ProposalBranch(id), Base: "main"})
+		require.NoError(t, err)
+
+		ids = append(ids, id)
+	}
+
+	server, err := api.NewServer(src, config(t, template.New()))
 	require.NoError(t, err)
 
 	srv := httptest.NewServer(server)
 	t.Cleanup(srv.Close)
 
-	path := "/apis/test.cup.flipt.io/v1alpha1/namespaces/default/resources/baz"
-	req, err := http.NewRequest("DELETE", srv.URL+path, nil)
+	do := func(t *testing.T, method, path string) *http.Response {
+		t.Helper()
+
+		req, err := http.NewRequest(method, srv.URL+path, nil)
+		require.NoError(t, err)
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+
+		t.Cleanup(func() { resp.Body.Close() })
+
+		return resp
+	}
+
+	t.Run("list open proposals", func(t *testing.T) {
+		resp := do(t, http.MethodGet, "/proposals")
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		proposals, err := encoding.DecodeAll[api.Proposal](encoding.NewJSONDecoder[api.Proposal](resp.Body))
+		require.NoError(t, err)
+
+		var found []ulid.ULID
+		for _, p := range proposals {
+			found = append(found, p.ID)
+		}
+
+		assert.ElementsMatch(t, ids, found)
+	})
+
+	t.Run("merge proposal", func(t *testing.T) {
+		resp := do(t, http.MethodPost, "/proposals/"+ids[0].String()+"/merge")
+		require.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+		resp = do(t, http.MethodGet, "/proposals/"+ids[0].String())
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var proposal api.Proposal
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&proposal))
+		assert.Equal(t, api.ProposalStatusMerged, proposal.Status)
+	})
+
+	t.Run("close proposal", func(t *testing.T) {
+		resp := do(t, http.MethodPost, "/proposals/"+ids[1].String()+"/close")
+		require.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+		resp = do(t, http.MethodGet, "/proposals/"+ids[1].String())
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var proposal api.Proposal
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&proposal))
+		assert.Equal(t, api.ProposalStatusClosed, proposal.Status)
+	})
+
+	t.Run("unknown proposal", func(t *testing.T) {
+		resp := do(t, http.MethodGet, "/proposals/"+ulid.Make().String())
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+
+	t.Run("invalid proposal id", func(t *testing.T) {
+		resp := do(t, http.MethodPost, "/proposals/invalid/merge")
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+func Test_Server_Put_Proposal(t *testing.T) {
+	var (
+		ctx = context.Background()
+		scm = memscm.New()
+		src = &recordingSource{proposalSource: proposalSource{Source: mem.New(), scm: scm}}
+		id  = ulid.Make()
+	)
+
+	src.AddFS("main", memfs.New())
+	src.AddFS(git.ProposalBranch(id), memfs.New())
+
+	_, err := scm.Propose(ctx, git.Proposal{ID: id, Head: git.ProposalBranch(id), Base: "main"})
 	require.NoError(t, err)
 
-	resp, err := http.DefaultClient.Do(req)
+	server, err := api.NewServer(src, config(t, template.New()))
 	require.NoError(t, err)
 
-	defer resp.Body.Close()
+	srv := httptest.NewServer(server)
+	t.Cleanup(srv.Close)
 
-	if !assert.Equal(t, http.StatusAccepted, resp.StatusCode) {
-		data, err := io.ReadAll(resp.Body)
+	put := func(t *testing.T, proposal string) *http.Response {
+		t.Helper()
+
+		path := "/apis/test.cup.flipt.io/v1alpha1/namespaces/default/resources/baz?proposal=" + proposal
+		req, err := http.NewRequest(http.MethodPut, srv.URL+path, strings.NewReader(bazPayload))
 		require.NoError(t, err)
-		t.Log(string(data))
-		t.FailNow()
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+
+		t.Cleanup(func() { resp.Body.Close() })
+
+		return resp
 	}
 
-	var result api.Result
-	require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
-	assert.Equal(t, &api.Result{}, &result)
+	t.Run("amends existing proposal", func(t *testing.T) {
+		resp := put(t, id.String())
+		require.Equal(t, http.StatusAccepted, resp.StatusCode)
 
-	_, err = fs.Open(fsPath)
-	require.ErrorIs(t, err, os.ErrNotExist)
-}
+		require.Len(t, src.options, 1)
+		assert.Equal(t, id, src.options[0].Proposal)
+	})
 
-const bazPayload = `{
-  "apiVersion": "test.cup.flipt.io/v1alpha1",
-  "kind": "Resource",
-  "metadata": {
-    "namespace": "default",
-    "name": "baz",
-    "labels": {
-      "foo": "bar"
-    },
-    "annotations": {}
-  },
-  "spec": {}
+	t.Run("invalid proposal id", func(t *testing.T) {
+		resp := put(t, "invalid")
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("unknown proposal", func(t *testing.T) {
+		resp := put(t, ulid.Make().String())
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+}
+
+func Test_Server_Put_Caller(t *testing.T) {
+	src := &recordingSource{proposalSource: proposalSource{Source: mem.New(), scm: memscm.New()}}
+	src.AddFS("main", memfs.New())
+
+	cfg := config(t, template.New())
+	cfg.TailscaleClient = whoIsClient{&apitype.WhoIsResponse{
+		UserProfile: &tailcfg.UserProfile{
+			LoginName:   "jane@flipt.io",
+			DisplayName: "Jane Doe",
+		},
+	}}
+
+	server, err := api.NewServer(src, cfg)
+	require.NoError(t, err)
+
+	srv := httptest.NewServer(server)
+	t.Cleanup(srv.Close)
+
+	path := "/apis/test.cup.flipt.io/v1alpha1/namespaces/default/resources/baz"
+	req, err := http.NewRequest(http.MethodPut, srv.URL+path, strings.NewReader(bazPayload))
+	require.NoError(t, err)
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { resp.Body.Close() })
+
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+	assert.Equal(t, []*api.Caller{{Name: "Jane Doe", Email: "jane@flipt.io"}}, src.callers)
+}
+
+func Test_Server_Put_Templates(t *testing.T) {
+	templates := core.Templates{
+		Branch:  "cup/proposal/{{ .Resource.Name }}-{{ .ID }}",
+		Message: "feat({{ .Resource.Name }}): {{ .Operation }} {{ .Resource.Kind | lower }}",
+	}
+
+	src := &recordingSource{proposalSource: proposalSource{Source: mem.New(), scm: memscm.New()}}
+	src.AddFS("main", memfs.New())
+
+	cfg := config(t, template.New())
+	cfg.Bindings["test"].Spec.Templates = templates
+
+	server, err := api.NewServer(src, cfg)
+	require.NoError(t, err)
+
+	srv := httptest.NewServer(server)
+	t.Cleanup(srv.Close)
+
+	path := "/apis/test.cup.flipt.io/v1alpha1/namespaces/default/resources/baz"
+	req, err := http.NewRequest(http.MethodPut, srv.URL+path, strings.NewReader(bazPayload))
+	require.NoError(t, err)
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { resp.Body.Close() })
+
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+	require.Len(t, src.options, 1)
+	assert.Equal(t, templates, src.options[0].Templates)
+
+	require.Len(t, src.options[0].Changes, 1)
+	change := src.options[0].Changes[0]
+	assert.Equal(t, api.OperationTypePut, change.Operation)
+	assert.Equal(t, "test.cup.flipt.io/v1alpha1/Resource/default/baz", change.String())
+	require.NotNil(t, change.Resource)
+	assert.Equal(t, "baz", change.Resource.Metadata.Name)
+
+	data := api.NewTemplateData(context.Background(), ulid.Make(), "main", "feat: update", src.options[0].Changes)
+	message, err := api.RenderTemplate("message", templates.Message, data, "")
+	require.NoError(t, err)
+	assert.Equal(t, "feat(baz): put resource", message)
+
+	t.Run("invalid template", func(t *testing.T) {
+		cfg := config(t, template.New())
+		cfg.Bindings["test"].Spec.Templates = core.Templates{Title: "{{ .Resource.Name"}
+
+		_, err := api.NewServer(src, cfg)
+		require.ErrorContains(t, err, "parsing title template")
+	})
+}
+
+func Test_Server_Put_ProposalDetails(t *testing.T) {
+	fs := memfs.New()
+	src := &recordingSource{proposalSource: proposalSource{Source: mem.New(), scm: memscm.New()}}
+	src.AddFS("main", fs)
+
+	cfg := config(t, template.New())
+	cfg.Bindings["test"].Spec.Proposal = core.ProposalDetails{
+		Reviewers: []string{"alice"},
+		Labels:    []string{"flags"},
+	}
+
+	server, err := api.NewServer(src, cfg)
+	require.NoError(t, err)
+
+	srv := httptest.NewServer(server)
+	t.Cleanup(srv.Close)
+
+	put := func(t *testing.T, annotations map[string]string) *http.Response {
+		t.Helper()
+
+		resource := core.Resource{
+			APIVersion: "test.cup.flipt.io/v1alpha1",
+			Kind:       "Resource",
+			Metadata: core.NamespacedMetadata{
+				Namespace:   "default",
+				Name:        "baz",
+				Annotations: annotations,
+			},
+			Spec: json.RawMessage("{}"),
+		}
+
+		data, err := json.Marshal(resource)
+		require.NoError(t, err)
+
+		path := "/apis/test.cup.flipt.io/v1alpha1/namespaces/default/resources/baz"
+		req, err := http.NewRequest(http.MethodPut, srv.URL+path, bytes.NewReader(data))
+		require.NoError(t, err)
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		t.Cleanup(func() { resp.Body.Close() })
+
+		return resp
+	}
+
+	resp := put(t, map[string]string{
+		"owner":                     "flags-team",
+		api.AnnotationReviewers:     "bob, alice",
+		api.AnnotationTeamReviewers: "flipt-io/flags",
+		api.AnnotationLabels:        "urgent",
+		api.AnnotationAssignees:     "carol",
+		api.AnnotationMilestone:     "v1.0",
+		api.AnnotationDraft:         "true",
+	})
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+
+	require.Len(t, src.options, 1)
+	assert.Equal(t, core.ProposalDetails{
+		Reviewers:     []string{"alice", "bob"},
+		TeamReviewers: []string{"flipt-io/flags"},
+		Labels:        []string{"flags", "urgent"},
+		Assignees:     []string{"carol"},
+		Milestone:     "v1.0",
+		Draft:         true,
+	}, src.options[0].ProposalDetails)
+
+	// the binding is left untouched
+	assert.Equal(t, []string{"alice"}, cfg.Bindings["test"].Spec.Proposal.Reviewers)
+
+	// the proposal annotations are not persisted with the resource
+	fi, err := fs.Open("default/test.cup.flipt.io-v1alpha1-Resource-baz.json")
+	require.NoError(t, err)
+	t.Cleanup(func() { fi.Close() })
+
+	var stored core.Resource
+	require.NoError(t, json.NewDecoder(fi).Decode(&stored))
+	assert.Equal(t, map[string]string{"owner": "flags-team"}, stored.Metadata.Annotations)
+
+	t.Run("invalid draft", func(t *testing.T) {
+		resp := put(t, map[string]string{api.AnnotationDraft: "maybe"})
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+func Test_Server_Put_AutoMerge(t *testing.T) {
+	src := &recordingSource{proposalSource: proposalSource{Source: mem.New(), scm: memscm.New()}}
+	src.AddFS("main", memfs.New())
+
+	cfg := config(t, template.New())
+	cfg.Bindings["test"].Spec.AutoMerge = true
+
+	server, err := api.NewServer(src, cfg)
+	require.NoError(t, err)
+
+	srv := httptest.NewServer(server)
+	t.Cleanup(srv.Close)
+
+	put := func(t *testing.T, query string) *http.Response {
+		t.Helper()
+
+		data, err := json.Marshal(core.Resource{
+			APIVersion: "test.cup.flipt.io/v1alpha1",
+			Kind:       "Resource",
+			Metadata:   core.NamespacedMetadata{Namespace: "default", Name: "baz"},
+			Spec:       json.RawMessage("{}"),
+		})
+		require.NoError(t, err)
+
+		path := "/apis/test.cup.flipt.io/v1alpha1/namespaces/default/resources/baz" + query
+		req, err := http.NewRequest(http.MethodPut, srv.URL+path, bytes.NewReader(data))
+		require.NoError(t, err)
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		t.Cleanup(func() { resp.Body.Close() })
+
+		return resp
+	}
+
+	// enabled by the binding
+	resp := put(t, "")
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+
+	// disabled by the request
+	resp = put(t, "?autoMerge=false")
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+
+	require.Len(t, src.options, 2)
+	assert.True(t, src.options[0].AutoMerge)
+	assert.False(t, src.options[1].AutoMerge)
+
+	t.Run("invalid autoMerge", func(t *testing.T) {
+		resp := put(t, "?autoMerge=maybe")
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+func Test_Server_Proposals_Unsupported(t *testing.T) {
+	server, err := api.NewServer(mem.New(), config(t, template.New()))
+	require.NoError(t, err)
+
+	srv := httptest.NewServer(server)
+	t.Cleanup(srv.Close)
+
+	resp, err := http.Get(srv.URL + "/proposals")
+	require.NoError(t, err)
+
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusNotImplemented, resp.StatusCode)
+}
+
+func Test_Server_Delete(t *testing.T) {
+	var (
+		fs      = memfs.New()
+		fsPath  = "default/test.cup.flipt.io-v1alpha1-Resource-baz.json"
+		fi, err = fs.Create(fsPath)
+	)
+	require.NoError(t, err)
+
+	io.Copy(fi, strings.NewReader(bazPayload))
+	_ = fi.Close()
+
+	fss := mem.New()
+	fss.AddFS("main", fs)
+
+	cntrl := template.New()
+	config := config(t, cntrl)
+
+	server, err := api.NewServer(fss, config)
+	require.NoError(t, err)
+
+	srv := httptest.NewServer(server)
+	t.Cleanup(srv.Close)
+
+	path := "/apis/test.cup.flipt.io/v1alpha1/namespaces/default/resources/baz"
+	req, err := http.NewRequest("DELETE", srv.URL+path, nil)
+	require.NoError(t, err)
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+
+	defer resp.Body.Close()
+
+	if !assert.Equal(t, http.StatusAccepted, resp.StatusCode) {
+		data, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		t.Log(string(data))
+		t.FailNow()
+	}
+
+	var result api.Result
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+	assert.Equal(t, &api.Result{}, &result)
+
+	_, err = fs.Open(fsPath)
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func Test_Server_Batch(t *testing.T) {
+	var (
+		fs      = memfs.New()
+		bazPath = "default/test.cup.flipt.io-v1alpha1-Resource-baz.json"
+		quxPath = "default/test.cup.flipt.io-v1alpha1-Resource-qux.json"
+		oldPath = "other/test.cup.flipt.io-v1alpha1-Resource-old.json"
+		fi, err = fs.Create(oldPath)
+	)
+	require.NoError(t, err)
+	_ = fi.Close()
+
+	src := &recordingSource{proposalSource: proposalSource{Source: mem.New(), scm: memscm.New()}}
+	src.AddFS("main", fs)
+
+	server, err := api.NewServer(src, config(t, template.New()))
+	require.NoError(t, err)
+
+	srv := httptest.NewServer(server)
+	t.Cleanup(srv.Close)
+
+	resource := func(namespace, name string) *core.Resource {
+		return &core.Resource{
+			APIVersion: "test.cup.flipt.io/v1alpha1",
+			Kind:       "Resource",
+			Metadata:   core.NamespacedMetadata{Namespace: namespace, Name: name},
+			Spec:       json.RawMessage("{}"),
+		}
+	}
+
+	post := func(t *testing.T, batch api.Batch) *http.Response {
+		t.Helper()
+
+		body, err := json.Marshal(batch)
+		require.NoError(t, err)
+
+		resp, err := http.Post(srv.URL+"/apis/batch", "application/json", bytes.NewReader(body))
+		require.NoError(t, err)
+
+		t.Cleanup(func() { resp.Body.Close() })
+
+		return resp
+	}
+
+	t.Run("applies all operations in a single update", func(t *testing.T) {
+		resp := post(t, api.Batch{Operations: []api.Operation{
+			{Type: api.OperationTypePut, Resource: resource("default", "baz")},
+			{Type: api.OperationTypePut, Resource: resource("default", "qux")},
+			{Type: api.OperationTypeDelete, Resource: resource("other", "old")},
+		}})
+
+		if !assert.Equal(t, http.StatusAccepted, resp.StatusCode) {
+			data, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			t.Log(string(data))
+			t.FailNow()
+		}
+
+		require.Len(t, src.options, 1)
+		assert.Equal(t, []string{
+			"test.cup.flipt.io/v1alpha1/Resource/default/baz",
+			"test.cup.flipt.io/v1alpha1/Resource/default/qux",
+			"test.cup.flipt.io/v1alpha1/Resource/other/old",
+		}, src.options[0].Resources)
+
+		for _, path := range []string{bazPath, quxPath} {
+			_, err := fs.Stat(path)
+			require.NoError(t, err, path)
+		}
+
+		_, err := fs.Stat(oldPath)
+		require.ErrorIs(t, err, os.ErrNotExist)
+	})
+
+	t.Run("rejects unknown kinds", func(t *testing.T) {
+		unknown := resource("default", "baz")
+		unknown.Kind = "Unknown"
+
+		resp := post(t, api.Batch{Operations: []api.Operation{
+			{Type: api.OperationTypePut, Resource: resource("default", "baz")},
+			{Type: api.OperationTypePut, Resource: unknown},
+		}})
+
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		assert.Len(t, src.options, 1)
+	})
+
+	t.Run("rejects empty batches", func(t *testing.T) {
+		resp := post(t, api.Batch{})
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+func Test_Server_Webhooks(t *testing.T) {
+	const secret = "s3cr3t"
+
+	src := &refreshSource{Source: mem.New()}
+
+	cfg := config(t, template.New())
+	cfg.WebhookSecret = secret
+
+	server, err := api.NewServer(src, cfg)
+	require.NoError(t, err)
+
+	srv := httptest.NewServer(server)
+	t.Cleanup(srv.Close)
+
+	payload := []byte(`{"ref":"refs/heads/main"}`)
+
+	sign := func(secret string) string {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(payload)
+		return hex.EncodeToString(mac.Sum(nil))
+	}
+
+	for _, test := range []struct {
+		name     string
+		scm      string
+		header   string
+		value    string
+		status   int
+		branches [][]string
+	}{
+		{
+			name:     "github valid signature",
+			scm:      "github",
+			header:   "X-Hub-Signature-256",
+			value:    "sha256=" + sign(secret),
+			status:   http.StatusNoContent,
+			branches: [][]string{{"main"}},
+		},
+		{
+			name:   "github invalid signature",
+			scm:    "github",
+			header: "X-Hub-Signature-256",
+			value:  "sha256=" + sign("other"),
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:     "gitea valid signature",
+			scm:      "gitea",
+			header:   "X-Gitea-Signature",
+			value:    sign(secret),
+			status:   http.StatusNoContent,
+			branches: [][]string{{"main"}},
+		},
+		{
+			name:   "gitea missing signature",
+			scm:    "gitea",
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:     "gitlab valid token",
+			scm:      "gitlab",
+			header:   "X-Gitlab-Token",
+			value:    secret,
+			status:   http.StatusNoContent,
+			branches: [][]string{{"main"}},
+		},
+		{
+			name:   "gitlab invalid token",
+			scm:    "gitlab",
+			header: "X-Gitlab-Token",
+			value:  "other",
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "unsupported scm",
+			scm:    "unknown",
+			status: http.StatusNotFound,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			src.branches = nil
+
+			req, err := http.NewRequest(http.MethodPost, srv.URL+"/webhooks/"+test.scm, bytes.NewReader(payload))
+			require.NoError(t, err)
+
+			if test.header != "" {
+				req.Header.Set(test.header, test.value)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+
+			defer resp.Body.Close()
+
+			assert.Equal(t, test.status, resp.StatusCode)
+			assert.Equal(t, test.branches, src.branches)
+		})
+	}
+}
+
+func Test_Server_Webhooks_Disabled(t *testing.T) {
+	server, err := api.NewServer(&refreshSource{Source: mem.New()}, config(t, template.New()))
+	require.NoError(t, err)
+
+	srv := httptest.NewServer(server)
+	t.Cleanup(srv.Close)
+
+	resp, err := http.Post(srv.URL+"/webhooks/github", "application/json", strings.NewReader(`{}`))
+	require.NoError(t, err)
+
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func Test_Server_Sources(t *testing.T) {
+	var (
+		ctx      = context.Background()
+		scm      = memscm.New()
+		services = memfs.New()
+		flags    = mem.New()
+		cfg      = config(t, template.New())
+	)
+
+	flags.AddFS("main", osfs.New("testdata"))
+
+	// the services source supports proposals and serves its bindings from production
+	servicesSrc := mem.New()
+	servicesSrc.AddFS("production", services)
+
+	cfg.Sources = containers.MapStore[string, *api.NamedSource]{
+		"services": {
+			Source:   proposalSource{Source: servicesSrc, scm: scm},
+			Revision: "production",
+		},
+	}
+
+	cfg.Definitions["test.cup.flipt.io/v1alpha1/services"] = &core.ResourceDefinition{
+		Names: core.Names{Kind: "Service", Singular: "service", Plural: "services"},
+		Spec: core.ResourceDefinitionSpec{
+			Group:    "test.cup.flipt.io",
+			Versions: testDef.Spec.Versions,
+		},
+	}
+
+	cfg.Bindings["services"] = &core.Binding{
+		Spec: core.BindingSpec{
+			Controller: "test",
+			Source:     "services",
+			Resources:  []string{"test.cup.flipt.io/v1alpha1/services"},
+		},
+	}
+
+	id := ulid.Make()
+	_, err := scm.Propose(ctx, git.Proposal{ID: id, Head: git.ProposalBranch(id), Base: "production"})
+	require.NoError(t, err)
+
+	server, err := api.NewServer(flags, cfg)
+	require.NoError(t, err)
+
+	srv := httptest.NewServer(server)
+	t.Cleanup(srv.Close)
+
+	t.Run("list sources", func(t *testing.T) {
+		resp, err := http.Get(srv.URL + "/apis/sources")
+		require.NoError(t, err)
+
+		defer resp.Body.Close()
+
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		sources, err := encoding.DecodeAll[api.SourceInfo](encoding.NewJSONDecoder[api.SourceInfo](resp.Body))
+		require.NoError(t, err)
+
+		assert.Equal(t, []*api.SourceInfo{
+			{Name: "default", Revision: "main", Bindings: []string{"test"}, Watch: true},
+			{Name: "services", Revision: "production", Bindings: []string{"services"}, Proposals: true, Watch: true},
+		}, sources)
+	})
+
+	t.Run("resources are served from their source", func(t *testing.T) {
+		resp, err := http.Get(srv.URL + "/apis/test.cup.flipt.io/v1alpha1/namespaces/default/resources/foo")
+		require.NoError(t, err)
+
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		payload := strings.NewReader(strings.Replace(bazPayload, `"kind": "Resource"`, `"kind": "Service"`, 1))
+		req, err := http.NewRequest(http.MethodPut, srv.URL+"/apis/test.cup.flipt.io/v1alpha1/namespaces/default/services/baz", payload)
+		require.NoError(t, err)
+
+		resp, err = http.DefaultClient.Do(req)
+		require.NoError(t, err)
+
+		defer resp.Body.Close()
+
+		require.Equal(t, http.StatusAccepted, resp.StatusCode)
+
+		_, err = services.Stat("default/test.cup.flipt.io-v1alpha1-Service-baz.json")
+		assert.NoError(t, err)
+	})
+
+	t.Run("proposals are served from their source", func(t *testing.T) {
+		resp, err := http.Get(srv.URL + "/proposals/" + id.String())
+		require.NoError(t, err)
+
+		defer resp.Body.Close()
+
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		resp, err = http.Get(srv.URL + "/proposals?source=unknown")
+		require.NoError(t, err)
+
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("batches cannot span sources", func(t *testing.T) {
+		var batch api.Batch
+		for _, kind := range []string{"Resource", "Service"} {
+			batch.Operations = append(batch.Operations, api.Operation{
+				Type: api.OperationTypeDelete,
+				Resource: &core.Resource{
+					APIVersion: "test.cup.flipt.io/v1alpha1",
+					Kind:       kind,
+					Metadata:   core.NamespacedMetadata{Namespace: "default", Name: "foo"},
+				},
+			})
+		}
+
+		data, err := json.Marshal(batch)
+		require.NoError(t, err)
+
+		resp, err := http.Post(srv.URL+"/apis/batch", "application/json", bytes.NewReader(data))
+		require.NoError(t, err)
+
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("binding targets unknown source", func(t *testing.T) {
+		cfg := config(t, template.New())
+		cfg.Bindings["test"].Spec.Source = "unknown"
+
+		_, err := api.NewServer(flags, cfg)
+		assert.ErrorContains(t, err, `binding "": source`)
+	})
+}
+
+func Test_Server_Reload(t *testing.T) {
+	fss := mem.New()
+	fss.AddFS("main", osfs.New("testdata"))
+
+	server, err := api.NewServer(fss, config(t, template.New()))
+	require.NoError(t, err)
+
+	srv := httptest.NewServer(server)
+	t.Cleanup(srv.Close)
+
+	get := func(t *testing.T, path string) int {
+		t.Helper()
+
+		resp, err := http.Get(srv.URL + path)
+		require.NoError(t, err)
+
+		defer resp.Body.Close()
+
+		return resp.StatusCode
+	}
+
+	const (
+		resources = "/apis/test.cup.flipt.io/v1alpha1/namespaces/default/resources/foo"
+		services  = "/apis/test.cup.flipt.io/v1alpha1/namespaces/default/services"
+	)
+
+	require.Equal(t, http.StatusOK, get(t, resources))
+	require.Equal(t, http.StatusNotFound, get(t, services))
+
+	t.Run("rebinds resources", func(t *testing.T) {
+		cfg := config(t, template.New())
+		cfg.Definitions = containers.MapStore[string, *core.ResourceDefinition]{
+			"test.cup.flipt.io/v1alpha1/services": {
+				Names: core.Names{Kind: "Service", Singular: "service", Plural: "services"},
+				Spec: core.ResourceDefinitionSpec{
+					Group:    "test.cup.flipt.io",
+					Versions: testDef.Spec.Versions,
+				},
+			},
+		}
+		cfg.Bindings["test"].Spec.Resources = []string{"test.cup.flipt.io/v1alpha1/services"}
+
+		require.NoError(t, server.Reload(cfg))
+
+		assert.Equal(t, http.StatusNotFound, get(t, resources))
+		assert.Equal(t, http.StatusOK, get(t, services))
+
+		resp, err := http.Get(srv.URL + "/apis")
+		require.NoError(t, err)
+
+		defer resp.Body.Close()
+
+		var definitions map[string]*core.ResourceDefinition
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&definitions))
+		assert.Contains(t, definitions, "test.cup.flipt.io/v1alpha1/services")
+	})
+
+	t.Run("invalid configuration is rejected", func(t *testing.T) {
+		cfg := config(t, template.New())
+		cfg.Bindings["test"].Spec.Controller = "unknown"
+
+		require.Error(t, server.Reload(cfg))
+
+		// the last good configuration continues to be served
+		assert.Equal(t, http.StatusOK, get(t, services))
+	})
+
+	t.Run("controllers of rejected configuration are closed", func(t *testing.T) {
+		cntl := &closingController{Controller: template.New(), closed: make(chan struct{})}
+
+		cfg := config(t, cntl)
+		cfg.Bindings["test"].Spec.Controller = "unknown"
+
+		require.Error(t, server.Reload(cfg))
+
+		select {
+		case <-cntl.closed:
+		case <-time.After(time.Second):
+			t.Fatal("rejected controller was not closed")
+		}
+	})
+
+	t.Run("replaced controllers are closed", func(t *testing.T) {
+		cntl := &closingController{Controller: template.New(), closed: make(chan struct{})}
+
+		require.NoError(t, server.Reload(config(t, cntl)))
+
+		// controllers retained across reloads remain open
+		require.NoError(t, server.Reload(config(t, cntl)))
+
+		select {
+		case <-cntl.closed:
+			t.Fatal("retained controller was closed")
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		require.NoError(t, server.Reload(config(t, template.New())))
+
+		select {
+		case <-cntl.closed:
+		case <-time.After(time.Second):
+			t.Fatal("replaced controller was not closed")
+		}
+
+		assert.Equal(t, http.StatusOK, get(t, resources))
+	})
+
+	t.Run("replays are discarded before replaced controllers are closed", func(t *testing.T) {
+		cntl := &closingController{Controller: template.New(), closed: make(chan struct{})}
+		src := &replayingSource{Source: fss, closed: cntl.closed, discarded: make(chan bool, 1)}
+
+		server, err := api.NewServer(src, config(t, cntl))
+		require.NoError(t, err)
+
+		require.NoError(t, server.Reload(config(t, template.New())))
+
+		select {
+		case closed := <-src.discarded:
+			assert.False(t, closed, "replays were discarded after the controllers they call were closed")
+		case <-time.After(time.Second):
+			t.Fatal("replays were not discarded")
+		}
+
+		select {
+		case <-cntl.closed:
+		case <-time.After(time.Second):
+			t.Fatal("replaced controller was not closed")
+		}
+	})
+}
+
+// closingController is a controller which records when it is closed.
+type closingController struct {
+	api.Controller
+	closed chan struct{}
+}
+
+func (c *closingController) Close(context.Context) error {
+	close(c.closed)
+	return nil
+}
+
+// replayingSource is a source which records whether the controller was already closed
+// when the updates it retains for replay are discarded.
+type replayingSource struct {
+	*mem.Source
+	closed    chan struct{}
+	discarded chan bool
+}
+
+func (r *replayingSource) DiscardReplays() {
+	select {
+	case <-r.closed:
+		r.discarded <- true
+	default:
+		r.discarded <- false
+	}
+}
+
+const bazPayload = `{
+  "apiVersion": "test.cup.flipt.io/v1alpha1",
+  "kind": "Resource",
+  "metadata": {
+    "namespace": "default",
+    "name": "baz",
+    "labels": {
+      "foo": "bar"
+    },
+    "annotations": {}
+  },
+  "spec": {}
+}
+`
+
+const fooModifiedPayload = `{
+  "apiVersion": "test.cup.flipt.io/v1alpha1",
+  "kind": "Resource",
+  "metadata": {
+    "namespace": "default",
+    "name": "foo",
+    "labels": {
+      "bar": "baz"
+    },
+    "annotations": {}
+  },
+  "spec": {
+    "foo": "bar"
+  }
+}
+`
+
+// versionedSource wraps a mem.Source and reports the name of
+// each revision as the version of the filesystem it serves.
+type versionedSource struct {
+	*mem.Source
+}
+
+func (v versionedSource) View(ctx context.Context, revision string, fn api.ViewFunc) error {
+	return v.Source.View(ctx, revision, func(f fs.FS) error {
+		return fn(versionedFS{f, revision})
+	})
+}
+
+// resultSource wraps a mem.Source and returns the provided result from every update.
+type resultSource struct {
+	*mem.Source
+	result *api.Result
+}
+
+func (r resultSource) Update(ctx context.Context, rev, message string, fn api.UpdateFunc, opts ...containers.Option[api.UpdateOptions]) (*api.Result, error) {
+	if _, err := r.Source.Update(ctx, rev, message, fn, opts...); err != nil {
+		return nil, err
+	}
+
+	return r.result, nil
+}
+
+type versionedFS struct {
+	fs.FS
+	version string
+}
+
+func (v versionedFS) Version() string {
+	return v.version
+}
+
+// proposalSource wraps a mem.Source and manages proposals using an in-memory SCM.
+type proposalSource struct {
+	*mem.Source
+	scm *memscm.SCM
+}
+
+func (p proposalSource) Proposals(ctx context.Context) ([]*api.Proposal, error) {
+	return p.scm.List(ctx)
+}
+
+func (p proposalSource) Proposal(ctx context.Context, id ulid.ULID) (*api.Proposal, error) {
+	return p.scm.Get(ctx, id)
+}
+
+func (p proposalSource) MergeProposal(ctx context.Context, id ulid.ULID) error {
+	return p.scm.Merge(ctx, id)
+}
+
+func (p proposalSource) CloseProposal(ctx context.Context, id ulid.ULID) error {
+	return p.scm.Close(ctx, id)
+}
+
+// recordingSource records the options and caller supplied to each call to Update.
+type recordingSource struct {
+	proposalSource
+	options []api.UpdateOptions
+	callers []*api.Caller
+}
+
+func (r *recordingSource) Update(ctx context.Context, rev, message string, fn api.UpdateFunc, opts ...containers.Option[api.UpdateOptions]) (*api.Result, error) {
+	var options api.UpdateOptions
+	containers.ApplyAll(&options, opts...)
+	r.options = append(r.options, options)
+
+	caller, _ := api.CallerFromContext(ctx)
+	r.callers = append(r.callers, caller)
+
+	return r.proposalSource.Update(ctx, rev, message, fn, opts...)
+}
+
+// whoIsClient identifies every caller as the provided Tailscale user.
+type whoIsClient struct {
+	who *apitype.WhoIsResponse
+}
+
+func (c whoIsClient) WhoIs(context.Context, string) (*apitype.WhoIsResponse, error) {
+	return c.who, nil
+}
+
+// refreshSource records the branches supplied to each call to Refresh.
+type refreshSource struct {
+	*mem.Source
+	branches [][]string
+}
+
+func (r *refreshSource) Refresh(_ context.Context, branches ...string) error {
+	r.branches = append(r.branches, branches)
+	return nil
 }
-`
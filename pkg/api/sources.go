@@ -0,0 +1,87 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+
+	"go.flipt.io/cup/pkg/api/core"
+	"golang.org/x/exp/maps"
+)
+
+// DefaultSource is the name of the source provided to NewServer.
+// Bindings which do not name a source target the default source.
+const DefaultSource = "default"
+
+// NamedSource is a source which bindings target by name.
+type NamedSource struct {
+	Source Source
+	// Revision is the default revision (base branch) of the source.
+	// It can be overridden per binding and defaults to "main" when empty.
+	Revision string
+	// WebhookSecret is used to verify webhooks received from the SCM of the source.
+	// Webhooks are disabled for the source when empty.
+	WebhookSecret string
+}
+
+// SourceName returns the name of the source targeted by the binding.
+func SourceName(binding *core.Binding) string {
+	if binding.Spec.Source != "" {
+		return binding.Spec.Source
+	}
+
+	return DefaultSource
+}
+
+// SourceInfo describes a source served by the API along with the bindings which target it.
+type SourceInfo struct {
+	Name     string `json:"name"`
+	Revision string `json:"revision"`
+	// Bindings are the names of the bindings which target the source.
+	Bindings []string `json:"bindings,omitempty"`
+	// Proposals, Watch and Webhooks report the optional capabilities of the source.
+	Proposals bool `json:"proposals"`
+	Watch     bool `json:"watch"`
+	Webhooks  bool `json:"webhooks"`
+}
+
+// handleSources lists the sources served by the API ordered by name.
+// Sources are streamed as newline-delimited JSON.
+func (s *Server) handleSources(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	bindings := map[string][]string{}
+	for name, binding := range s.cfg.Bindings {
+		source := SourceName(binding)
+		bindings[source] = append(bindings[source], name)
+	}
+
+	names := maps.Keys(s.sources)
+	slices.Sort(names)
+
+	enc := json.NewEncoder(w)
+	for _, name := range names {
+		var (
+			src  = s.sources[name]
+			info = SourceInfo{
+				Name:     name,
+				Revision: src.Revision,
+				Bindings: bindings[name],
+			}
+		)
+
+		slices.Sort(info.Bindings)
+
+		_, info.Proposals = src.Source.(ProposalSource)
+		_, info.Watch = src.Source.(WatchableSource)
+		if _, ok := src.Source.(RefreshableSource); ok {
+			info.Webhooks = src.WebhookSecret != ""
+		}
+
+		if err := enc.Encode(info); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
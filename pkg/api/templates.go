@@ -0,0 +1,187 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/oklog/ulid/v2"
+	"go.flipt.io/cup/pkg/api/core"
+)
+
+// OperationBatch is the operation of template data for changes made by a batch.
+const OperationBatch = "batch"
+
+// Change describes a single operation performed on a resource by an update.
+type Change struct {
+	Operation OperationType
+	Group     string
+	Version   string
+	Kind      string
+	Namespace string
+	Name      string
+	// Resource is the resource being put (it is nil for deletes).
+	Resource *core.Resource
+}
+
+// String returns the identifier of the changed resource
+// in the form <group>/<version>/<kind>/<namespace>/<name>.
+func (c Change) String() string {
+	return path.Join(c.Group, c.Version, c.Kind, c.Namespace, c.Name)
+}
+
+// DiffSummary summarizes the files changed by an update.
+type DiffSummary struct {
+	Added    []string
+	Modified []string
+	Deleted  []string
+}
+
+// Files returns the paths of all the added, modified and deleted files.
+func (d DiffSummary) Files() []string {
+	files := make([]string, 0, len(d.Added)+len(d.Modified)+len(d.Deleted))
+	files = append(files, d.Added...)
+	files = append(files, d.Modified...)
+	return append(files, d.Deleted...)
+}
+
+// String returns the number of files added, modified and deleted (e.g. "1 added, 2 modified").
+func (d DiffSummary) String() string {
+	var parts []string
+	for _, count := range []struct {
+		n    int
+		verb string
+	}{
+		{len(d.Added), "added"},
+		{len(d.Modified), "modified"},
+		{len(d.Deleted), "deleted"},
+	} {
+		if count.n > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", count.n, count.verb))
+		}
+	}
+
+	if len(parts) == 0 {
+		return "no changes"
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// TemplateData is the data available to the templates which describe a change.
+type TemplateData struct {
+	// ID identifies the proposal (or direct change).
+	ID string
+	// Operation is the operation of the change ("put" or "delete")
+	// or "batch" when the change is made up of several operations.
+	Operation string
+	// Resource is the first (and often only) change made to a resource.
+	Resource Change
+	// Changes are all the changes made to resources.
+	Changes []Change
+	// Caller is the user on whose behalf the change is made (if any).
+	Caller *Caller
+	// Base is the revision the change is made against.
+	Base string
+	// Message is the default commit message for the change.
+	Message string
+	// Diff summarizes the files changed.
+	Diff DiffSummary
+}
+
+// NewTemplateData returns the template data for the change identified by id
+// made against base on behalf of the caller in ctx (if any).
+func NewTemplateData(ctx context.Context, id ulid.ULID, base, message string, changes []Change) *TemplateData {
+	data := &TemplateData{
+		ID:      id.String(),
+		Changes: changes,
+		Base:    base,
+		Message: message,
+	}
+
+	data.Caller, _ = CallerFromContext(ctx)
+
+	switch len(changes) {
+	case 0:
+	case 1:
+		data.Operation = string(changes[0].Operation)
+		data.Resource = changes[0]
+	default:
+		data.Operation = OperationBatch
+		data.Resource = changes[0]
+	}
+
+	return data
+}
+
+var templateFuncs = template.FuncMap{
+	"lower":   strings.ToLower,
+	"upper":   strings.ToUpper,
+	"trim":    strings.TrimSpace,
+	"replace": strings.ReplaceAll,
+	"join":    strings.Join,
+	"slug":    slug,
+}
+
+// ParseTemplates returns an error when any of the provided templates fails to parse.
+func ParseTemplates(t core.Templates) error {
+	for name, text := range map[string]string{
+		"branch":  t.Branch,
+		"message": t.Message,
+		"title":   t.Title,
+		"body":    t.Body,
+	} {
+		if _, err := template.New(name).Funcs(templateFuncs).Parse(text); err != nil {
+			return fmt.Errorf("parsing %s template: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// RenderTemplate executes the provided template text with data.
+// It returns def when the template is empty.
+func RenderTemplate(name, text string, data *TemplateData, def string) (string, error) {
+	if text == "" {
+		return def, nil
+	}
+
+	tmpl, err := template.New(name).Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("parsing %s template: %w", name, err)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := tmpl.Execute(buf, data); err != nil {
+		return "", fmt.Errorf("executing %s template: %w", name, err)
+	}
+
+	return buf.String(), nil
+}
+
+// slug lowercases v and replaces each run of characters which are not letters or digits with "-".
+func slug(v string) string {
+	var (
+		b    strings.Builder
+		dash bool
+	)
+
+	for _, r := range strings.ToLower(v) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+			dash = false
+			continue
+		}
+
+		if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+
+	return strings.TrimSuffix(b.String(), "-")
+}
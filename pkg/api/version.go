@@ -0,0 +1,123 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"strings"
+
+	"go.flipt.io/cup/pkg/api/core"
+	"go.flipt.io/cup/pkg/controllers"
+)
+
+// errConflict is returned when a write is made against a stale resourceVersion.
+var errConflict = errors.New("conflict")
+
+// VersionedFS is an optional interface which can be implemented by the fs.FS
+// instances provided to a ViewFunc.
+// The version identifies the state of the source the FS was derived from
+// (e.g. a Git commit hash) and can itself be used as a revision in calls to View.
+type VersionedFS interface {
+	fs.FS
+	Version() string
+}
+
+type getFunc func(context.Context, fs.FS) (*core.Resource, error)
+
+// fsVersion returns the version of the provided FS or the empty string
+// when the FS is not versioned.
+func fsVersion(f fs.FS) string {
+	if v, ok := f.(VersionedFS); ok {
+		return v.Version()
+	}
+
+	return ""
+}
+
+// ifMatch returns the entity tag supplied via the If-Match header (if any).
+func ifMatch(r *http.Request) string {
+	tag := strings.TrimPrefix(r.Header.Get("If-Match"), "W/")
+	return strings.Trim(tag, `"`)
+}
+
+// Precondition requires that a resource is unchanged since the version it was read at,
+// so that an update does not overwrite changes made to the resource since.
+type Precondition struct {
+	// Version is the version (see VersionedFS) the resource was read at, such as its resourceVersion.
+	Version string
+	// Get returns the resource from the provided FS.
+	Get func(context.Context, fs.FS) (*core.Resource, error)
+}
+
+// CheckPreconditions ensures each of the preconditions holds for the state of rev within src.
+// Sources call it with the revision (e.g. the commit hash) each attempt at an update is applied to,
+// so that the check and the update are made against the same state.
+// It returns an error wrapping errConflict (which is served as 409 Conflict) when a precondition fails.
+func CheckPreconditions(ctx context.Context, src Source, rev string, preconditions []Precondition) error {
+	for _, p := range preconditions {
+		if p.Version == "" {
+			continue
+		}
+
+		if err := checkVersion(ctx, src, rev, p.Version, p.Get); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkVersion ensures the resource returned by get is unchanged between the
+// expected version and the current state of rev within src.
+// Changes to other resources between the two versions do not produce a conflict.
+func checkVersion(ctx context.Context, src Source, rev, expected string, get getFunc) error {
+	var (
+		current *core.Resource
+		match   bool
+	)
+
+	if err := src.View(ctx, rev, func(f fs.FS) (err error) {
+		if match = fsVersion(f) == expected; match {
+			return nil
+		}
+
+		current, err = getOrNil(ctx, f, get)
+		return err
+	}); err != nil {
+		return err
+	}
+
+	if match {
+		return nil
+	}
+
+	var previous *core.Resource
+	if err := src.View(ctx, expected, func(f fs.FS) (err error) {
+		previous, err = getOrNil(ctx, f, get)
+		return err
+	}); err != nil {
+		return fmt.Errorf("%w: resolving resourceVersion %q: %v", errConflict, expected, err)
+	}
+
+	if !equal(previous, current) {
+		return fmt.Errorf("%w: resource has been modified since resourceVersion %q", errConflict, expected)
+	}
+
+	return nil
+}
+
+// getOrNil calls get and adapts any not found errors into a nil resource.
+func getOrNil(ctx context.Context, f fs.FS, get getFunc) (*core.Resource, error) {
+	resource, err := get(ctx, f)
+	if err != nil {
+		if errors.Is(err, controllers.ErrNotFound) || errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return resource, nil
+}
@@ -0,0 +1,192 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"path"
+
+	"go.flipt.io/cup/pkg/api/core"
+)
+
+// EventType identifies the kind of change described by an Event.
+type EventType string
+
+const (
+	// EventTypeAdded signifies a resource which did not previously exist.
+	EventTypeAdded = EventType("ADDED")
+	// EventTypeModified signifies a resource which existed and has changed.
+	EventTypeModified = EventType("MODIFIED")
+	// EventTypeDeleted signifies a resource which existed and has been removed.
+	EventTypeDeleted = EventType("DELETED")
+)
+
+// Event is a single change to a resource observed while watching a revision.
+// Events are streamed as newline-delimited JSON on list requests made with
+// the watch query parameter.
+type Event struct {
+	Type     EventType      `json:"type"`
+	Resource *core.Resource `json:"resource"`
+}
+
+// WatchableSource is an optional extension of Source.
+// Implementations notify subscribers whenever the state of a revision changes.
+type WatchableSource interface {
+	Source
+	// Subscribe registers ch to be signalled each time the provided revision is
+	// observed to have moved. The subscription is removed once the context is done.
+	// Implementations must not block when signalling ch.
+	Subscribe(_ context.Context, revision string, ch chan<- struct{})
+}
+
+type listFunc func(context.Context, fs.FS) ([]*core.Resource, error)
+
+// watch streams events to the client derived from the difference between
+// successive calls to list each time src notifies that rev has moved.
+// It starts by emitting an ADDED event for every resource which currently exists.
+func (s *Server) watch(w http.ResponseWriter, r *http.Request, src Source, rev string, list listFunc) {
+	watchable, ok := src.(WatchableSource)
+	if !ok {
+		http.Error(w, "source does not support watch", http.StatusNotImplemented)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	ctx := r.Context()
+
+	// subscribe before taking the initial snapshot so that
+	// no updates are missed in-between
+	ch := make(chan struct{}, 1)
+	watchable.Subscribe(ctx, rev, ch)
+
+	snapshot := func() (resources []*core.Resource, err error) {
+		err = src.View(ctx, rev, func(f fs.FS) error {
+			resources, err = list(ctx, f)
+			return err
+		})
+		return
+	}
+
+	last, err := snapshot()
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+
+	enc := json.NewEncoder(w)
+	emit := func(events []Event) bool {
+		for i := range events {
+			if err := enc.Encode(&events[i]); err != nil {
+				slog.Debug("Writing watch event", "error", err)
+				return false
+			}
+		}
+
+		flusher.Flush()
+
+		return true
+	}
+
+	if !emit(diff(nil, last)) {
+		return
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ch:
+			next, err := snapshot()
+			if err != nil {
+				slog.Error("Listing resources for watch", "revision", rev, "error", err)
+				continue
+			}
+
+			if !emit(diff(last, next)) {
+				return
+			}
+
+			last = next
+		}
+	}
+}
+
+// diff compares two lists of resources and returns the events required
+// to transition from prev to next.
+// Deletions are emitted first, followed by additions and modifications
+// in the order they appear in next.
+func diff(prev, next []*core.Resource) (events []Event) {
+	var (
+		key = func(r *core.Resource) string {
+			return path.Join(r.Metadata.Namespace, r.Metadata.Name)
+		}
+		previous = map[string]*core.Resource{}
+		seen     = map[string]struct{}{}
+	)
+
+	for _, r := range prev {
+		previous[key(r)] = r
+	}
+
+	for _, r := range next {
+		seen[key(r)] = struct{}{}
+	}
+
+	for _, r := range prev {
+		if _, ok := seen[key(r)]; !ok {
+			events = append(events, Event{Type: EventTypeDeleted, Resource: r})
+		}
+	}
+
+	for _, r := range next {
+		p, ok := previous[key(r)]
+		if !ok {
+			events = append(events, Event{Type: EventTypeAdded, Resource: r})
+			continue
+		}
+
+		if !equal(p, r) {
+			events = append(events, Event{Type: EventTypeModified, Resource: r})
+		}
+	}
+
+	return
+}
+
+// equal compares two resources ignoring their resourceVersion,
+// which changes whenever any resource in the revision changes.
+func equal(a, b *core.Resource) bool {
+	a, b = unversioned(a), unversioned(b)
+
+	ab, err := json.Marshal(a)
+	if err != nil {
+		return false
+	}
+
+	bb, err := json.Marshal(b)
+	if err != nil {
+		return false
+	}
+
+	return bytes.Equal(ab, bb)
+}
+
+func unversioned(r *core.Resource) *core.Resource {
+	if r == nil || r.Metadata.ResourceVersion == "" {
+		return r
+	}
+
+	c := *r
+	c.Metadata.ResourceVersion = ""
+	return &c
+}
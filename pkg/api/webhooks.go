@@ -0,0 +1,153 @@
+package api
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// errInvalidSignature is returned when a webhook cannot be verified using the configured secret.
+var errInvalidSignature = errors.New("invalid signature")
+
+// RefreshableSource is an optional extension of Source.
+// Implementations can be asked to immediately observe changes made upstream,
+// for example, when notified of a push by an SCM webhook.
+type RefreshableSource interface {
+	Source
+	// Refresh fetches the latest state of the provided branches from upstream.
+	// All branches are refreshed when none are provided.
+	Refresh(_ context.Context, branches ...string) error
+}
+
+// verifiers are the signature verification schemes for each supported webhook SCM.
+var verifiers = map[string]func(r *http.Request, secret string, body []byte) error{
+	"github": verifyHMAC("X-Hub-Signature-256", "sha256="),
+	"gitea":  verifyHMAC("X-Gitea-Signature", ""),
+	"gitlab": verifyToken("X-Gitlab-Token"),
+}
+
+// registerWebhooks adds the routes which receive SCM webhooks.
+// Webhooks for the default source are received on /webhooks/{scm}
+// and those for named sources on /webhooks/{scm}/{source}.
+// Webhooks are only enabled for sources with a secret configured to verify them.
+func (s *Server) registerWebhooks(mux chi.Router) {
+	mux.Post("/webhooks/{scm}", s.handleWebhook)
+	mux.Post("/webhooks/{scm}/{source}", s.handleWebhook)
+}
+
+// handleWebhook verifies the signature of the webhook and refreshes the source.
+// Push events refresh the pushed branch, all other events refresh every branch.
+func (s *Server) handleWebhook(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParamFromCtx(r.Context(), "source")
+	if name == "" {
+		name = DefaultSource
+	}
+
+	s.mu.RLock()
+	source, ok := s.sources[name]
+	s.mu.RUnlock()
+
+	if !ok || source.WebhookSecret == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	scm := chi.URLParamFromCtx(r.Context(), "scm")
+	verify, ok := verifiers[scm]
+	if !ok {
+		http.Error(w, fmt.Sprintf("scm not supported: %q", scm), http.StatusNotFound)
+		return
+	}
+
+	src, ok := source.Source.(RefreshableSource)
+	if !ok {
+		http.Error(w, "source does not support refresh", http.StatusNotImplemented)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := verify(r, source.WebhookSecret, body); err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	var (
+		event struct {
+			Ref string `json:"ref"`
+		}
+		branches []string
+	)
+
+	// the payload of a push event identifies the ref which moved
+	if err := json.Unmarshal(body, &event); err == nil {
+		if branch, ok := strings.CutPrefix(event.Ref, "refs/heads/"); ok {
+			branches = append(branches, branch)
+		}
+	}
+
+	slog.Debug("Refreshing source from webhook", "source", name, "scm", scm, "branches", branches)
+
+	if err := src.Refresh(r.Context(), branches...); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// verifyHMAC verifies the hex encoded HMAC-SHA256 digest of the body
+// supplied via the provided header with the optional prefix.
+func verifyHMAC(header, prefix string) func(*http.Request, string, []byte) error {
+	return func(r *http.Request, secret string, body []byte) error {
+		signature, ok := strings.CutPrefix(r.Header.Get(header), prefix)
+		if !ok || signature == "" {
+			return fmt.Errorf("%w: missing %s header", errInvalidSignature, header)
+		}
+
+		actual, err := hex.DecodeString(signature)
+		if err != nil {
+			return fmt.Errorf("%w: %v", errInvalidSignature, err)
+		}
+
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+
+		if !hmac.Equal(mac.Sum(nil), actual) {
+			return errInvalidSignature
+		}
+
+		return nil
+	}
+}
+
+// verifyToken verifies the secret token supplied verbatim via the provided header.
+func verifyToken(header string) func(*http.Request, string, []byte) error {
+	return func(r *http.Request, secret string, _ []byte) error {
+		token := r.Header.Get(header)
+		if token == "" {
+			return fmt.Errorf("%w: missing %s header", errInvalidSignature, header)
+		}
+
+		if subtle.ConstantTimeCompare([]byte(token), []byte(secret)) != 1 {
+			return errInvalidSignature
+		}
+
+		return nil
+	}
+}
@@ -1,10 +1,15 @@
 package config
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
 	"net/url"
+	"os"
 	"strings"
+	"time"
+
+	"go.flipt.io/cup/pkg/api/core"
 )
 
 type Config struct {
@@ -17,9 +22,36 @@ func (c *Config) FlagSet() *flag.FlagSet {
 	set.StringVar(&c.API.Address, "api-address", ":8181", "server listen address")
 	set.StringVar(&c.API.Source.Type, "api-source", "local", "source type (one of [local, git])")
 	set.StringVar(&c.API.Source.Local.Path, "api-local-path", ".", "path to local source directory")
+	set.BoolVar(&c.API.Source.Local.Git, "api-local-git", false, "commit changes to the Git repository at the local path (proposals are made as local branches)")
 	set.StringVar(&c.API.Source.Git.URL, "api-git-repo", "", "target git repository URL")
-	set.StringVar(&c.API.Source.Git.SCM, "api-git-scm", "github", "SCM type (one of [github, gitea])")
+	set.StringVar(&c.API.Source.Git.SCM, "api-git-scm", "github", "SCM type (one of [github, gitea, gitlab, bitbucket, none])")
+	set.StringVar(&c.API.Source.Git.Branch, "api-git-branch", "main", "base branch to serve and propose changes against")
+	set.DurationVar(&c.API.Source.Git.PollInterval, "api-git-poll-interval", 10*time.Second, "interval in which the remote is polled for changes (0 disables polling)")
+	set.StringVar(&c.API.Source.Git.CacheDir, "api-git-cache-dir", "", "directory in which the repository is cached between restarts (defaults to in-memory)")
+	set.IntVar(&c.API.Source.Git.Depth, "api-git-depth", 0, "limit fetched history to the provided number of commits (0 fetches all history)")
+	set.BoolVar(&c.API.Source.Git.SingleBranch, "api-git-single-branch", false, "only fetch the base branches and proposal branches")
+	set.StringVar(&c.API.Source.Git.Auth.Username, "api-git-auth-username", "", "username to authenticate as (leave empty for bearer tokens)")
+	set.StringVar(&c.API.Source.Git.Auth.TokenFile, "api-git-auth-token-file", "", "path to a file containing the password or token for the remote and SCM")
+	set.StringVar(&c.API.Source.Git.Auth.TokenEnv, "api-git-auth-token-env", "", "environment variable containing the password or token for the remote and SCM")
+	set.StringVar(&c.API.Source.Git.Auth.CredentialHelper, "api-git-auth-credential-helper", "", "git credential helper used to obtain the password or token")
+	set.StringVar(&c.API.Source.Git.Auth.SSHKeyFile, "api-git-auth-ssh-key-file", "", "path to the private key for SSH remotes (defaults to the SSH agent)")
+	set.StringVar(&c.API.Source.Git.Auth.SSHKeyPassphraseEnv, "api-git-auth-ssh-key-passphrase-env", "", "environment variable containing the passphrase for the SSH private key")
+	set.StringVar(&c.API.Source.Git.Auth.SSHKnownHosts, "api-git-auth-ssh-known-hosts", "", "path to the known_hosts file used to verify SSH remotes (defaults to ~/.ssh/known_hosts)")
+	set.Int64Var(&c.API.Source.Git.Auth.GitHubApp.ID, "api-git-auth-github-app-id", 0, "GitHub App ID to authenticate as (requires a private key file)")
+	set.Int64Var(&c.API.Source.Git.Auth.GitHubApp.InstallationID, "api-git-auth-github-app-installation-id", 0, "GitHub App installation ID (defaults to the installation on the repository)")
+	set.StringVar(&c.API.Source.Git.Auth.GitHubApp.PrivateKeyFile, "api-git-auth-github-app-private-key-file", "", "path to the PEM encoded GitHub App private key")
+	set.StringVar(&c.API.Source.Git.Signing.Format, "api-git-signing-format", "openpgp", "format of the commit signing key (one of [openpgp, ssh])")
+	set.StringVar(&c.API.Source.Git.Signing.KeyFile, "api-git-signing-key-file", "", "path to the private key used to sign commits (commits are unsigned when empty)")
+	set.StringVar(&c.API.Source.Git.Signing.PassphraseEnv, "api-git-signing-passphrase-env", "", "environment variable containing the passphrase for the signing key")
+	set.StringVar(&c.API.Source.Git.Templates.Branch, "api-git-branch-template", "", "Go template for the names of proposal branches (must contain {{ .ID }})")
+	set.StringVar(&c.API.Source.Git.Templates.Message, "api-git-message-template", "", "Go template for commit messages")
+	set.StringVar(&c.API.Source.Git.Templates.Title, "api-git-title-template", "", "Go template for proposal titles (defaults to the commit message)")
+	set.StringVar(&c.API.Source.Git.Templates.Body, "api-git-body-template", "", "Go template for proposal bodies")
+	set.StringVar(&c.API.Source.Git.WebhookSecret, "api-git-webhook-secret", "", "secret used to verify SCM webhooks received on /webhooks/{scm} (optional)")
 	set.StringVar(&c.API.Resources, "api-resources", ".", "path to server configuration directory (controllers, definitions and bindings)")
+	set.DurationVar(&c.API.ResourcesPollInterval, "api-resources-poll-interval", 10*time.Second, "interval in which the resources are checked for changes and reloaded (0 disables reloading)")
+	set.StringVar(&c.API.ResourcesSource, "api-resources-source", "", "name of the source to load the resources from, in which case -api-resources is a path within it (defaults to the local filesystem)")
+	set.StringVar(&c.API.SourcesFile, "api-sources-file", "", "path to a JSON file of additional named sources which bindings can target (optional)")
 
 	// Tailscale
 	set.StringVar(&c.Tailscale.Hostname, "tailscale-hostname", "", "hostname to expose on Tailscale")
@@ -40,6 +72,52 @@ type API struct {
 	Address   string
 	Source    Source
 	Resources string
+	// ResourcesPollInterval is the interval in which the resources are checked
+	// for changes and reloaded. Reloading is disabled when zero.
+	ResourcesPollInterval time.Duration
+	// ResourcesSource is the name of the source the resources are loaded from,
+	// in which case Resources is a path within the source.
+	// The resources are loaded from the local filesystem when empty.
+	ResourcesSource string
+	// SourcesFile is the path to a JSON object of named sources, from which Sources are loaded.
+	SourcesFile string
+	// Sources are additional named sources which bindings can target
+	// alongside the default source.
+	Sources map[string]Source
+}
+
+// LoadSources reads the named sources from the sources file (if configured).
+// Each source starts from the defaults of the source flags, which are then
+// overridden by the fields present in the file.
+func (a *API) LoadSources() error {
+	if a.SourcesFile == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(a.SourcesFile)
+	if err != nil {
+		return fmt.Errorf("reading sources: %w", err)
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("parsing sources: %w", err)
+	}
+
+	a.Sources = make(map[string]Source, len(raw))
+	for name, msg := range raw {
+		var defaults Config
+		defaults.FlagSet()
+
+		src := defaults.API.Source
+		if err := json.Unmarshal(msg, &src); err != nil {
+			return fmt.Errorf("parsing source %q: %w", name, err)
+		}
+
+		a.Sources[name] = src
+	}
+
+	return nil
 }
 
 type Source struct {
@@ -50,18 +128,128 @@ type Source struct {
 
 type LocalSource struct {
 	Path string `json:"path"`
+	// Git commits changes to the Git repository at the path, either directly
+	// or on local proposal branches, instead of writing them to the directory.
+	Git bool `json:"git"`
 }
 
 type GitSource struct {
-	URL string `json:"url"`
-	SCM string `json:"scm"`
+	URL    string `json:"url"`
+	SCM    string `json:"scm"`
+	Branch string `json:"branch"`
+	// PollInterval is the interval in which the remote is polled for changes.
+	// Polling is disabled when zero, in which case changes are only observed via webhooks.
+	PollInterval time.Duration `json:"pollInterval"`
+	// CacheDir is the directory the repository is stored in between restarts.
+	// The repository is cloned into memory when empty.
+	CacheDir string `json:"cacheDir"`
+	// Depth limits the history fetched to the provided number of commits.
+	Depth int `json:"depth"`
+	// SingleBranch limits the branches fetched to the base branches
+	// of the source and bindings, along with the proposal branches.
+	SingleBranch bool `json:"singleBranch"`
+	// WebhookSecret verifies push events delivered by the SCM.
+	WebhookSecret string `json:"webhookSecret"`
+	// Auth configures how to authenticate with the remote and SCM.
+	Auth GitAuth `json:"auth"`
+	// Signing configures how the commits created by cupd are signed.
+	Signing GitSigning `json:"signing"`
+	// Templates are the default templates which describe changes.
+	// They are overridden by the templates of each binding.
+	Templates core.Templates `json:"templates"`
+}
+
+// UnmarshalJSON decodes the source, accepting the poll interval
+// as a duration string (e.g. "30s").
+func (g *GitSource) UnmarshalJSON(data []byte) error {
+	type plain GitSource
+	v := struct {
+		*plain
+		PollInterval *string `json:"pollInterval"`
+	}{plain: (*plain)(g)}
+
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	if v.PollInterval != nil {
+		interval, err := time.ParseDuration(*v.PollInterval)
+		if err != nil {
+			return fmt.Errorf("pollInterval: %w", err)
+		}
+
+		g.PollInterval = interval
+	}
+
+	return nil
+}
+
+// GitSigning configures the key used to sign commits.
+// Commits are left unsigned when no key file is configured.
+type GitSigning struct {
+	// Format is the format of the key and signature (one of [openpgp, ssh]).
+	Format string `json:"format"`
+	// KeyFile is the path to the armored OpenPGP or PEM encoded SSH private key.
+	KeyFile string `json:"keyFile"`
+	// PassphraseEnv is the name of an environment variable containing
+	// the passphrase for the private key.
+	PassphraseEnv string `json:"passphraseEnv"`
+}
+
+// GitAuth configures the credentials for a Git remote and its SCM API
+// separately from the remote URL.
+// The password or token is read from the first of the token file, token environment
+// variable and credential helper which is configured.
+type GitAuth struct {
+	// Username is the user to authenticate as.
+	// When empty, the token is used as a bearer token for the SCM API
+	// and as the password for the Git remote.
+	Username string `json:"username"`
+	// TokenFile is the path to a file containing the password or token.
+	TokenFile string `json:"tokenFile"`
+	// TokenEnv is the name of an environment variable containing the password or token.
+	TokenEnv string `json:"tokenEnv"`
+	// CredentialHelper is a git credential helper used to obtain the password or token.
+	CredentialHelper string `json:"credentialHelper"`
+	// SSHKeyFile is the path to the private key used to authenticate with SSH remotes.
+	// The SSH agent is used when empty.
+	SSHKeyFile string `json:"sshKeyFile"`
+	// SSHKeyPassphraseEnv is the name of an environment variable containing
+	// the passphrase for the SSH private key.
+	SSHKeyPassphraseEnv string `json:"sshKeyPassphraseEnv"`
+	// SSHKnownHosts is the path to the known_hosts file used to verify SSH remotes.
+	SSHKnownHosts string `json:"sshKnownHosts"`
+	// GitHubApp authenticates as a GitHub App installation instead of a user.
+	GitHubApp GitHubApp `json:"githubApp"`
+}
+
+// GitHubApp configures authentication as an installation of a GitHub App.
+// Short-lived installation tokens are minted from the app private key and
+// used for both the Git remote and the GitHub API.
+type GitHubApp struct {
+	// ID is the identifier of the GitHub App.
+	ID int64 `json:"id"`
+	// InstallationID is the identifier of the app installation.
+	// When zero, the installation is discovered from the repository.
+	InstallationID int64 `json:"installationID"`
+	// PrivateKeyFile is the path to the PEM encoded private key of the app.
+	PrivateKeyFile string `json:"privateKeyFile"`
 }
 
 type GitURL struct {
 	*url.URL
 }
 
+// ParseGitURL parses the URL of a Git remote.
+// The scp-like syntax for SSH remotes (e.g. git@github.com:owner/repo.git)
+// is normalized into an equivalent ssh:// URL.
 func ParseGitURL(v string) (_ *GitURL, err error) {
+	if user, rest, ok := strings.Cut(v, "@"); ok && !strings.Contains(user, "://") {
+		if host, path, ok := strings.Cut(rest, ":"); ok && !strings.HasPrefix(path, "//") {
+			v = fmt.Sprintf("ssh://%s@%s/%s", user, host, strings.TrimPrefix(path, "/"))
+		}
+	}
+
 	g := &GitURL{}
 	g.URL, err = url.Parse(v)
 	return g, err
@@ -72,12 +260,26 @@ func (u *GitURL) Credentials() (user, pass string) {
 	return u.User.Username(), pass
 }
 
+// Host returns the scheme and host of the remote, which is the base URL of most SCM APIs.
+// The API of SSH remotes is assumed to be served over HTTPS on the same hostname.
 func (u *GitURL) Host() string {
+	if u.Scheme == "ssh" {
+		return "https://" + u.Hostname()
+	}
+
 	return fmt.Sprintf("%s://%s", u.Scheme, u.URL.Host)
 }
 
+// OwnerRepo returns the owner (user, organization, group, workspace or project)
+// and repository name from the URL path.
+// The /scm/ prefix of Bitbucket Server clone URLs is ignored.
 func (u *GitURL) OwnerRepo() (owner, repo string, err error) {
-	parts := strings.SplitN(u.Path, "/", 3)
+	path := u.Path
+	if strings.HasPrefix(path, "/scm/") {
+		path = strings.TrimPrefix(path, "/scm")
+	}
+
+	parts := strings.SplitN(path, "/", 3)
 	if len(parts) < 3 {
 		return "", "", fmt.Errorf("unexpected path: %q", u.Path)
 	}
@@ -1,12 +1,17 @@
 package controllers
 
 import (
+	"errors"
 	"io/fs"
 	"path"
 
 	"go.flipt.io/cup/pkg/api/core"
+	"go.flipt.io/cup/pkg/labels"
 )
 
+// ErrNotFound is returned by controllers when the requested resource does not exist.
+var ErrNotFound = errors.New("resource not found")
+
 type Controller struct{}
 
 type Request struct {
@@ -29,7 +34,7 @@ type GetRequest struct {
 type ListRequest struct {
 	Request
 	FS     fs.FS
-	Labels [][2]string
+	Labels labels.Selector
 }
 
 type PutRequest struct {
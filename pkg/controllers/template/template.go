@@ -125,7 +125,7 @@ func (c *Controller) Get(_ context.Context, req *controllers.GetRequest) (_ *cor
 }
 
 // List finds all the resources on the provided FS in the folder { namespace }
-// The result set is filtered by any specified labels.
+// The result set is filtered by the label selector (when specified).
 func (c *Controller) List(_ context.Context, req *controllers.ListRequest) (resources []*core.Resource, err error) {
 	defer func() {
 		if err != nil {
@@ -157,12 +157,10 @@ func (c *Controller) List(_ context.Context, req *controllers.ListRequest) (reso
 				return err
 			}
 
-			for _, kv := range req.Labels {
-				// skip adding resource if any of the specified labels
-				// do not match as expected
-				if v, ok := resource.Metadata.Labels[kv[0]]; !ok || v != kv[1] {
-					return nil
-				}
+			// skip adding resource if the label selector
+			// does not match as expected
+			if !req.Labels.Matches(resource.Metadata.Labels) {
+				return nil
 			}
 
 			resources = append(resources, resource)
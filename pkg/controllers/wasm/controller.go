@@ -22,7 +22,7 @@ var (
 
 	// ErrNotFound is returned when the requested resource cannot
 	// be located by the WASM runtime implementation
-	ErrNotFound = errors.New("resource not found")
+	ErrNotFound = controllers.ErrNotFound
 )
 
 type Controller struct {
@@ -41,6 +41,11 @@ func New(ctx context.Context, wasm []byte) *Controller {
 	return c
 }
 
+// Close releases the WASM runtime of the controller, after which it can no longer be used.
+func (c *Controller) Close(ctx context.Context) error {
+	return c.runtime.Close(ctx)
+}
+
 func (c *Controller) Get(ctx context.Context, r *controllers.GetRequest) (_ *core.Resource, err error) {
 	defer func() {
 		if err != nil {
@@ -72,9 +77,16 @@ func (c *Controller) List(ctx context.Context, r *controllers.ListRequest) (reso
 		}
 	}()
 
+	// the label selector is passed as an optional trailing argument
+	// list <kind> <namespace> [<selector>]
+	args := []string{"list", r.Kind, r.Namespace}
+	if len(r.Labels) > 0 {
+		args = append(args, r.Labels.String())
+	}
+
 	buf := &bytes.Buffer{}
 	if err = c.exec(ctx,
-		[]string{"list", r.Kind, r.Namespace},
+		args,
 		func(mc wazero.ModuleConfig) wazero.ModuleConfig {
 			return mc.WithStdout(buf).WithFSConfig(wazero.NewFSConfig().WithFSMount(r.FS, "/"))
 		}); err != nil {
@@ -88,6 +100,12 @@ func (c *Controller) List(ctx context.Context, r *controllers.ListRequest) (reso
 			break
 		}
 
+		// controllers are not required to support label selectors
+		// so the results are filtered again here
+		if !r.Labels.Matches(resource.Metadata.Labels) {
+			continue
+		}
+
 		resources = append(resources, &resource)
 	}
 
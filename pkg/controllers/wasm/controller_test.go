@@ -17,6 +17,7 @@ import (
 	"github.com/stretchr/testify/require"
 	"go.flipt.io/cup/pkg/api/core"
 	"go.flipt.io/cup/pkg/controllers"
+	"go.flipt.io/cup/pkg/labels"
 )
 
 //go:embed testdata/*
@@ -108,6 +109,34 @@ func Test_Controller_List(t *testing.T) {
 	}, resources)
 }
 
+func Test_Controller_List_Selector(t *testing.T) {
+	wasm, skip := compileTestController(t)
+	if skip {
+		return
+	}
+
+	ctx := context.Background()
+	controller := New(ctx, wasm)
+
+	selector, err := labels.Parse("bar in (baz)")
+	require.NoError(t, err)
+
+	resources, err := controller.List(ctx, &controllers.ListRequest{
+		FS: testdataFS(t),
+		Request: controllers.Request{
+			Group:     "test.cup.flipt.io",
+			Version:   "v1alpha1",
+			Kind:      "Resource",
+			Namespace: "default",
+		},
+		Labels: selector,
+	})
+	require.NoError(t, err)
+
+	require.Len(t, resources, 1)
+	assert.Equal(t, "foo", resources[0].Metadata.Name)
+}
+
 func Test_Controller_Put(t *testing.T) {
 	wasm, skip := compileTestController(t)
 	if skip {
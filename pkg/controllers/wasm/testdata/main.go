@@ -9,6 +9,7 @@ import (
 	"sort"
 
 	"go.flipt.io/cup/pkg/api/core"
+	"go.flipt.io/cup/pkg/labels"
 )
 
 var data = map[string]map[string]map[string]*core.Resource{}
@@ -79,9 +80,17 @@ func main() {
 			os.Exit(2)
 		}
 
+		var selector labels.Selector
+		if len(os.Args) > 4 {
+			selector, err = labels.Parse(os.Args[4])
+			fatal(err)
+		}
+
 		var names []string
-		for name := range namespace {
-			names = append(names, name)
+		for name, resource := range namespace {
+			if selector.Matches(resource.Metadata.Labels) {
+				names = append(names, name)
+			}
 		}
 
 		sort.Strings(names)
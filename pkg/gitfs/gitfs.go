@@ -21,12 +21,23 @@ import (
 type FS struct {
 	storage storage.Storer
 	tree    *object.Tree
+	commit  plumbing.Hash
 }
 
 // New instantiates and instance of storage which retrieves files from
 // the provided storage.Storer implementation and instance of *object.Tree.
 func New(storage storage.Storer, tree *object.Tree) FS {
-	return FS{storage, tree}
+	return FS{storage: storage, tree: tree}
+}
+
+// Version returns the hash of the commit the FS was derived from.
+// It returns an empty string when the FS was constructed directly from a tree.
+func (f FS) Version() string {
+	if f.commit.IsZero() {
+		return ""
+	}
+
+	return f.commit.String()
 }
 
 // Options configures call to NewFromRepo.
@@ -81,7 +92,10 @@ func NewFromRepoHash(repo *git.Repository, hash plumbing.Hash) (FS, error) {
 		return FS{}, fmt.Errorf("retrieving root tree (%q): %w", commit.TreeHash, err)
 	}
 
-	return New(repo.Storer, tree), nil
+	fs := New(repo.Storer, tree)
+	fs.commit = commit.Hash
+
+	return fs, nil
 }
 
 // Open opens the named file.
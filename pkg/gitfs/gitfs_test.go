@@ -24,9 +24,13 @@ var expected = map[string]string{
 func Test_FS(t *testing.T) {
 	repo := testdataRepo(t)
 
-	filesystem, _, err := NewFromRepo(repo)
+	filesystem, hash, err := NewFromRepo(repo)
 	require.NoError(t, err)
 
+	t.Run("Ensure version is the hash of the source commit", func(t *testing.T) {
+		assert.Equal(t, hash.String(), filesystem.Version())
+	})
+
 	t.Run("Ensure invalid and non existent paths produce an error", func(t *testing.T) {
 		_, err := filesystem.Open("..")
 		require.Equal(t, err, &fs.PathError{
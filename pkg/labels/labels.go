@@ -0,0 +1,225 @@
+package labels
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Operator is the relationship a Requirement asserts between a label key and its values.
+type Operator string
+
+const (
+	// Equals matches when the label is present with the single supplied value.
+	Equals = Operator("=")
+	// NotEquals matches when the label is absent or not the single supplied value.
+	NotEquals = Operator("!=")
+	// In matches when the label is present with one of the supplied values.
+	In = Operator("in")
+	// NotIn matches when the label is absent or not one of the supplied values.
+	NotIn = Operator("notin")
+	// Exists matches when the label is present with any value.
+	Exists = Operator("exists")
+	// DoesNotExist matches when the label is absent.
+	DoesNotExist = Operator("!")
+)
+
+var (
+	// ErrInvalidSelector is wrapped and returned when a selector cannot be parsed.
+	ErrInvalidSelector = errors.New("invalid label selector")
+
+	keyPattern   = regexp.MustCompile(`^[A-Za-z0-9]([-A-Za-z0-9_./]*[A-Za-z0-9])?$`)
+	valuePattern = regexp.MustCompile(`^([A-Za-z0-9]([-A-Za-z0-9_.]*[A-Za-z0-9])?)?$`)
+	setPattern   = regexp.MustCompile(`^(\S+)\s+(in|notin)\s*\((.*)\)$`)
+)
+
+// Requirement is a single condition over the labels of a resource.
+type Requirement struct {
+	Key      string
+	Operator Operator
+	Values   []string
+}
+
+// Matches returns true if the provided labels satisfy the requirement.
+func (r Requirement) Matches(labels map[string]string) bool {
+	v, ok := labels[r.Key]
+	switch r.Operator {
+	case Equals, In:
+		return ok && slices.Contains(r.Values, v)
+	case NotEquals, NotIn:
+		return !ok || !slices.Contains(r.Values, v)
+	case Exists:
+		return ok
+	case DoesNotExist:
+		return !ok
+	default:
+		return false
+	}
+}
+
+// String returns the requirement in the form accepted by Parse.
+func (r Requirement) String() string {
+	switch r.Operator {
+	case Exists:
+		return r.Key
+	case DoesNotExist:
+		return "!" + r.Key
+	case In, NotIn:
+		return fmt.Sprintf("%s %s (%s)", r.Key, r.Operator, strings.Join(r.Values, ","))
+	default:
+		return r.Key + string(r.Operator) + strings.Join(r.Values, "")
+	}
+}
+
+// Selector is a set of requirements which must all be satisfied (logical AND).
+// An empty selector matches everything.
+type Selector []Requirement
+
+// Matches returns true if the provided labels satisfy every requirement in the selector.
+func (s Selector) Matches(labels map[string]string) bool {
+	for _, r := range s {
+		if !r.Matches(labels) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// String returns the selector in the form accepted by Parse.
+func (s Selector) String() string {
+	reqs := make([]string, 0, len(s))
+	for _, r := range s {
+		reqs = append(reqs, r.String())
+	}
+
+	return strings.Join(reqs, ",")
+}
+
+// Parse parses a Kubernetes-style label selector.
+// A selector is a comma separated list of requirements, each of which is one of:
+//
+//	key=value, key==value  (equality)
+//	key!=value             (inequality)
+//	key in (v1,v2)         (set membership)
+//	key notin (v1,v2)      (set exclusion)
+//	key                    (existence)
+//	!key                   (non-existence)
+func Parse(selector string) (s Selector, err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("%w %q: %v", ErrInvalidSelector, selector, err)
+		}
+	}()
+
+	terms, err := split(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, term := range terms {
+		r, err := parseRequirement(term)
+		if err != nil {
+			return nil, err
+		}
+
+		s = append(s, r)
+	}
+
+	return s, nil
+}
+
+func parseRequirement(term string) (r Requirement, err error) {
+	switch {
+	case strings.HasPrefix(term, "!") && !strings.Contains(term, "="):
+		r = Requirement{Key: strings.TrimSpace(term[1:]), Operator: DoesNotExist}
+	case setPattern.MatchString(term):
+		match := setPattern.FindStringSubmatch(term)
+		r = Requirement{Key: match[1], Operator: Operator(match[2])}
+		if strings.TrimSpace(match[3]) == "" {
+			return r, fmt.Errorf("empty set of values for key %q", r.Key)
+		}
+
+		for _, v := range strings.Split(match[3], ",") {
+			r.Values = append(r.Values, strings.TrimSpace(v))
+		}
+
+		sort.Strings(r.Values)
+	case strings.Contains(term, "!="):
+		k, v, _ := strings.Cut(term, "!=")
+		r = Requirement{Key: strings.TrimSpace(k), Operator: NotEquals, Values: []string{strings.TrimSpace(v)}}
+	case strings.Contains(term, "="):
+		k, v, _ := strings.Cut(term, "=")
+		r = Requirement{Key: strings.TrimSpace(k), Operator: Equals, Values: []string{strings.TrimSpace(strings.TrimPrefix(v, "="))}}
+	default:
+		r = Requirement{Key: term, Operator: Exists}
+	}
+
+	if !keyPattern.MatchString(r.Key) {
+		return r, fmt.Errorf("invalid key %q", r.Key)
+	}
+
+	for _, v := range r.Values {
+		if !valuePattern.MatchString(v) {
+			return r, fmt.Errorf("invalid value %q for key %q", v, r.Key)
+		}
+	}
+
+	return r, nil
+}
+
+// split separates the selector on top-level commas
+// ignoring those which occur within parentheses.
+func split(selector string) (terms []string, err error) {
+	var (
+		depth int
+		start int
+	)
+
+	add := func(term string) error {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			return errors.New("empty requirement")
+		}
+
+		terms = append(terms, term)
+		return nil
+	}
+
+	if strings.TrimSpace(selector) == "" {
+		return nil, nil
+	}
+
+	for i, c := range selector {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth < 0 {
+				return nil, errors.New("unexpected ')'")
+			}
+		case ',':
+			if depth == 0 {
+				if err := add(selector[start:i]); err != nil {
+					return nil, err
+				}
+
+				start = i + 1
+			}
+		}
+	}
+
+	if depth != 0 {
+		return nil, errors.New("unterminated '('")
+	}
+
+	if err := add(selector[start:]); err != nil {
+		return nil, err
+	}
+
+	return terms, nil
+}
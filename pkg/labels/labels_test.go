@@ -0,0 +1,95 @@
+package labels
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Parse(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		selector string
+		expected Selector
+		err      bool
+	}{
+		{name: "empty", selector: ""},
+		{
+			name:     "equality",
+			selector: "env=prod, tier==frontend",
+			expected: Selector{
+				{Key: "env", Operator: Equals, Values: []string{"prod"}},
+				{Key: "tier", Operator: Equals, Values: []string{"frontend"}},
+			},
+		},
+		{
+			name:     "inequality",
+			selector: "env!=prod",
+			expected: Selector{{Key: "env", Operator: NotEquals, Values: []string{"prod"}}},
+		},
+		{
+			name:     "set based",
+			selector: "env in (prod, staging),tier notin (backend)",
+			expected: Selector{
+				{Key: "env", Operator: In, Values: []string{"prod", "staging"}},
+				{Key: "tier", Operator: NotIn, Values: []string{"backend"}},
+			},
+		},
+		{
+			name:     "existence",
+			selector: "flipt.io/team,!deprecated",
+			expected: Selector{
+				{Key: "flipt.io/team", Operator: Exists},
+				{Key: "deprecated", Operator: DoesNotExist},
+			},
+		},
+		{name: "empty requirement", selector: "env=prod,", err: true},
+		{name: "unterminated set", selector: "env in (prod", err: true},
+		{name: "empty set", selector: "env in ()", err: true},
+		{name: "empty exclusion set", selector: "env notin ( )", err: true},
+		{name: "invalid key", selector: "en v=prod", err: true},
+		{name: "invalid value", selector: "env=pr(od", err: true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			selector, err := Parse(test.selector)
+			if test.err {
+				require.ErrorIs(t, err, ErrInvalidSelector)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, selector)
+
+			// ensure the selector round trips through String
+			reparsed, err := Parse(selector.String())
+			require.NoError(t, err)
+			assert.Equal(t, selector, reparsed)
+		})
+	}
+}
+
+func Test_Selector_Matches(t *testing.T) {
+	labels := map[string]string{"env": "prod", "tier": "frontend"}
+
+	for selector, expected := range map[string]bool{
+		"":                         true,
+		"env=prod":                 true,
+		"env=staging":              false,
+		"env!=staging":             true,
+		"missing!=value":           true,
+		"env in (prod,staging)":    true,
+		"env notin (prod,staging)": false,
+		"missing notin (prod)":     true,
+		"tier":                     true,
+		"missing":                  false,
+		"!missing":                 true,
+		"env=prod,!tier":           false,
+	} {
+		t.Run(selector, func(t *testing.T) {
+			s, err := Parse(selector)
+			require.NoError(t, err)
+			assert.Equal(t, expected, s.Matches(labels))
+		})
+	}
+}
@@ -0,0 +1,339 @@
+// Package auth resolves the credentials used to authenticate with Git remotes
+// and their SCM APIs from configuration which is kept separate from the remote URL.
+package auth
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"go.flipt.io/cup/pkg/config"
+)
+
+// Credentials are the resolved secrets used to authenticate with a Git remote and its SCM API.
+type Credentials struct {
+	// Username is the user to authenticate as.
+	// It is empty when the secret is a bearer token.
+	Username string
+	// Secret is the password or token used to authenticate over HTTP and with the SCM API.
+	Secret string
+	// Method authenticates the Git transport.
+	// It is nil when no credentials have been configured.
+	Method transport.AuthMethod
+
+	// refresh resolves the secret again from its token file or credential helper.
+	// It is nil when the secret cannot change without a restart.
+	refresh func(context.Context) (string, error)
+}
+
+// Token returns the current password or token.
+// Token files and credential helpers are read again on each call,
+// so that rotated secrets are picked up without a restart.
+func (c *Credentials) Token(ctx context.Context) (string, error) {
+	if c.refresh == nil {
+		return c.Secret, nil
+	}
+
+	return c.refresh(ctx)
+}
+
+// Transport returns an http.RoundTripper which sets the provided header to the prefix
+// followed by the current token on each request made to an SCM API.
+func (c *Credentials) Transport(header, prefix string) http.RoundTripper {
+	return &credentialsTransport{creds: c, set: func(r *http.Request, token string) {
+		r.Header.Set(header, prefix+token)
+	}}
+}
+
+// BasicTransport returns an http.RoundTripper which authenticates each request made to an
+// SCM API using basic auth with the username and current password or token.
+func (c *Credentials) BasicTransport() http.RoundTripper {
+	return &credentialsTransport{creds: c, set: func(r *http.Request, token string) {
+		r.SetBasicAuth(c.Username, token)
+	}}
+}
+
+// Resolve derives the credentials for the provided remote from the auth configuration.
+// The secret is taken from the first of the token file, token environment variable,
+// credential helper and (for backwards compatibility) the password embedded in the URL.
+// HTTPS remotes authenticate the transport using basic auth, with the token as the password,
+// and token files and credential helpers are read again on each use.
+// SSH remotes authenticate the transport using the configured key file (or the SSH agent)
+// with host keys checked against known_hosts.
+func Resolve(ctx context.Context, cfg config.GitAuth, u *config.GitURL) (*Credentials, error) {
+	creds := &Credentials{Username: cfg.Username}
+
+	urlUser, urlPass := u.Credentials()
+	if creds.Username == "" && u.Scheme != "ssh" {
+		creds.Username = urlUser
+	}
+
+	switch {
+	case cfg.TokenFile != "":
+		creds.refresh = func(context.Context) (string, error) {
+			data, err := os.ReadFile(cfg.TokenFile)
+			if err != nil {
+				return "", fmt.Errorf("reading token file: %w", err)
+			}
+
+			return strings.TrimSpace(string(data)), nil
+		}
+	case cfg.TokenEnv != "":
+		creds.Secret = os.Getenv(cfg.TokenEnv)
+		if creds.Secret == "" {
+			return nil, fmt.Errorf("token environment variable %q is empty", cfg.TokenEnv)
+		}
+	case cfg.CredentialHelper != "":
+		user, pass, err := credentialHelper(ctx, cfg.CredentialHelper, u, creds.Username)
+		if err != nil {
+			return nil, fmt.Errorf("credential helper: %w", err)
+		}
+
+		if creds.Username == "" {
+			creds.Username = user
+		}
+
+		creds.Secret = pass
+		creds.refresh = func(ctx context.Context) (string, error) {
+			_, pass, err := credentialHelper(ctx, cfg.CredentialHelper, u, creds.Username)
+			if err != nil {
+				return "", fmt.Errorf("credential helper: %w", err)
+			}
+
+			return pass, nil
+		}
+	default:
+		creds.Secret = urlPass
+	}
+
+	if creds.refresh != nil && creds.Secret == "" {
+		secret, err := creds.refresh(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		creds.Secret = secret
+	}
+
+	if u.Scheme == "ssh" {
+		method, err := sshAuth(cfg, urlUser)
+		if err != nil {
+			return nil, fmt.Errorf("ssh auth: %w", err)
+		}
+
+		creds.Method = method
+
+		return creds, nil
+	}
+
+	// Git hosts only accept tokens over HTTPS as the password of basic auth
+	// so a username is supplied when authenticating with a token alone
+	user := creds.Username
+	if user == "" {
+		user = tokenUsername(u)
+	}
+
+	switch {
+	case creds.Secret == "":
+	case creds.refresh != nil:
+		creds.Method = &refreshingAuth{creds: creds, username: user}
+	default:
+		creds.Method = &githttp.BasicAuth{Username: user, Password: creds.Secret}
+	}
+
+	return creds, nil
+}
+
+// tokenUsername returns the username which accompanies a token in basic auth for the host of the remote.
+// GitLab expects oauth2 and Bitbucket Cloud x-token-auth, while GitHub (and most other hosts)
+// accept any non-empty username.
+func tokenUsername(u *config.GitURL) string {
+	switch host := u.Hostname(); {
+	case strings.Contains(host, "gitlab"):
+		return "oauth2"
+	case host == "bitbucket.org":
+		return "x-token-auth"
+	default:
+		return "x-access-token"
+	}
+}
+
+// refreshingAuth authenticates Git over HTTPS using basic auth with the current token of the credentials.
+// It implements the go-git http.AuthMethod interface.
+type refreshingAuth struct {
+	creds    *Credentials
+	username string
+}
+
+func (a *refreshingAuth) Name() string {
+	return "http-basic-auth"
+}
+
+func (a *refreshingAuth) String() string {
+	return fmt.Sprintf("%s - %s:%s", a.Name(), a.username, "*******")
+}
+
+// SetAuth authenticates the request with the current token, which is resolved again on each use.
+func (a *refreshingAuth) SetAuth(r *http.Request) {
+	token, err := a.creds.Token(r.Context())
+	if err != nil {
+		slog.Error("Resolving git credentials", "error", err)
+		return
+	}
+
+	r.SetBasicAuth(a.username, token)
+}
+
+// credentialsTransport authenticates requests to an SCM API using the current token of the credentials.
+type credentialsTransport struct {
+	creds *Credentials
+	set   func(*http.Request, string)
+}
+
+func (t *credentialsTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	token, err := t.creds.Token(r.Context())
+	if err != nil {
+		return nil, err
+	}
+
+	r = r.Clone(r.Context())
+	t.set(r, token)
+
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+// sshAuth returns public key authentication using the configured key file.
+// When no key file is configured the SSH agent is used instead.
+func sshAuth(cfg config.GitAuth, user string) (transport.AuthMethod, error) {
+	if user == "" {
+		user = gitssh.DefaultUsername
+	}
+
+	// the default known_hosts files are used when none is configured
+	var files []string
+	if cfg.SSHKnownHosts != "" {
+		files = append(files, cfg.SSHKnownHosts)
+	}
+
+	callback, err := gitssh.NewKnownHostsCallback(files...)
+	if err != nil {
+		return nil, fmt.Errorf("loading known hosts: %w", err)
+	}
+
+	if cfg.SSHKeyFile == "" {
+		auth, err := gitssh.NewSSHAgentAuth(user)
+		if err != nil {
+			return nil, err
+		}
+
+		auth.HostKeyCallback = callback
+
+		return auth, nil
+	}
+
+	var passphrase string
+	if cfg.SSHKeyPassphraseEnv != "" {
+		passphrase = os.Getenv(cfg.SSHKeyPassphraseEnv)
+	}
+
+	auth, err := gitssh.NewPublicKeysFromFile(user, cfg.SSHKeyFile, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	auth.HostKeyCallback = callback
+
+	return auth, nil
+}
+
+// credentialHelper requests credentials for the remote from the provided git credential helper.
+// The helper follows the same conventions as git's credential.helper configuration:
+// a value prefixed with ! is run as a shell command, an absolute path is run as-is
+// and any other value is run as the git subcommand credential-<helper>.
+func credentialHelper(ctx context.Context, helper string, u *config.GitURL, user string) (username, password string, _ error) {
+	command := helper
+	switch {
+	case strings.HasPrefix(helper, "!"):
+		command = strings.TrimPrefix(helper, "!")
+	case strings.HasPrefix(helper, "/"):
+	default:
+		command = "git credential-" + helper
+	}
+
+	input := &bytes.Buffer{}
+	fmt.Fprintf(input, "protocol=%s\n", u.Scheme)
+	fmt.Fprintf(input, "host=%s\n", u.URL.Host)
+	if user != "" {
+		fmt.Fprintf(input, "username=%s\n", user)
+	}
+	fmt.Fprintln(input)
+
+	stderr := &bytes.Buffer{}
+	cmd := exec.CommandContext(ctx, "sh", "-c", command+" get")
+	cmd.Stdin = input
+	cmd.Stderr = stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		return "", "", fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if !ok {
+			continue
+		}
+
+		switch key {
+		case "username":
+			username = value
+		case "password":
+			password = value
+		}
+	}
+
+	if password == "" {
+		return "", "", errors.New("no password returned")
+	}
+
+	return username, password, scanner.Err()
+}
+
+// BearerTransport is an http.RoundTripper which authenticates each request
+// with the provided bearer token.
+type BearerTransport struct {
+	Token string
+	// Base is the underlying transport.
+	// http.DefaultTransport is used when nil.
+	Base http.RoundTripper
+}
+
+// RoundTrip adds the Authorization header to a clone of the request
+// and delegates it to the base transport.
+func (t *BearerTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	r = r.Clone(r.Context())
+	r.Header.Set("Authorization", "Bearer "+t.Token)
+
+	return base.RoundTrip(r)
+}
+
+// Client returns an *http.Client which uses the transport.
+func (t *BearerTransport) Client() *http.Client {
+	return &http.Client{Transport: t}
+}
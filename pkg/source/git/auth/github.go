@@ -0,0 +1,280 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"go.flipt.io/cup/pkg/containers"
+)
+
+// GitHubAPIURL is the base URL of the public GitHub REST API.
+const GitHubAPIURL = "https://api.github.com"
+
+// tokenExpiryLeeway is how long before expiry an installation token is refreshed.
+const tokenExpiryLeeway = 5 * time.Minute
+
+// GitHubApp authenticates as an installation of a GitHub App.
+// Installation access tokens are minted on demand using a JWT signed with the
+// private key of the app and are refreshed shortly before they expire.
+type GitHubApp struct {
+	appID          int64
+	installationID int64
+	key            *rsa.PrivateKey
+	baseURL        string
+	client         *http.Client
+	owner          string
+	repository     string
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// WithInstallationID configures the installation of the app to authenticate as.
+func WithInstallationID(id int64) containers.Option[GitHubApp] {
+	return func(a *GitHubApp) {
+		a.installationID = id
+	}
+}
+
+// WithRepository configures the repository used to discover the installation of the app
+// when no installation ID has been configured.
+func WithRepository(owner, repository string) containers.Option[GitHubApp] {
+	return func(a *GitHubApp) {
+		a.owner = owner
+		a.repository = repository
+	}
+}
+
+// WithBaseURL overrides the base URL of the GitHub API (e.g. for GitHub Enterprise Server).
+func WithBaseURL(url string) containers.Option[GitHubApp] {
+	return func(a *GitHubApp) {
+		a.baseURL = strings.TrimSuffix(url, "/")
+	}
+}
+
+// WithAppHTTPClient overrides the HTTP client used to mint installation tokens.
+func WithAppHTTPClient(client *http.Client) containers.Option[GitHubApp] {
+	return func(a *GitHubApp) {
+		a.client = client
+	}
+}
+
+// NewGitHubApp constructs a GitHubApp for the app identified by appID using
+// the provided PEM encoded RSA private key of the app.
+// Either an installation ID or a repository must be configured.
+func NewGitHubApp(appID int64, privateKey []byte, opts ...containers.Option[GitHubApp]) (*GitHubApp, error) {
+	key, err := parseRSAPrivateKey(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("github app: %w", err)
+	}
+
+	app := &GitHubApp{
+		appID:   appID,
+		key:     key,
+		baseURL: GitHubAPIURL,
+		client:  http.DefaultClient,
+	}
+
+	containers.ApplyAll(app, opts...)
+
+	if app.installationID == 0 && (app.owner == "" || app.repository == "") {
+		return nil, errors.New("github app: either an installation ID or repository is required")
+	}
+
+	return app, nil
+}
+
+// Token returns a valid installation access token.
+// A cached token is returned until it is close to expiring.
+func (a *GitHubApp) Token(ctx context.Context) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.token != "" && time.Now().Add(tokenExpiryLeeway).Before(a.expires) {
+		return a.token, nil
+	}
+
+	jwt, err := a.jwt()
+	if err != nil {
+		return "", fmt.Errorf("signing jwt: %w", err)
+	}
+
+	if a.installationID == 0 {
+		var installation struct {
+			ID int64 `json:"id"`
+		}
+
+		if err := a.do(ctx, http.MethodGet, fmt.Sprintf("/repos/%s/%s/installation", a.owner, a.repository), jwt, &installation); err != nil {
+			return "", fmt.Errorf("finding installation: %w", err)
+		}
+
+		a.installationID = installation.ID
+	}
+
+	var token struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+
+	if err := a.do(ctx, http.MethodPost, fmt.Sprintf("/app/installations/%d/access_tokens", a.installationID), jwt, &token); err != nil {
+		return "", fmt.Errorf("creating installation token: %w", err)
+	}
+
+	a.token, a.expires = token.Token, token.ExpiresAt
+
+	return a.token, nil
+}
+
+// Client returns an *http.Client which authenticates requests to the GitHub API
+// as the installation.
+func (a *GitHubApp) Client() *http.Client {
+	return &http.Client{Transport: &installationTransport{app: a, base: http.DefaultTransport}}
+}
+
+// AuthMethod returns a Git transport auth method which authenticates
+// pushes and fetches over HTTPS as the installation.
+func (a *GitHubApp) AuthMethod() transport.AuthMethod {
+	return &installationAuth{app: a}
+}
+
+// jwt returns a JSON web token which authenticates as the app itself.
+// The issued at time is backdated to allow for clock drift.
+func (a *GitHubApp) jwt() (string, error) {
+	now := time.Now()
+
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+
+	claims, err := json.Marshal(map[string]any{
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(9 * time.Minute).Unix(),
+		"iss": strconv.FormatInt(a.appID, 10),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	enc := base64.RawURLEncoding
+	unsigned := enc.EncodeToString(header) + "." + enc.EncodeToString(claims)
+
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, a.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+
+	return unsigned + "." + enc.EncodeToString(signature), nil
+}
+
+func (a *GitHubApp) do(ctx context.Context, method, path, jwt string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, method, a.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Authorization", "Bearer "+jwt)
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("unexpected status %q: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func parseRSAPrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("private key is not PEM encoded")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parsing private key: %w", err)
+	}
+
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("unexpected private key type %T", parsed)
+	}
+
+	return key, nil
+}
+
+// installationTransport authenticates requests to the GitHub API using installation tokens.
+type installationTransport struct {
+	app  *GitHubApp
+	base http.RoundTripper
+}
+
+func (t *installationTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	token, err := t.app.Token(r.Context())
+	if err != nil {
+		return nil, err
+	}
+
+	r = r.Clone(r.Context())
+	r.Header.Set("Authorization", "Bearer "+token)
+
+	return t.base.RoundTrip(r)
+}
+
+// installationAuth authenticates Git over HTTPS using installation tokens.
+// It implements the go-git http.AuthMethod interface.
+type installationAuth struct {
+	app *GitHubApp
+}
+
+func (a *installationAuth) Name() string {
+	return "http-github-app"
+}
+
+func (a *installationAuth) String() string {
+	return fmt.Sprintf("%s - app %d", a.Name(), a.app.appID)
+}
+
+// SetAuth authenticates the request using the x-access-token convention for installation tokens.
+func (a *installationAuth) SetAuth(r *http.Request) {
+	token, err := a.app.Token(r.Context())
+	if err != nil {
+		slog.Error("Minting GitHub App installation token", "error", err)
+		return
+	}
+
+	r.SetBasicAuth("x-access-token", token)
+}
@@ -0,0 +1,148 @@
+package auth_test
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.flipt.io/cup/pkg/source/git/auth"
+)
+
+func Test_GitHubApp(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	pemKey := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+
+	var (
+		minted   atomic.Int32
+		lifetime = time.Hour
+	)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/owner/repo/installation", func(w http.ResponseWriter, r *http.Request) {
+		verifyJWT(t, &key.PublicKey, r)
+		_, _ = w.Write([]byte(`{"id":42}`))
+	})
+	mux.HandleFunc("/app/installations/42/access_tokens", func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodPost, r.Method)
+		verifyJWT(t, &key.PublicKey, r)
+
+		n := minted.Add(1)
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"token":      fmt.Sprintf("token-%d", n),
+			"expires_at": time.Now().Add(lifetime),
+		})
+	})
+	mux.HandleFunc("/api", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.Header.Get("Authorization")))
+	})
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	app, err := auth.NewGitHubApp(1234, pemKey,
+		auth.WithRepository("owner", "repo"),
+		auth.WithBaseURL(srv.URL),
+	)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	// installation is discovered from the repository and the token is cached
+	token, err := app.Token(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "token-1", token)
+
+	token, err = app.Token(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "token-1", token)
+
+	resp, err := app.Client().Get(srv.URL + "/api")
+	require.NoError(t, err)
+	header, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	assert.Equal(t, "Bearer token-1", string(header))
+
+	method, ok := app.AuthMethod().(githttp.AuthMethod)
+	require.True(t, ok, "expected go-git http auth method")
+
+	req := httptest.NewRequest(http.MethodGet, srv.URL, nil)
+	method.SetAuth(req)
+	user, pass, ok := req.BasicAuth()
+	require.True(t, ok)
+	assert.Equal(t, "x-access-token", user)
+	assert.Equal(t, "token-1", pass)
+
+	t.Run("refreshes tokens close to expiry", func(t *testing.T) {
+		lifetime = time.Minute
+
+		app, err := auth.NewGitHubApp(1234, pemKey,
+			auth.WithInstallationID(42),
+			auth.WithBaseURL(srv.URL),
+		)
+		require.NoError(t, err)
+
+		first, err := app.Token(ctx)
+		require.NoError(t, err)
+
+		second, err := app.Token(ctx)
+		require.NoError(t, err)
+
+		assert.NotEqual(t, first, second)
+	})
+
+	t.Run("requires installation or repository", func(t *testing.T) {
+		_, err := auth.NewGitHubApp(1234, pemKey)
+		require.ErrorContains(t, err, "installation ID or repository")
+	})
+
+	t.Run("invalid private key", func(t *testing.T) {
+		_, err := auth.NewGitHubApp(1234, []byte("not a key"), auth.WithInstallationID(42))
+		require.ErrorContains(t, err, "PEM")
+	})
+}
+
+// verifyJWT asserts that the request is authenticated as app 1234 using a token signed by the provided key.
+func verifyJWT(t *testing.T, key *rsa.PublicKey, r *http.Request) {
+	t.Helper()
+
+	jwt, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	require.True(t, ok)
+
+	parts := strings.Split(jwt, ".")
+	require.Len(t, parts, 3)
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	require.NoError(t, err)
+
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	require.NoError(t, rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature))
+
+	data, err := base64.RawURLEncoding.DecodeString(parts[1])
+	require.NoError(t, err)
+
+	var claims struct {
+		Issuer string `json:"iss"`
+	}
+	require.NoError(t, json.Unmarshal(data, &claims))
+	assert.Equal(t, "1234", claims.Issuer)
+}
@@ -0,0 +1,200 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/oklog/ulid/v2"
+	"go.flipt.io/cup/pkg/api"
+)
+
+// trailerAutoMerge is the commit trailer recording that a proposal is merged once its checks pass.
+// It allows polling to resume for proposals which were open before the source was started.
+const trailerAutoMerge = "Cup-Auto-Merge"
+
+// AutoMerger is an optional extension of SCM.
+// Implementations natively merge a proposal once its required checks pass.
+type AutoMerger interface {
+	// AutoMerge enables the automatic merge of the open proposal identified by id.
+	AutoMerge(_ context.Context, id ulid.ULID) error
+}
+
+// CheckStatus is the combined state of the checks on the head of a proposal.
+type CheckStatus string
+
+const (
+	// CheckStatusPending is a proposal with checks which have yet to complete.
+	CheckStatusPending = CheckStatus("pending")
+	// CheckStatusSuccess is a proposal whose checks have all passed.
+	CheckStatusSuccess = CheckStatus("success")
+	// CheckStatusFailure is a proposal with at least one failed check.
+	CheckStatusFailure = CheckStatus("failure")
+)
+
+// Checker is an optional extension of SCM.
+// Implementations report the status of the checks on a proposal, which the source polls
+// in order to merge proposals when the SCM cannot do so natively.
+type Checker interface {
+	// Checks returns the combined status of the checks on the open proposal identified by id.
+	Checks(_ context.Context, id ulid.ULID) (CheckStatus, error)
+}
+
+// Check is the status of a single named check (e.g. a commit status or check run) on a proposal.
+type Check struct {
+	Name   string
+	Status CheckStatus
+}
+
+// CombineChecks returns the combined status of the checks on a proposal.
+// When the base of the proposal requires checks, only those checks are considered
+// and any which have yet to report are pending.
+// Otherwise every check must pass and a proposal without any checks is pending,
+// as its checks are not reported until CI has registered them after a push.
+// The last of any checks with the same name takes precedence.
+func CombineChecks(checks []Check, required []string) CheckStatus {
+	statuses := map[string]CheckStatus{}
+	for _, check := range checks {
+		statuses[check.Name] = check.Status
+	}
+
+	names := required
+	if len(names) == 0 {
+		if len(statuses) == 0 {
+			return CheckStatusPending
+		}
+
+		for name := range statuses {
+			names = append(names, name)
+		}
+	}
+
+	combined := CheckStatusSuccess
+	for _, name := range names {
+		switch status, ok := statuses[name]; {
+		case status == CheckStatusFailure:
+			return CheckStatusFailure
+		case !ok || status == CheckStatusPending:
+			combined = CheckStatusPending
+		}
+	}
+
+	return combined
+}
+
+// canAutoMerge returns an error wrapping errors.ErrUnsupported when the SCM
+// can neither merge proposals natively nor report the status of their checks.
+func (s *Source) canAutoMerge() error {
+	switch s.scm.(type) {
+	case AutoMerger, Checker:
+		return nil
+	default:
+		return fmt.Errorf("auto-merge: SCM supports neither native auto-merge nor checks: %w", errors.ErrUnsupported)
+	}
+}
+
+// enableAutoMerge enables the native auto-merge of the proposal identified by id
+// where supported, falling back to polling its checks.
+// The resulting state is recorded on the tracked proposal.
+func (s *Source) enableAutoMerge(ctx context.Context, id ulid.ULID) {
+	state := &api.AutoMerge{State: api.AutoMergeStatePending}
+
+	merger, ok := s.scm.(AutoMerger)
+	if ok {
+		err := merger.AutoMerge(ctx, id)
+		if err == nil {
+			state.Native = true
+		} else if _, polled := s.scm.(Checker); polled {
+			s.logger.Warn("Enabling native auto-merge, falling back to polling checks", "proposal", id, "error", err)
+		} else {
+			state.State, state.Message = api.AutoMergeStateFailed, err.Error()
+		}
+	}
+
+	s.setAutoMerge(id, state)
+}
+
+// setAutoMerge records the state of the automatic merge of the tracked proposal identified by id.
+func (s *Source) setAutoMerge(id ulid.ULID, state *api.AutoMerge) {
+	s.trackMu.Lock()
+	defer s.trackMu.Unlock()
+
+	if t, ok := s.tracked[id]; ok {
+		t.autoMerge = state
+	}
+}
+
+// autoMerge polls the checks of each tracked proposal which is merged once they pass,
+// but which the SCM does not merge natively.
+// Proposals whose checks have passed are merged and no longer tracked.
+// Those with failed checks, or which cannot be merged, are marked as failed
+// and are polled again until they are merged or closed.
+func (s *Source) autoMerge(ctx context.Context) {
+	checker, ok := s.scm.(Checker)
+	if !ok {
+		return
+	}
+
+	s.trackMu.Lock()
+	var ids []ulid.ULID
+	for id, t := range s.tracked {
+		if t.autoMerge != nil && !t.autoMerge.Native && len(t.conflicts) == 0 {
+			ids = append(ids, id)
+		}
+	}
+	s.trackMu.Unlock()
+
+	for _, id := range ids {
+		status, err := checker.Checks(ctx, id)
+		if err != nil {
+			if errors.Is(err, api.ErrProposalNotFound) {
+				// the proposal has been merged or closed elsewhere
+				s.untrack(id)
+				continue
+			}
+
+			s.logger.Error("Checking proposal", "proposal", id, "error", err)
+			continue
+		}
+
+		switch status {
+		case CheckStatusSuccess:
+			if err := s.scm.Merge(ctx, id); err != nil {
+				s.logger.Warn("Merging proposal", "proposal", id, "error", err)
+				s.setAutoMerge(id, &api.AutoMerge{State: api.AutoMergeStateFailed, Message: err.Error()})
+				continue
+			}
+
+			s.logger.Info("Merged proposal once checks passed", "proposal", id)
+			s.untrack(id)
+		case CheckStatusFailure:
+			s.setAutoMerge(id, &api.AutoMerge{State: api.AutoMergeStateFailed, Message: "checks failed"})
+		default:
+			s.setAutoMerge(id, &api.AutoMerge{State: api.AutoMergeStatePending})
+		}
+	}
+}
+
+// autoMergeState returns the state of the automatic merge of the proposal
+// given whether it was requested (as recorded in the trailers of the proposal)
+// and the state reported by the SCM.
+// Proposals which are no longer tracked have either been merged or closed.
+func autoMergeState(proposal *api.Proposal, requested bool) *api.AutoMerge {
+	if !requested && proposal.AutoMerge == nil {
+		return nil
+	}
+
+	state := &api.AutoMerge{State: api.AutoMergeStatePending}
+	if proposal.AutoMerge != nil {
+		state.Native = proposal.AutoMerge.Native
+	}
+
+	switch proposal.Status {
+	case api.ProposalStatusMerged:
+		state.State = api.AutoMergeStateMerged
+	case api.ProposalStatusClosed:
+		state.State, state.Message = api.AutoMergeStateFailed, "proposal closed"
+	}
+
+	return state
+}
@@ -0,0 +1,157 @@
+package git_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.flipt.io/cup/pkg/api"
+	"go.flipt.io/cup/pkg/controllers"
+	"go.flipt.io/cup/pkg/source/git"
+	memscm "go.flipt.io/cup/pkg/source/git/scm/mem"
+)
+
+func Test_Source_AutoMerge(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	write := func(f controllers.FSConfig) error {
+		fi, err := f.ToFS().Create(bazPath)
+		if err != nil {
+			return err
+		}
+
+		defer fi.Close()
+
+		_, err = fi.Write([]byte(`{"spec":{"value":"proposed"}}`))
+		return err
+	}
+
+	// propose sets up a source with a single open proposal which is merged once its checks pass
+	propose := func(t *testing.T) (*git.Source, *memscm.SCM, *localRemote, ulid.ULID) {
+		t.Helper()
+
+		scm := memscm.New()
+		fss, remote, skipped := testLocalSource(t, ctx, scm, git.WithPollInterval(0))
+		if skipped {
+			t.SkipNow()
+		}
+
+		result, err := fss.Update(ctx, "main", "feat: update `baz` resource", write, api.WithAutoMerge(true))
+		require.NoError(t, err)
+
+		scm.SetChecks(result.ID, git.CheckStatusPending)
+
+		return fss, scm, remote, result.ID
+	}
+
+	autoMerge := func(t *testing.T, fss *git.Source, id ulid.ULID) (api.ProposalStatus, *api.AutoMerge) {
+		t.Helper()
+
+		proposal, err := fss.Proposal(ctx, id)
+		require.NoError(t, err)
+
+		return proposal.Status, proposal.AutoMerge
+	}
+
+	t.Run("merged once checks pass", func(t *testing.T) {
+		fss, scm, remote, id := propose(t)
+
+		status, state := autoMerge(t, fss, id)
+		assert.Equal(t, api.ProposalStatusOpen, status)
+		assert.Equal(t, &api.AutoMerge{State: api.AutoMergeStatePending}, state)
+
+		// the request is recorded on the proposal commit
+		origin, err := gogit.PlainOpen(remote.path)
+		require.NoError(t, err)
+
+		ref, err := origin.Reference(plumbing.NewBranchReferenceName(git.ProposalBranch(id)), true)
+		require.NoError(t, err)
+
+		commit, err := origin.CommitObject(ref.Hash())
+		require.NoError(t, err)
+		assert.Contains(t, commit.Message, "Cup-Auto-Merge: true")
+
+		require.NoError(t, fss.Refresh(ctx))
+
+		status, state = autoMerge(t, fss, id)
+		assert.Equal(t, api.ProposalStatusOpen, status)
+		assert.Equal(t, &api.AutoMerge{State: api.AutoMergeStatePending}, state)
+
+		scm.SetChecks(id, git.CheckStatusFailure)
+		require.NoError(t, fss.Refresh(ctx))
+
+		status, state = autoMerge(t, fss, id)
+		assert.Equal(t, api.ProposalStatusOpen, status)
+		assert.Equal(t, &api.AutoMerge{State: api.AutoMergeStateFailed, Message: "checks failed"}, state)
+
+		// checks which pass when re-run still merge the proposal
+		scm.SetChecks(id, git.CheckStatusSuccess)
+		require.NoError(t, fss.Refresh(ctx))
+
+		status, state = autoMerge(t, fss, id)
+		assert.Equal(t, api.ProposalStatusMerged, status)
+		assert.Equal(t, &api.AutoMerge{State: api.AutoMergeStateMerged}, state)
+	})
+
+	t.Run("not merged before checks are reported", func(t *testing.T) {
+		scm := memscm.New()
+		fss, _, skipped := testLocalSource(t, ctx, scm, git.WithPollInterval(0))
+		if skipped {
+			t.SkipNow()
+		}
+
+		// no checks have been registered against the proposal yet
+		result, err := fss.Update(ctx, "main", "feat: update `baz` resource", write, api.WithAutoMerge(true))
+		require.NoError(t, err)
+
+		require.NoError(t, fss.Refresh(ctx))
+
+		status, state := autoMerge(t, fss, result.ID)
+		assert.Equal(t, api.ProposalStatusOpen, status)
+		assert.Equal(t, &api.AutoMerge{State: api.AutoMergeStatePending}, state)
+	})
+
+	t.Run("polling resumes after a restart", func(t *testing.T) {
+		_, scm, remote, id := propose(t)
+
+		fss, err := git.NewSource(ctx, scm, remote.path, git.WithPollInterval(0))
+		require.NoError(t, err)
+
+		status, state := autoMerge(t, fss, id)
+		assert.Equal(t, api.ProposalStatusOpen, status)
+		assert.Equal(t, &api.AutoMerge{State: api.AutoMergeStatePending}, state)
+
+		scm.SetChecks(id, git.CheckStatusSuccess)
+		require.NoError(t, fss.Refresh(ctx))
+
+		status, _ = autoMerge(t, fss, id)
+		assert.Equal(t, api.ProposalStatusMerged, status)
+	})
+
+	t.Run("closed before checks pass", func(t *testing.T) {
+		fss, _, _, id := propose(t)
+
+		require.NoError(t, fss.CloseProposal(ctx, id))
+
+		status, state := autoMerge(t, fss, id)
+		assert.Equal(t, api.ProposalStatusClosed, status)
+		assert.Equal(t, &api.AutoMerge{State: api.AutoMergeStateFailed, Message: "proposal closed"}, state)
+	})
+
+	t.Run("unsupported SCM", func(t *testing.T) {
+		// the SCM is wrapped so that it no longer reports checks
+		fss, _, skipped := testLocalSource(t, ctx, struct{ git.SCM }{memscm.New()}, git.WithPollInterval(0))
+		if skipped {
+			t.SkipNow()
+		}
+
+		_, err := fss.Update(ctx, "main", "feat: update `baz` resource", write, api.WithAutoMerge(true))
+		require.True(t, errors.Is(err, errors.ErrUnsupported), "unexpected error: %v", err)
+	})
+}
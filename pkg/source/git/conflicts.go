@@ -0,0 +1,527 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/oklog/ulid/v2"
+	"go.flipt.io/cup/pkg/api"
+	"go.flipt.io/cup/pkg/api/core"
+	"go.flipt.io/cup/pkg/controllers"
+)
+
+// errConflict is returned when the changes of a proposal cannot be re-applied over its base.
+var errConflict = errors.New("conflict")
+
+// trackedProposal is an open proposal which is reconciled against its base whenever the base moves.
+type trackedProposal struct {
+	base string
+	// checked is the hash of the base the proposal was last reconciled against.
+	checked plumbing.Hash
+	// pushed is the last commit the source pushed to the proposal branch.
+	// Operations are only replayed while the proposal branch remains at this commit,
+	// so that commits pushed to the branch by anyone else are never discarded.
+	pushed plumbing.Hash
+	// operations are the updates which produced the proposal, in order.
+	// They can only be replayed when replayable, which is the case for proposals
+	// made (and only ever amended) since the source was started, until they are discarded.
+	operations []operation
+	replayable bool
+	// conflicts are the paths changed on both the base and the proposal
+	// which could not be rebased or replayed.
+	conflicts []string
+	// autoMerge is the state of the automatic merge of the proposal (if requested).
+	autoMerge *api.AutoMerge
+}
+
+// operation is a single call to Update which contributed to a proposal.
+// The co-authors of rebased and replayed proposals are carried over from the commits they replace.
+type operation struct {
+	message   string
+	fn        api.UpdateFunc
+	options   api.UpdateOptions
+	coAuthors []string
+}
+
+// trackOpen starts tracking the proposals which are already open on the SCM.
+// Their operations are unknown, so they can be rebased but never replayed.
+// Polling their checks resumes for those which are merged once they pass.
+// They are considered reconciled against the current state of their base, unless they
+// have changed any of the same paths, in which case they are marked as conflicted (so that
+// conflicts reported before a restart are not reported again) until they can be rebased.
+func (s *Source) trackOpen(ctx context.Context) {
+	proposals, err := s.scm.List(ctx)
+	if err != nil {
+		s.logger.Warn("Listing open proposals to track", "error", err)
+		return
+	}
+
+	s.trackMu.Lock()
+	defer s.trackMu.Unlock()
+
+	for _, proposal := range proposals {
+		if proposal.Base == "" {
+			continue
+		}
+
+		checked, err := s.resolve(proposal.Base)
+		if err != nil {
+			s.logger.Debug("Resolving proposal base", "proposal", proposal.ID, "base", proposal.Base, "error", err)
+			continue
+		}
+
+		t := &trackedProposal{base: proposal.Base, checked: checked}
+		if d, err := s.diverged(checked, proposal.ID); err == nil && len(d.overlap) > 0 {
+			t.checked, t.conflicts = plumbing.ZeroHash, d.overlap
+		}
+
+		if trailers, err := s.proposalTrailers(proposal.ID, proposal.Head); err == nil && len(trailers[trailerAutoMerge]) > 0 {
+			// polling resumes unless the SCM reports that it merges the proposal natively
+			t.autoMerge = autoMergeState(proposal, true)
+		}
+
+		s.tracked[proposal.ID] = t
+	}
+}
+
+// track records an operation which created (or amended) the proposal identified by id
+// and pushed the commit to its branch.
+// The proposal is considered reconciled against the base the operation was applied to.
+func (s *Source) track(id ulid.ULID, base string, checked, pushed plumbing.Hash, op operation) {
+	s.trackMu.Lock()
+	defer s.trackMu.Unlock()
+
+	amendment := op.options.Proposal != (ulid.ULID{})
+
+	t, ok := s.tracked[id]
+	if !ok {
+		// amendments of proposals made before the source was started cannot be replayed
+		t = &trackedProposal{base: base, replayable: !amendment}
+		s.tracked[id] = t
+	}
+
+	// an amendment is made on top of the proposal branch and so does not move its fork
+	if !amendment || t.checked == plumbing.ZeroHash {
+		t.checked = checked
+	}
+
+	t.pushed = pushed
+	t.operations = append(t.operations, op)
+}
+
+// untrack stops tracking the proposal identified by id.
+func (s *Source) untrack(id ulid.ULID) {
+	s.trackMu.Lock()
+	defer s.trackMu.Unlock()
+
+	delete(s.tracked, id)
+}
+
+// DiscardReplays stops the operations of the proposals tracked so far from being replayed, after
+// which they are only rebased. The operations call the controllers they were made with, so they are
+// discarded before those controllers are closed (such as when the configuration is reloaded).
+func (s *Source) DiscardReplays() {
+	// waits for any replay which is in progress
+	s.reconcileMu.Lock()
+	defer s.reconcileMu.Unlock()
+
+	s.trackMu.Lock()
+	defer s.trackMu.Unlock()
+
+	for _, t := range s.tracked {
+		t.operations, t.replayable = nil, false
+	}
+}
+
+// annotate populates the conflicts and the state of the automatic merge of a tracked proposal.
+func (s *Source) annotate(proposal *api.Proposal) {
+	s.trackMu.Lock()
+	defer s.trackMu.Unlock()
+
+	t, ok := s.tracked[proposal.ID]
+	if !ok {
+		return
+	}
+
+	if len(t.conflicts) > 0 {
+		proposal.Conflicts = slices.Clone(t.conflicts)
+	}
+
+	if t.autoMerge != nil && (proposal.Status == "" || proposal.Status == api.ProposalStatusOpen) {
+		state := *t.autoMerge
+		proposal.AutoMerge = &state
+	}
+}
+
+// reconcile checks each tracked proposal whose base has moved since it was last checked.
+// When the base has changed any of the same files as the proposal, the proposal is rebased
+// onto the new base or, failing that, its operations are replayed over the new base.
+// If neither succeeds, the proposal is marked as conflicted and a comment is added to it.
+// Proposals which are no longer open on the SCM stop being tracked.
+func (s *Source) reconcile(ctx context.Context) {
+	if s.scm == nil {
+		return
+	}
+
+	s.reconcileMu.Lock()
+	defer s.reconcileMu.Unlock()
+
+	s.trackMu.Lock()
+	ids := make([]ulid.ULID, 0, len(s.tracked))
+	for id := range s.tracked {
+		ids = append(ids, id)
+	}
+	s.trackMu.Unlock()
+
+	for _, id := range ids {
+		if err := s.reconcileProposal(ctx, id); err != nil {
+			s.logger.Error("Reconciling proposal", "proposal", id, "error", err)
+		}
+	}
+}
+
+func (s *Source) reconcileProposal(ctx context.Context, id ulid.ULID) error {
+	s.trackMu.Lock()
+	t, ok := s.tracked[id]
+	if !ok {
+		s.trackMu.Unlock()
+		return nil
+	}
+
+	var (
+		base       = t.base
+		checked    = t.checked
+		pushed     = t.pushed
+		operations = slices.Clone(t.operations)
+		replayable = t.replayable
+		conflicted = len(t.conflicts) > 0
+	)
+	s.trackMu.Unlock()
+
+	baseHash, err := s.resolve(base)
+	if err != nil {
+		return fmt.Errorf("resolving base: %w", err)
+	}
+
+	if baseHash == checked {
+		return nil
+	}
+
+	// proposals merged or closed on the SCM (whose branches may since have been deleted) are no longer reconciled
+	proposal, err := s.scm.Get(ctx, id)
+	if errors.Is(err, api.ErrProposalNotFound) {
+		s.untrack(id)
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if proposal.Status != "" && proposal.Status != api.ProposalStatusOpen {
+		s.untrack(id)
+		return nil
+	}
+
+	d, err := s.diverged(baseHash, id)
+	if err != nil {
+		return err
+	}
+
+	overlap := d.overlap
+
+	// the base has moved over other files only, so the proposal still applies as-is
+	if len(overlap) == 0 {
+		s.reconciled(id, baseHash, nil)
+		return nil
+	}
+
+	s.logger.Debug("Base moved over proposal", "proposal", id, "base", base, "paths", overlap)
+
+	trailers, err := s.proposalTrailers(id, s.proposalBranch(id))
+	if err != nil {
+		return fmt.Errorf("reading proposal trailers: %w", err)
+	}
+
+	// rebased and replayed commits retain the resources, auto-merge request and co-authors of the proposal
+	options := api.UpdateOptions{
+		Proposal:  id,
+		Resources: trailers[trailerResource],
+		AutoMerge: len(trailers[trailerAutoMerge]) > 0,
+	}
+
+	rebase := operation{
+		message:   fmt.Sprintf("chore: rebase proposal onto %s", base),
+		fn:        rebaseFunc(d.fork, d.base, d.head, d.headChanges, d.baseChanges),
+		options:   options,
+		coAuthors: trailers[trailerCoAuthor],
+	}
+
+	err = s.reapply(ctx, base, rebase)
+	if err == nil {
+		s.logger.Info("Rebased proposal", "proposal", id, "base", base)
+		s.reconciled(id, baseHash, nil)
+		return nil
+	}
+
+	s.logger.Debug("Rebasing proposal", "proposal", id, "error", err)
+
+	// replaying discards the proposal branch, so it is only done while it holds nothing
+	// but the commits pushed by this source
+	if replayable && d.head.Hash != pushed {
+		s.logger.Debug("Proposal branch moved since it was pushed, skipping replay", "proposal", id)
+	} else if replayable {
+		replay := operation{
+			message: operations[0].message,
+			fn: func(f controllers.FSConfig) error {
+				for _, op := range operations {
+					if err := op.fn(f); err != nil {
+						return err
+					}
+				}
+
+				return nil
+			},
+			options:   options,
+			coAuthors: trailers[trailerCoAuthor],
+		}
+
+		if err = s.reapply(ctx, base, replay); err == nil {
+			s.logger.Info("Replayed proposal", "proposal", id, "base", base)
+			s.reconciled(id, baseHash, nil)
+			return nil
+		}
+
+		s.logger.Debug("Replaying proposal", "proposal", id, "error", err)
+	}
+
+	s.reconciled(id, baseHash, overlap)
+
+	// only comment the first time a proposal becomes conflicted
+	if conflicted {
+		return nil
+	}
+
+	s.logger.Warn("Proposal conflicts with base", "proposal", id, "base", base, "paths", overlap)
+
+	if err := s.scm.Comment(ctx, id, conflictComment(base, overlap)); err != nil {
+		return fmt.Errorf("commenting on conflicted proposal: %w", err)
+	}
+
+	return nil
+}
+
+// reapply recreates the proposal branch from the head of base using the provided operation
+// and force pushes it to origin in place of the existing proposal branch.
+func (s *Source) reapply(ctx context.Context, base string, op operation) error {
+	defer s.lockProposal(op.options.Proposal)()
+
+	// the messages of rebased and replayed commits are not templated
+	change, err := s.commit(ctx, base, op.message, op.fn, op.options, core.Templates{}, op.coAuthors)
+	if err != nil {
+		return err
+	}
+
+	if change == nil {
+		return fmt.Errorf("%w: proposal has no changes over %q", errConflict, base)
+	}
+
+	if err := s.push(ctx, change, change.branch, true); err != nil {
+		return fmt.Errorf("pushing changes: %w", err)
+	}
+
+	s.trackMu.Lock()
+	defer s.trackMu.Unlock()
+
+	if t, ok := s.tracked[change.id]; ok {
+		t.pushed = change.commit
+	}
+
+	return nil
+}
+
+// divergence describes how a proposal and its base have changed since the proposal forked from the base.
+type divergence struct {
+	fork, base, head *object.Commit
+	// baseChanges and headChanges are the paths changed on the base and proposal since the fork
+	// and overlap are the paths changed on both.
+	baseChanges, headChanges, overlap []string
+}
+
+// diverged compares the proposal identified by id with the provided hash of its base.
+func (s *Source) diverged(baseHash plumbing.Hash, id ulid.ULID) (*divergence, error) {
+	headHash, err := s.resolve(s.proposalBranch(id))
+	if err != nil {
+		return nil, fmt.Errorf("resolving proposal: %w", err)
+	}
+
+	d := &divergence{}
+
+	d.base, err = s.repo.CommitObject(baseHash)
+	if err != nil {
+		return nil, err
+	}
+
+	d.head, err = s.repo.CommitObject(headHash)
+	if err != nil {
+		return nil, err
+	}
+
+	// the fork is the commit on the base which the proposal was made from
+	forks, err := d.head.MergeBase(d.base)
+	if err != nil {
+		return nil, fmt.Errorf("finding merge base: %w", err)
+	}
+
+	if len(forks) == 0 {
+		return nil, errors.New("proposal shares no history with its base")
+	}
+
+	d.fork = forks[0]
+
+	d.baseChanges, err = changedPaths(d.fork, d.base)
+	if err != nil {
+		return nil, err
+	}
+
+	d.headChanges, err = changedPaths(d.fork, d.head)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, path := range d.headChanges {
+		if slices.Contains(d.baseChanges, path) {
+			d.overlap = append(d.overlap, path)
+		}
+	}
+
+	return d, nil
+}
+
+// reconciled records the base hash the proposal identified by id has been reconciled against,
+// along with any paths which conflict with it.
+func (s *Source) reconciled(id ulid.ULID, checked plumbing.Hash, conflicts []string) {
+	s.trackMu.Lock()
+	defer s.trackMu.Unlock()
+
+	if t, ok := s.tracked[id]; ok {
+		t.checked = checked
+		t.conflicts = conflicts
+	}
+}
+
+// rebaseFunc returns an update which re-applies the changes the proposal made since the fork
+// over the new base. It fails when the base has changed any of the same paths to different contents.
+func rebaseFunc(fork, base, head *object.Commit, headChanges, baseChanges []string) api.UpdateFunc {
+	return func(f controllers.FSConfig) error {
+		fs := f.ToFS()
+		for _, path := range headChanges {
+			contents, exists, err := fileContents(head, path)
+			if err != nil {
+				return err
+			}
+
+			if slices.Contains(baseChanges, path) {
+				baseContents, baseExists, err := fileContents(base, path)
+				if err != nil {
+					return err
+				}
+
+				if contents != baseContents || exists != baseExists {
+					return fmt.Errorf("%w: %s", errConflict, path)
+				}
+
+				continue
+			}
+
+			if !exists {
+				if err := fs.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+					return err
+				}
+
+				continue
+			}
+
+			fi, err := fs.Create(path)
+			if err != nil {
+				return err
+			}
+
+			if _, err := fi.Write([]byte(contents)); err != nil {
+				_ = fi.Close()
+				return err
+			}
+
+			if err := fi.Close(); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+}
+
+// changedPaths returns the paths which differ between the trees of the provided commits.
+func changedPaths(from, to *object.Commit) (paths []string, _ error) {
+	fromTree, err := from.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	toTree, err := to.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := object.DiffTree(fromTree, toTree)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, change := range changes {
+		for _, path := range []string{change.From.Name, change.To.Name} {
+			if path != "" && !slices.Contains(paths, path) {
+				paths = append(paths, path)
+			}
+		}
+	}
+
+	return paths, nil
+}
+
+// fileContents returns the contents of the file at path in the provided commit
+// and whether or not it exists.
+func fileContents(commit *object.Commit, path string) (string, bool, error) {
+	file, err := commit.File(path)
+	if errors.Is(err, object.ErrFileNotFound) {
+		return "", false, nil
+	}
+
+	if err != nil {
+		return "", false, err
+	}
+
+	contents, err := file.Contents()
+	return contents, true, err
+}
+
+// conflictComment describes the conflict between a proposal and its base.
+func conflictComment(base string, paths []string) string {
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "The base branch `%s` has changed the following files since this proposal was made:\n\n", base)
+	for _, path := range paths {
+		fmt.Fprintf(b, "- `%s`\n", path)
+	}
+
+	fmt.Fprintln(b)
+	fmt.Fprint(b, "cup could not automatically rebase or replay the proposal onto the new base. ")
+	fmt.Fprint(b, "Please resolve the conflict manually, or close this proposal and apply the change again.")
+
+	return b.String()
+}
@@ -0,0 +1,319 @@
+package git_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.flipt.io/cup/pkg/api"
+	"go.flipt.io/cup/pkg/containers"
+	"go.flipt.io/cup/pkg/controllers"
+	"go.flipt.io/cup/pkg/source/git"
+	memscm "go.flipt.io/cup/pkg/source/git/scm/mem"
+)
+
+func Test_Source_Reconcile(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	const proposed = `{"spec":{"value":"proposed"}}`
+
+	write := func(path, contents string) api.UpdateFunc {
+		return func(f controllers.FSConfig) error {
+			fi, err := f.ToFS().Create(path)
+			if err != nil {
+				return err
+			}
+
+			defer fi.Close()
+
+			_, err = fi.Write([]byte(contents))
+			return err
+		}
+	}
+
+	caller := &api.Caller{Name: "Alice", Email: "alice@flipt.io"}
+
+	// propose sets up a source with a single open proposal which writes baz on behalf of caller
+	propose := func(t *testing.T, fn api.UpdateFunc, opts ...containers.Option[api.UpdateOptions]) (*git.Source, *memscm.SCM, *localRemote, ulid.ULID) {
+		t.Helper()
+
+		scm := memscm.New()
+		fss, remote, skipped := testLocalSource(t, ctx, scm, git.WithPollInterval(0))
+		if skipped {
+			t.SkipNow()
+		}
+
+		result, err := fss.Update(api.WithCaller(ctx, caller), "main", "feat: update `baz` resource", fn, opts...)
+		require.NoError(t, err)
+
+		return fss, scm, remote, result.ID
+	}
+
+	// proposalHead returns the head commit of the proposal branch on origin
+	proposalHead := func(t *testing.T, remote *localRemote, id ulid.ULID) plumbing.Hash {
+		t.Helper()
+
+		origin, err := gogit.PlainOpen(remote.path)
+		require.NoError(t, err)
+
+		ref, err := origin.Reference(plumbing.NewBranchReferenceName(git.ProposalBranch(id)), true)
+		require.NoError(t, err)
+
+		return ref.Hash()
+	}
+
+	// pushProposal commits the provided message over the proposal branch on origin
+	// as though it were pushed by someone other than the source
+	pushProposal := func(t *testing.T, remote *localRemote, id ulid.ULID, message string) plumbing.Hash {
+		t.Helper()
+
+		origin, err := gogit.PlainOpen(remote.path)
+		require.NoError(t, err)
+
+		parent, err := origin.CommitObject(proposalHead(t, remote, id))
+		require.NoError(t, err)
+
+		signature := object.Signature{Name: "test", Email: "test@flipt.io", When: time.Now()}
+		obj := origin.Storer.NewEncodedObject()
+		require.NoError(t, (&object.Commit{
+			Author:       signature,
+			Committer:    signature,
+			Message:      message,
+			TreeHash:     parent.TreeHash,
+			ParentHashes: []plumbing.Hash{parent.Hash},
+		}).Encode(obj))
+
+		hash, err := origin.Storer.SetEncodedObject(obj)
+		require.NoError(t, err)
+
+		ref := plumbing.NewHashReference(plumbing.NewBranchReferenceName(git.ProposalBranch(id)), hash)
+		require.NoError(t, origin.Storer.SetReference(ref))
+
+		return hash
+	}
+
+	t.Run("base moves over other files", func(t *testing.T) {
+		fss, scm, remote, id := propose(t, write(bazPath, proposed))
+
+		head := proposalHead(t, remote, id)
+
+		remote.commit(t, map[string]string{"default/other.json": "{}"})
+		require.NoError(t, fss.Refresh(ctx))
+
+		assert.Equal(t, head, proposalHead(t, remote, id))
+
+		proposal, err := fss.Proposal(ctx, id)
+		require.NoError(t, err)
+		assert.Empty(t, proposal.Conflicts)
+		assert.Empty(t, scm.Comments(id))
+	})
+
+	t.Run("base moves over the same files", func(t *testing.T) {
+		fss, scm, remote, id := propose(t, write(bazPath, proposed), api.WithAutoMerge(true))
+
+		moved := remote.commit(t, map[string]string{bazPath: `{"spec":{"value":"moved"}}`})
+		require.NoError(t, fss.Refresh(ctx))
+
+		origin, err := gogit.PlainOpen(remote.path)
+		require.NoError(t, err)
+
+		// the operation is replayed over the new base
+		commit, err := origin.CommitObject(proposalHead(t, remote, id))
+		require.NoError(t, err)
+		assert.Equal(t, []plumbing.Hash{moved}, commit.ParentHashes)
+
+		file, err := commit.File(bazPath)
+		require.NoError(t, err)
+		contents, err := file.Contents()
+		require.NoError(t, err)
+		assert.Equal(t, proposed, contents)
+
+		// the auto-merge request and co-authors of the proposal are carried over
+		assert.Contains(t, commit.Message, "Cup-Auto-Merge: true")
+		assert.Contains(t, commit.Message, "Co-authored-by: Alice <alice@flipt.io>")
+
+		proposal, err := fss.Proposal(ctx, id)
+		require.NoError(t, err)
+		assert.Empty(t, proposal.Conflicts)
+		assert.Empty(t, scm.Comments(id))
+	})
+
+	t.Run("proposal branch moved by someone else", func(t *testing.T) {
+		var calls int
+		fss, _, remote, id := propose(t, func(f controllers.FSConfig) error {
+			// the operation writes different contents when replayed
+			calls++
+			if calls > 1 {
+				return write(bazPath, `{"spec":{"value":"replayed"}}`)(f)
+			}
+
+			return write(bazPath, proposed)(f)
+		})
+
+		head := pushProposal(t, remote, id, "fix: amend the proposal by hand")
+
+		remote.commit(t, map[string]string{bazPath: `{"spec":{"value":"moved"}}`})
+		require.NoError(t, fss.Refresh(ctx))
+
+		// the operation is not replayed over commits it did not push
+		assert.Equal(t, head, proposalHead(t, remote, id))
+
+		proposal, err := fss.Proposal(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, []string{bazPath}, proposal.Conflicts)
+	})
+
+	t.Run("conflicting operation", func(t *testing.T) {
+		var calls int
+		fss, scm, remote, id := propose(t, func(f controllers.FSConfig) error {
+			// the operation only succeeds when first applied
+			calls++
+			if calls > 1 {
+				return errors.New("resource has changed")
+			}
+
+			return write(bazPath, proposed)(f)
+		})
+
+		head := proposalHead(t, remote, id)
+
+		remote.commit(t, map[string]string{bazPath: `{"spec":{"value":"moved"}}`})
+		require.NoError(t, fss.Refresh(ctx))
+
+		// the proposal is left in place and marked as conflicted
+		assert.Equal(t, head, proposalHead(t, remote, id))
+
+		proposal, err := fss.Proposal(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, []string{bazPath}, proposal.Conflicts)
+
+		comments := scm.Comments(id)
+		require.Len(t, comments, 1)
+		assert.Contains(t, comments[0], bazPath)
+
+		// conflicts are only commented on once
+		remote.commit(t, map[string]string{bazPath: `{"spec":{"value":"moved again"}}`})
+		require.NoError(t, fss.Refresh(ctx))
+
+		assert.Len(t, scm.Comments(id), 1)
+
+		// conflicts are derived again once the source is restarted
+		restarted, err := git.NewSource(ctx, scm, remote.path, git.WithPollInterval(0))
+		require.NoError(t, err)
+
+		proposal, err = restarted.Proposal(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, []string{bazPath}, proposal.Conflicts)
+
+		require.NoError(t, restarted.Refresh(ctx))
+		assert.Len(t, scm.Comments(id), 1)
+
+		// closed proposals are no longer reconciled
+		require.NoError(t, fss.CloseProposal(ctx, id))
+
+		proposal, err = fss.Proposal(ctx, id)
+		require.NoError(t, err)
+		assert.Empty(t, proposal.Conflicts)
+	})
+
+	t.Run("discarded replays", func(t *testing.T) {
+		var calls int
+		fss, _, remote, id := propose(t, func(f controllers.FSConfig) error {
+			calls++
+			return write(bazPath, proposed)(f)
+		})
+
+		head := proposalHead(t, remote, id)
+
+		// as when the controllers which made the proposal are replaced
+		fss.DiscardReplays()
+
+		remote.commit(t, map[string]string{bazPath: `{"spec":{"value":"moved"}}`})
+		require.NoError(t, fss.Refresh(ctx))
+
+		// the operation is not replayed, so the proposal is marked as conflicted
+		assert.Equal(t, 1, calls)
+		assert.Equal(t, head, proposalHead(t, remote, id))
+
+		proposal, err := fss.Proposal(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, []string{bazPath}, proposal.Conflicts)
+	})
+
+	t.Run("proposal merged on the SCM", func(t *testing.T) {
+		var calls int
+		scm := &countingSCM{SCM: memscm.New()}
+		fss, remote, skipped := testLocalSource(t, ctx, scm, git.WithPollInterval(0))
+		if skipped {
+			t.SkipNow()
+		}
+
+		result, err := fss.Update(api.WithCaller(ctx, caller), "main", "feat: update `baz` resource", func(f controllers.FSConfig) error {
+			calls++
+			return write(bazPath, proposed)(f)
+		})
+		require.NoError(t, err)
+
+		id := result.ID
+
+		// the proposal is merged by a merge commit made outside of the source
+		require.NoError(t, scm.Merge(ctx, id))
+
+		origin, err := gogit.PlainOpen(remote.path)
+		require.NoError(t, err)
+
+		head, err := origin.CommitObject(proposalHead(t, remote, id))
+		require.NoError(t, err)
+
+		base, err := origin.Reference(plumbing.NewBranchReferenceName("main"), true)
+		require.NoError(t, err)
+
+		signature := object.Signature{Name: "test", Email: "test@flipt.io", When: time.Now()}
+		obj := origin.Storer.NewEncodedObject()
+		require.NoError(t, (&object.Commit{
+			Author:       signature,
+			Committer:    signature,
+			Message:      "Merge proposal",
+			TreeHash:     head.TreeHash,
+			ParentHashes: []plumbing.Hash{base.Hash(), head.Hash},
+		}).Encode(obj))
+
+		merge, err := origin.Storer.SetEncodedObject(obj)
+		require.NoError(t, err)
+		require.NoError(t, origin.Storer.SetReference(plumbing.NewHashReference(base.Name(), merge)))
+
+		require.NoError(t, fss.Refresh(ctx))
+
+		// the merged proposal is no longer tracked, so it is not looked up again as the base moves
+		remote.commit(t, map[string]string{"default/other.json": "{}"})
+		require.NoError(t, fss.Refresh(ctx))
+
+		assert.Equal(t, int32(1), scm.gets.Load())
+		assert.Equal(t, 1, calls)
+		assert.Empty(t, scm.Comments(id))
+
+		proposal, err := fss.Proposal(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, api.ProposalStatusMerged, proposal.Status)
+	})
+}
+
+// countingSCM counts the proposals looked up on the wrapped SCM.
+type countingSCM struct {
+	*memscm.SCM
+	gets atomic.Int32
+}
+
+func (s *countingSCM) Get(ctx context.Context, id ulid.ULID) (*api.Proposal, error) {
+	s.gets.Add(1)
+	return s.SCM.Get(ctx, id)
+}
@@ -0,0 +1,304 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/oklog/ulid/v2"
+	"go.flipt.io/cup/pkg/api"
+	"go.flipt.io/cup/pkg/containers"
+)
+
+var _ SCM = (*localSCM)(nil)
+
+// NewLocalSource constructs a Git Source over an existing repository on disk at path
+// (or any of its subdirectories), which requires neither a remote nor an SCM.
+// Any revision of the repository can be viewed.
+// Updates commit directly to the branches of the repository: direct updates move the target
+// branch, while proposals are made as local branches (which are merged by fast-forwarding
+// their base onto them and closed by deleting them).
+// A target branch which is checked out must have no uncommitted changes to tracked files,
+// as the worktree is reset to the new commit.
+// The auth, cache directory, depth and branches options do not apply to local sources.
+// Subscribers are signalled whenever a revision moves, including by commits made outside
+// the source, as observed on each poll interval and call to Refresh.
+func NewLocalSource(ctx context.Context, path string, opts ...containers.Option[Source]) (*Source, error) {
+	fs, err := newSource(nil, path, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	fs.local = true
+	fs.scm = &localSCM{source: fs}
+
+	fs.repo, err = git.PlainOpenWithOptions(path, &git.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return nil, fmt.Errorf("opening repository: %w", err)
+	}
+
+	fs.storage = fs.repo.Storer
+
+	fs.trackOpen(ctx)
+
+	if fs.interval > 0 {
+		go fs.pollRefs(ctx)
+	}
+
+	return fs, nil
+}
+
+// setBranch moves the target branch of the local repository onto the commit of the change.
+// Unless forced, an existing branch must not have moved from the base of the change.
+func (s *Source) setBranch(ctx context.Context, change *change, target string, force bool) error {
+	s.logger.Debug("Committing Changes", slog.String("target", target), slog.Bool("force", force))
+
+	name := plumbing.NewBranchReferenceName(target)
+
+	old, err := s.repo.Reference(name, false)
+	switch {
+	case errors.Is(err, plumbing.ErrReferenceNotFound):
+		old = nil
+	case err != nil:
+		return err
+	case !force && old.Hash() != change.base:
+		return fmt.Errorf("%w: %q has moved", git.ErrNonFastForwardUpdate, target)
+	}
+
+	if err := s.moveBranch(plumbing.NewHashReference(name, change.commit), old); err != nil {
+		return err
+	}
+
+	// signal subscribers to the branch without waiting for the next poll
+	return s.fetch(ctx)
+}
+
+// moveBranch sets the branch reference of the local repository, provided it is still old (if any).
+// When the branch is checked out, the worktree is reset to match it, which requires
+// that the worktree has no uncommitted changes to tracked files.
+func (s *Source) moveBranch(ref, old *plumbing.Reference) error {
+	s.branchMu.Lock()
+	defer s.branchMu.Unlock()
+
+	work, err := s.checkedOut(ref.Name())
+	if err != nil {
+		return err
+	}
+
+	if err := s.storage.CheckAndSetReference(ref, old); err != nil {
+		return fmt.Errorf("%w: %w", git.ErrNonFastForwardUpdate, err)
+	}
+
+	if work == nil {
+		return nil
+	}
+
+	if err := work.Reset(&git.ResetOptions{Commit: ref.Hash(), Mode: git.HardReset}); err != nil {
+		return fmt.Errorf("updating worktree: %w", err)
+	}
+
+	return nil
+}
+
+// checkedOut returns the worktree of the local repository when it has the named branch checked out.
+// It returns an error when the worktree has uncommitted changes to tracked files.
+func (s *Source) checkedOut(name plumbing.ReferenceName) (*git.Worktree, error) {
+	head, err := s.storage.Reference(plumbing.HEAD)
+	if err != nil || head.Type() != plumbing.SymbolicReference || head.Target() != name {
+		return nil, nil
+	}
+
+	work, err := s.repo.Worktree()
+	if errors.Is(err, git.ErrIsBareRepository) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	status, err := work.Status()
+	if err != nil {
+		return nil, fmt.Errorf("getting status: %w", err)
+	}
+
+	for path, file := range status {
+		if file.Staging != git.Unmodified && file.Staging != git.Untracked ||
+			file.Worktree != git.Unmodified && file.Worktree != git.Untracked {
+			return nil, fmt.Errorf("branch %q is checked out with uncommitted changes to %q", name.Short(), path)
+		}
+	}
+
+	return work, nil
+}
+
+// localSCM manages the proposals of a local source as branches of the repository.
+// The base of each proposal is recorded as the merge branch in its branch configuration.
+type localSCM struct {
+	source *Source
+}
+
+// Propose records the base of the proposal branch, which has already been committed.
+func (l *localSCM) Propose(_ context.Context, p Proposal) (*api.Proposal, error) {
+	repo := l.source.repo
+
+	cfg, err := repo.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.Branches[p.Head] = &config.Branch{
+		Name:  p.Head,
+		Merge: plumbing.NewBranchReferenceName(p.Base),
+	}
+
+	if err := repo.SetConfig(cfg); err != nil {
+		return nil, err
+	}
+
+	return localProposal(p.ID, p.Head, p.Base), nil
+}
+
+// List returns a proposal for each proposal branch of the repository.
+func (l *localSCM) List(context.Context) (proposals []*api.Proposal, _ error) {
+	refs, err := l.source.repo.Branches()
+	if err != nil {
+		return nil, fmt.Errorf("listing: %w", err)
+	}
+
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		if id, ok := ProposalID(ref.Name().Short()); ok {
+			proposals = append(proposals, localProposal(id, ref.Name().Short(), l.base(ref.Name().Short())))
+		}
+
+		return nil
+	})
+
+	return proposals, err
+}
+
+// Get returns the open proposal identified by id.
+// Proposals which have been merged or closed no longer exist.
+func (l *localSCM) Get(_ context.Context, id ulid.ULID) (*api.Proposal, error) {
+	head, err := l.head(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return localProposal(id, head.Name().Short(), l.base(head.Name().Short())), nil
+}
+
+// Merge fast-forwards the base of the proposal identified by id onto its head
+// and then removes the proposal branch.
+// Proposals which cannot be fast-forwarded must first be rebased onto their base.
+func (l *localSCM) Merge(ctx context.Context, id ulid.ULID) error {
+	head, err := l.head(id)
+	if err != nil {
+		return fmt.Errorf("merging: %w", err)
+	}
+
+	base := l.base(head.Name().Short())
+	if base == "" {
+		return fmt.Errorf("merging: proposal %q has no base", id)
+	}
+
+	repo := l.source.repo
+
+	old, err := repo.Reference(plumbing.NewBranchReferenceName(base), false)
+	if err != nil {
+		return fmt.Errorf("merging: %w", err)
+	}
+
+	baseCommit, err := repo.CommitObject(old.Hash())
+	if err != nil {
+		return fmt.Errorf("merging: %w", err)
+	}
+
+	headCommit, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		return fmt.Errorf("merging: %w", err)
+	}
+
+	if ok, err := baseCommit.IsAncestor(headCommit); err != nil || !ok {
+		return fmt.Errorf("proposal %q could not be merged: %q cannot be fast-forwarded", id, base)
+	}
+
+	if err := l.source.moveBranch(plumbing.NewHashReference(old.Name(), head.Hash()), old); err != nil {
+		return fmt.Errorf("merging: %w", err)
+	}
+
+	if err := l.remove(head); err != nil {
+		return err
+	}
+
+	// signal subscribers to the base without waiting for the next poll
+	return l.source.fetch(ctx)
+}
+
+// Close removes the branch of the proposal identified by id without merging it.
+func (l *localSCM) Close(_ context.Context, id ulid.ULID) error {
+	head, err := l.head(id)
+	if err != nil {
+		return fmt.Errorf("closing: %w", err)
+	}
+
+	return l.remove(head)
+}
+
+// Comment logs the comment as local proposals have nowhere to record it.
+func (l *localSCM) Comment(_ context.Context, id ulid.ULID, body string) error {
+	l.source.logger.Info("Proposal comment", "proposal", id, "comment", body)
+
+	return nil
+}
+
+// head returns the reference of the branch for the proposal identified by id.
+func (l *localSCM) head(id ulid.ULID) (*plumbing.Reference, error) {
+	branch := l.source.proposalBranch(id)
+
+	ref, err := l.source.repo.Reference(plumbing.NewBranchReferenceName(branch), false)
+	if errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return nil, fmt.Errorf("%w: %s", api.ErrProposalNotFound, id)
+	}
+
+	return ref, err
+}
+
+// base returns the base branch recorded for the proposal branch (if any).
+func (l *localSCM) base(branch string) string {
+	cfg, err := l.source.repo.Config()
+	if err != nil {
+		return ""
+	}
+
+	if b, ok := cfg.Branches[branch]; ok {
+		return b.Merge.Short()
+	}
+
+	return ""
+}
+
+// remove deletes the proposal branch along with its configuration.
+func (l *localSCM) remove(head *plumbing.Reference) error {
+	repo := l.source.repo
+
+	if err := repo.DeleteBranch(head.Name().Short()); err != nil && !errors.Is(err, git.ErrBranchNotFound) {
+		return err
+	}
+
+	return repo.Storer.RemoveReference(head.Name())
+}
+
+func localProposal(id ulid.ULID, head, base string) *api.Proposal {
+	return &api.Proposal{
+		ID:     id,
+		Source: "local",
+		Status: api.ProposalStatusOpen,
+		Head:   head,
+		Base:   base,
+	}
+}
@@ -0,0 +1,242 @@
+package git_test
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.flipt.io/cup/pkg/api"
+	"go.flipt.io/cup/pkg/controllers"
+	"go.flipt.io/cup/pkg/source/git"
+)
+
+func Test_LocalSource(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	writeBaz := func(f controllers.FSConfig) error {
+		fi, err := f.ToFS().Create(bazPath)
+		if err != nil {
+			return err
+		}
+
+		defer fi.Close()
+
+		_, err = fi.Write(bazContents)
+		return err
+	}
+
+	// setup returns a local source over a new repository, with main checked out
+	// and seeded with the contents of testdata
+	setup := func(t *testing.T) (*git.Source, *gogit.Repository, string, plumbing.Hash) {
+		t.Helper()
+
+		dir := t.TempDir()
+
+		repo, err := gogit.PlainInit(dir, false)
+		require.NoError(t, err)
+		require.NoError(t, repo.Storer.SetReference(plumbing.NewSymbolicReference(plumbing.HEAD, plumbing.Main)))
+
+		for path, contents := range testdataContents {
+			require.NoError(t, os.MkdirAll(filepath.Join(dir, filepath.Dir(path)), 0o755))
+			require.NoError(t, os.WriteFile(filepath.Join(dir, path), contents, 0o644))
+		}
+
+		work, err := repo.Worktree()
+		require.NoError(t, err)
+		require.NoError(t, work.AddWithOptions(&gogit.AddOptions{All: true}))
+
+		seed, err := work.Commit("test: seed resources", &gogit.CommitOptions{
+			Author: &object.Signature{Name: "test", Email: "test@flipt.io", When: time.Now()},
+		})
+		require.NoError(t, err)
+
+		fss, err := git.NewLocalSource(ctx, dir, git.WithPollInterval(0))
+		require.NoError(t, err)
+
+		return fss, repo, dir, seed
+	}
+
+	branch := func(t *testing.T, repo *gogit.Repository, name string) plumbing.Hash {
+		t.Helper()
+
+		ref, err := repo.Reference(plumbing.NewBranchReferenceName(name), true)
+		require.NoError(t, err)
+
+		return ref.Hash()
+	}
+
+	t.Run("view any revision", func(t *testing.T) {
+		fss, _, _, seed := setup(t)
+
+		for _, rev := range []string{"main", seed.String(), "HEAD"} {
+			require.NoError(t, fss.View(ctx, rev, func(f fs.FS) error {
+				data, err := fs.ReadFile(f, "default/test.cup.flipt.io-v1alpha1-Resource-foo.json")
+				require.NoError(t, err)
+				assert.Equal(t, testdataContents["default/test.cup.flipt.io-v1alpha1-Resource-foo.json"], data)
+				return nil
+			}))
+		}
+	})
+
+	t.Run("direct update", func(t *testing.T) {
+		fss, repo, dir, seed := setup(t)
+
+		result, err := fss.Update(ctx, "main", "feat: add `baz` resource", writeBaz, api.WithDirect(true))
+		require.NoError(t, err)
+
+		head := branch(t, repo, "main")
+		assert.Equal(t, result.Commit, head.String())
+
+		commit, err := repo.CommitObject(head)
+		require.NoError(t, err)
+		assert.Equal(t, []plumbing.Hash{seed}, commit.ParentHashes)
+
+		// the checked out worktree is updated to the new commit
+		data, err := os.ReadFile(filepath.Join(dir, bazPath))
+		require.NoError(t, err)
+		assert.Equal(t, bazContents, data)
+
+		work, err := repo.Worktree()
+		require.NoError(t, err)
+
+		status, err := work.Status()
+		require.NoError(t, err)
+		assert.True(t, status.IsClean(), "unexpected status: %v", status)
+	})
+
+	t.Run("subscribe", func(t *testing.T) {
+		fss, repo, dir, _ := setup(t)
+
+		ch := make(chan struct{}, 1)
+		fss.Subscribe(ctx, "main", ch)
+
+		// commit to main outside of the source
+		require.NoError(t, os.WriteFile(filepath.Join(dir, bazPath), bazContents, 0o644))
+
+		work, err := repo.Worktree()
+		require.NoError(t, err)
+		require.NoError(t, work.AddWithOptions(&gogit.AddOptions{All: true}))
+
+		_, err = work.Commit("test: add baz resource", &gogit.CommitOptions{
+			Author: &object.Signature{Name: "test", Email: "test@flipt.io", When: time.Now()},
+		})
+		require.NoError(t, err)
+
+		require.NoError(t, fss.Refresh(ctx))
+
+		select {
+		case <-ch:
+		default:
+			t.Fatal("expected subscriber to be signalled once main moved")
+		}
+
+		// revisions which have not moved since are not signalled again
+		require.NoError(t, fss.Refresh(ctx))
+
+		select {
+		case <-ch:
+			t.Fatal("unexpected signal for unchanged revision")
+		default:
+		}
+	})
+
+	t.Run("direct update over uncommitted changes", func(t *testing.T) {
+		fss, repo, dir, seed := setup(t)
+
+		path := filepath.Join(dir, "default/test.cup.flipt.io-v1alpha1-Resource-foo.json")
+		require.NoError(t, os.WriteFile(path, []byte("{}"), 0o644))
+
+		_, err := fss.Update(ctx, "main", "feat: add `baz` resource", writeBaz, api.WithDirect(true))
+		require.ErrorContains(t, err, "uncommitted changes")
+
+		// neither the branch nor the worktree are touched
+		assert.Equal(t, seed, branch(t, repo, "main"))
+
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("{}"), data)
+	})
+
+	t.Run("proposal branch", func(t *testing.T) {
+		fss, repo, dir, seed := setup(t)
+
+		result, err := fss.Update(ctx, "main", "feat: add `baz` resource", writeBaz)
+		require.NoError(t, err)
+
+		head := git.ProposalBranch(result.ID)
+		assert.Equal(t, &api.Proposal{
+			ID:     result.ID,
+			Source: "local",
+			Status: api.ProposalStatusOpen,
+			Head:   head,
+			Base:   "main",
+		}, result.Proposal)
+
+		// the change is only committed to the proposal branch
+		assert.Equal(t, seed, branch(t, repo, "main"))
+
+		commit, err := repo.CommitObject(branch(t, repo, head))
+		require.NoError(t, err)
+		assert.Equal(t, []plumbing.Hash{seed}, commit.ParentHashes)
+
+		_, err = os.Stat(filepath.Join(dir, bazPath))
+		assert.ErrorIs(t, err, os.ErrNotExist)
+
+		// HEAD is left on main
+		ref, err := repo.Reference(plumbing.HEAD, false)
+		require.NoError(t, err)
+		assert.Equal(t, plumbing.Main, ref.Target())
+
+		proposals, err := fss.Proposals(ctx)
+		require.NoError(t, err)
+		require.Len(t, proposals, 1)
+		assert.Equal(t, result.ID, proposals[0].ID)
+		assert.Equal(t, "main", proposals[0].Base)
+
+		// merging fast-forwards main onto the proposal
+		require.NoError(t, fss.MergeProposal(ctx, result.ID))
+
+		assert.Equal(t, commit.Hash, branch(t, repo, "main"))
+
+		data, err := os.ReadFile(filepath.Join(dir, bazPath))
+		require.NoError(t, err)
+		assert.Equal(t, bazContents, data)
+
+		_, err = fss.Proposal(ctx, result.ID)
+		assert.ErrorIs(t, err, api.ErrProposalNotFound)
+	})
+
+	t.Run("amend and close proposal", func(t *testing.T) {
+		fss, repo, _, _ := setup(t)
+
+		result, err := fss.Update(ctx, "main", "feat: add `baz` resource", writeBaz)
+		require.NoError(t, err)
+
+		head := git.ProposalBranch(result.ID)
+		first := branch(t, repo, head)
+
+		amended, err := fss.Update(ctx, "main", "fix: remove `foo` resource", func(f controllers.FSConfig) error {
+			return f.ToFS().Remove("default/test.cup.flipt.io-v1alpha1-Resource-foo.json")
+		}, api.WithProposal(result.ID))
+		require.NoError(t, err)
+		assert.Equal(t, result.ID, amended.ID)
+
+		commit, err := repo.CommitObject(branch(t, repo, head))
+		require.NoError(t, err)
+		assert.Equal(t, []plumbing.Hash{first}, commit.ParentHashes)
+
+		require.NoError(t, fss.CloseProposal(ctx, result.ID))
+
+		_, err = repo.Reference(plumbing.NewBranchReferenceName(head), false)
+		assert.ErrorIs(t, err, plumbing.ErrReferenceNotFound)
+	})
+}
@@ -0,0 +1,164 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"github.com/oklog/ulid/v2"
+	"go.flipt.io/cup/pkg/api"
+)
+
+// Proposals returns all the open proposals on the configured SCM.
+// Each proposal is decorated with the resources it affects.
+func (s *Source) Proposals(ctx context.Context) ([]*api.Proposal, error) {
+	if s.scm == nil {
+		return nil, fmt.Errorf("listing proposals: no SCM configured: %w", errors.ErrUnsupported)
+	}
+
+	proposals, err := s.scm.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("listing proposals: %w", err)
+	}
+
+	for _, proposal := range proposals {
+		s.decorate(proposal)
+	}
+
+	return proposals, nil
+}
+
+// Proposal returns the proposal identified by id on the configured SCM.
+// The proposal is decorated with the resources it affects.
+func (s *Source) Proposal(ctx context.Context, id ulid.ULID) (*api.Proposal, error) {
+	if s.scm == nil {
+		return nil, fmt.Errorf("getting proposal: no SCM configured: %w", errors.ErrUnsupported)
+	}
+
+	proposal, err := s.scm.Get(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("getting proposal: %w", err)
+	}
+
+	s.decorate(proposal)
+
+	return proposal, nil
+}
+
+// MergeProposal merges the proposal identified by id on the configured SCM.
+func (s *Source) MergeProposal(ctx context.Context, id ulid.ULID) error {
+	if s.scm == nil {
+		return fmt.Errorf("merging proposal: no SCM configured: %w", errors.ErrUnsupported)
+	}
+
+	if err := s.scm.Merge(ctx, id); err != nil {
+		return fmt.Errorf("merging proposal: %w", err)
+	}
+
+	s.untrack(id)
+
+	return nil
+}
+
+// CloseProposal closes the proposal identified by id on the configured SCM.
+func (s *Source) CloseProposal(ctx context.Context, id ulid.ULID) error {
+	if s.scm == nil {
+		return fmt.Errorf("closing proposal: no SCM configured: %w", errors.ErrUnsupported)
+	}
+
+	if err := s.scm.Close(ctx, id); err != nil {
+		return fmt.Errorf("closing proposal: %w", err)
+	}
+
+	s.untrack(id)
+
+	return nil
+}
+
+// decorate populates the proposal with its head branch, any conflicts with its base, the state of
+// its automatic merge and the resources it affects, as recorded in the trailers of the commits
+// on the proposal branch.
+func (s *Source) decorate(proposal *api.Proposal) {
+	if proposal.Head == "" {
+		proposal.Head = ProposalBranch(proposal.ID)
+	}
+
+	s.annotate(proposal)
+
+	trailers, err := s.proposalTrailers(proposal.ID, proposal.Head)
+	if err != nil {
+		s.logger.Debug("Reading proposal resources", "proposal", proposal.ID, "error", err)
+		return
+	}
+
+	proposal.Resources = trailers[trailerResource]
+
+	// proposals which are no longer tracked report the outcome of their automatic merge
+	if open := proposal.Status == "" || proposal.Status == api.ProposalStatusOpen; !open || proposal.AutoMerge == nil {
+		proposal.AutoMerge = autoMergeState(proposal, len(trailers[trailerAutoMerge]) > 0)
+	}
+}
+
+// proposalTrailers walks the commits on the proposal branch which belong to the proposal
+// and returns the distinct values of each trailer recorded on them.
+func (s *Source) proposalTrailers(id ulid.ULID, branch string) (map[string][]string, error) {
+	ref, err := s.repo.Reference(plumbing.NewRemoteReferenceName("origin", branch), true)
+	if errors.Is(err, plumbing.ErrReferenceNotFound) {
+		// fallback to the branch pushed from this source
+		// which may not yet have been fetched back from origin
+		ref, err = s.repo.Reference(plumbing.NewBranchReferenceName(branch), true)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	iter, err := s.repo.Log(&git.LogOptions{From: ref.Hash()})
+	if err != nil {
+		return nil, err
+	}
+
+	values := map[string][]string{}
+	err = iter.ForEach(func(c *object.Commit) error {
+		trailers := parseTrailers(c.Message)
+		if !slices.Contains(trailers[trailerProposal], id.String()) {
+			return storer.ErrStop
+		}
+
+		for key, vs := range trailers {
+			for _, v := range vs {
+				if !slices.Contains(values[key], v) {
+					values[key] = append(values[key], v)
+				}
+			}
+		}
+
+		return nil
+	})
+
+	return values, err
+}
+
+// parseTrailers returns the values of each trailer found in the
+// final paragraph of the provided commit message.
+func parseTrailers(message string) map[string][]string {
+	paragraphs := strings.Split(strings.TrimSpace(message), "\n\n")
+
+	trailers := map[string][]string{}
+	for _, line := range strings.Split(paragraphs[len(paragraphs)-1], "\n") {
+		key, value, ok := strings.Cut(line, ": ")
+		if !ok || strings.ContainsAny(key, " \t") {
+			continue
+		}
+
+		trailers[key] = append(trailers[key], strings.TrimSpace(value))
+	}
+
+	return trailers
+}
@@ -0,0 +1,474 @@
+package bitbucket
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/oklog/ulid/v2"
+	"go.flipt.io/cup/pkg/api"
+	"go.flipt.io/cup/pkg/containers"
+	"go.flipt.io/cup/pkg/source/git"
+)
+
+// CloudURL is the base URL of the Bitbucket Cloud API.
+const CloudURL = "https://api.bitbucket.org"
+
+// Flavor identifies which of the Bitbucket products (and so REST APIs) is targeted.
+type Flavor int
+
+const (
+	// Cloud targets the Bitbucket Cloud 2.0 API, where the owner is the workspace.
+	Cloud Flavor = iota
+	// Server targets the Bitbucket Server (and Data Center) 1.0 API,
+	// where the owner is the project key.
+	Server
+)
+
+// SCM is an implementation of git.SCM which opens pull requests on Bitbucket.
+type SCM struct {
+	client     *http.Client
+	flavor     Flavor
+	baseURL    string
+	owner      string
+	repository string
+	user       string
+	pass       string
+	token      string
+}
+
+// WithHTTPClient overrides the default HTTP client used to call the Bitbucket API.
+func WithHTTPClient(client *http.Client) containers.Option[SCM] {
+	return func(s *SCM) {
+		s.client = client
+	}
+}
+
+// WithBasicAuth configures the credentials used to authenticate with the Bitbucket API.
+// For Bitbucket Cloud this is a username and app password.
+// For Bitbucket Server the password may be a personal access token.
+func WithBasicAuth(user, pass string) containers.Option[SCM] {
+	return func(s *SCM) {
+		s.user = user
+		s.pass = pass
+	}
+}
+
+// WithToken configures a bearer token used to authenticate with the Bitbucket API.
+// This is a repository, project or workspace access token on Bitbucket Cloud,
+// or an HTTP access token on Bitbucket Server.
+func WithToken(token string) containers.Option[SCM] {
+	return func(s *SCM) {
+		s.token = token
+	}
+}
+
+// New constructs a Bitbucket SCM of the provided flavor for the repository
+// identified by owner and repository (the repository slug).
+func New(flavor Flavor, baseURL, owner, repository string, opts ...containers.Option[SCM]) *SCM {
+	s := &SCM{
+		client:     http.DefaultClient,
+		flavor:     flavor,
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		owner:      owner,
+		repository: repository,
+	}
+
+	containers.ApplyAll(s, opts...)
+
+	return s
+}
+
+type (
+	branch struct {
+		Name string `json:"name"`
+	}
+
+	endpoint struct {
+		Branch branch `json:"branch"`
+	}
+
+	ref struct {
+		ID string `json:"id"`
+	}
+
+	link struct {
+		Href string `json:"href"`
+	}
+
+	// pullRequest is the union of the fields used from both
+	// the Cloud and Server representations of a pull request.
+	pullRequest struct {
+		ID          int    `json:"id,omitempty"`
+		Version     int    `json:"version,omitempty"`
+		State       string `json:"state,omitempty"`
+		Title       string `json:"title"`
+		Description string `json:"description"`
+
+		// Cloud
+		Source      *endpoint `json:"source,omitempty"`
+		Destination *endpoint `json:"destination,omitempty"`
+
+		// Server
+		FromRef *ref `json:"fromRef,omitempty"`
+		ToRef   *ref `json:"toRef,omitempty"`
+
+		Links *links `json:"links,omitempty"`
+	}
+
+	links struct {
+		// HTML is populated by Cloud
+		HTML *link `json:"html,omitempty"`
+		// Self is a list of links on Server
+		// (it is a single link to the API resource on Cloud)
+		Self json.RawMessage `json:"self,omitempty"`
+	}
+
+	page struct {
+		Values []pullRequest `json:"values"`
+
+		// Cloud
+		Next string `json:"next,omitempty"`
+
+		// Server
+		IsLastPage    bool `json:"isLastPage,omitempty"`
+		NextPageStart int  `json:"nextPageStart,omitempty"`
+	}
+)
+
+func (p pullRequest) url() string {
+	if p.Links == nil {
+		return ""
+	}
+
+	if p.Links.HTML != nil {
+		return p.Links.HTML.Href
+	}
+
+	var self []link
+	if err := json.Unmarshal(p.Links.Self, &self); err == nil && len(self) > 0 {
+		return self[0].Href
+	}
+
+	return ""
+}
+
+func (p pullRequest) proposal(id ulid.ULID) *api.Proposal {
+	proposal := &api.Proposal{
+		ID:     id,
+		Source: "bitbucket",
+		URL:    p.url(),
+		Head:   p.head(),
+		Base:   p.base(),
+	}
+
+	switch p.State {
+	case "MERGED":
+		proposal.Status = api.ProposalStatusMerged
+	case "DECLINED", "SUPERSEDED":
+		proposal.Status = api.ProposalStatusClosed
+	default:
+		proposal.Status = api.ProposalStatusOpen
+	}
+
+	return proposal
+}
+
+func (p pullRequest) head() string {
+	if p.Source != nil {
+		return p.Source.Branch.Name
+	}
+
+	if p.FromRef != nil {
+		return strings.TrimPrefix(p.FromRef.ID, "refs/heads/")
+	}
+
+	return ""
+}
+
+func (p pullRequest) base() string {
+	if p.Destination != nil {
+		return p.Destination.Branch.Name
+	}
+
+	if p.ToRef != nil {
+		return strings.TrimPrefix(p.ToRef.ID, "refs/heads/")
+	}
+
+	return ""
+}
+
+func (s *SCM) Merge(ctx context.Context, id ulid.ULID) error {
+	pr, err := s.find(ctx, id, true)
+	if err != nil {
+		return fmt.Errorf("merging: %w", err)
+	}
+
+	var (
+		path  = fmt.Sprintf("%s/%d/merge", s.pullRequestsPath(), pr.ID)
+		body  any
+		state pullRequest
+	)
+
+	switch s.flavor {
+	case Server:
+		path += fmt.Sprintf("?version=%d", pr.Version)
+	default:
+		body = map[string]string{"merge_strategy": "merge_commit"}
+	}
+
+	if err := s.do(ctx, http.MethodPost, path, body, &state); err != nil {
+		return err
+	}
+
+	if state.State != "MERGED" {
+		return fmt.Errorf("proposal %q could not be merged: %q", id, state.State)
+	}
+
+	return nil
+}
+
+func (s *SCM) Close(ctx context.Context, id ulid.ULID) error {
+	pr, err := s.find(ctx, id, true)
+	if err != nil {
+		return fmt.Errorf("closing: %w", err)
+	}
+
+	path := fmt.Sprintf("%s/%d/decline", s.pullRequestsPath(), pr.ID)
+	if s.flavor == Server {
+		path += fmt.Sprintf("?version=%d", pr.Version)
+	}
+
+	var state pullRequest
+	if err := s.do(ctx, http.MethodPost, path, nil, &state); err != nil {
+		return fmt.Errorf("closing: %w", err)
+	}
+
+	return nil
+}
+
+func (s *SCM) Comment(ctx context.Context, id ulid.ULID, body string) error {
+	pr, err := s.find(ctx, id, true)
+	if err != nil {
+		return fmt.Errorf("commenting: %w", err)
+	}
+
+	var req any = map[string]any{"content": map[string]string{"raw": body}}
+	if s.flavor == Server {
+		req = map[string]string{"text": body}
+	}
+
+	var comment struct {
+		ID int `json:"id"`
+	}
+
+	if err := s.do(ctx, http.MethodPost, fmt.Sprintf("%s/%d/comments", s.pullRequestsPath(), pr.ID), req, &comment); err != nil {
+		return fmt.Errorf("commenting: %w", err)
+	}
+
+	return nil
+}
+
+func (s *SCM) Get(ctx context.Context, id ulid.ULID) (*api.Proposal, error) {
+	pr, err := s.find(ctx, id, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return pr.proposal(id), nil
+}
+
+func (s *SCM) List(ctx context.Context) (proposals []*api.Proposal, _ error) {
+	query := url.Values{}
+	switch s.flavor {
+	case Server:
+		query.Set("direction", "OUTGOING")
+		query.Set("state", "OPEN")
+	default:
+		query.Set("q", fmt.Sprintf("state = %q", "OPEN"))
+	}
+
+	if err := s.each(ctx, query, func(pr pullRequest) bool {
+		if id, ok := git.ProposalID(pr.head()); ok {
+			proposals = append(proposals, pr.proposal(id))
+		}
+
+		return true
+	}); err != nil {
+		return nil, fmt.Errorf("listing: %w", err)
+	}
+
+	return proposals, nil
+}
+
+// find returns the pull request for the proposal identified by id.
+// When open is true, only open pull requests are considered.
+// The name of a proposal branch depends on how it was templated, so pull requests
+// are matched on the proposal ID parsed from their source branch.
+func (s *SCM) find(ctx context.Context, id ulid.ULID, open bool) (*pullRequest, error) {
+	query := url.Values{}
+	switch s.flavor {
+	case Server:
+		query.Set("direction", "OUTGOING")
+		query.Set("state", "ALL")
+		if open {
+			query.Set("state", "OPEN")
+		}
+	default:
+		q := fmt.Sprintf("source.branch.name ~ %q", id.String())
+		if open {
+			q += fmt.Sprintf(" AND state = %q", "OPEN")
+		} else {
+			// the Cloud API only returns open pull requests by default
+			for _, state := range []string{"OPEN", "MERGED", "DECLINED", "SUPERSEDED"} {
+				query.Add("state", state)
+			}
+		}
+
+		query.Set("q", q)
+	}
+
+	var found *pullRequest
+	if err := s.each(ctx, query, func(pr pullRequest) bool {
+		if match, ok := git.ProposalID(pr.head()); ok && match == id {
+			found = &pr
+			return false
+		}
+
+		return true
+	}); err != nil {
+		return nil, err
+	}
+
+	if found == nil {
+		return nil, fmt.Errorf("%w: %s", api.ErrProposalNotFound, id)
+	}
+
+	return found, nil
+}
+
+// each calls fn for every pull request matching the query until fn returns false.
+func (s *SCM) each(ctx context.Context, query url.Values, fn func(pullRequest) bool) error {
+	next := s.pullRequestsPath() + "?" + query.Encode()
+	for next != "" {
+		var prs page
+		if err := s.do(ctx, http.MethodGet, next, nil, &prs); err != nil {
+			return err
+		}
+
+		for _, pr := range prs.Values {
+			if !fn(pr) {
+				return nil
+			}
+		}
+
+		switch {
+		case s.flavor == Server && !prs.IsLastPage && prs.NextPageStart > 0:
+			query.Set("start", strconv.Itoa(prs.NextPageStart))
+			next = s.pullRequestsPath() + "?" + query.Encode()
+		case s.flavor == Cloud && prs.Next != "":
+			next = prs.Next
+		default:
+			next = ""
+		}
+	}
+
+	return nil
+}
+
+func (s *SCM) Propose(ctx context.Context, p git.Proposal) (*api.Proposal, error) {
+	req := pullRequest{
+		Title:       p.Title,
+		Description: p.Body,
+	}
+
+	switch s.flavor {
+	case Server:
+		req.FromRef = &ref{ID: "refs/heads/" + p.Head}
+		req.ToRef = &ref{ID: "refs/heads/" + p.Base}
+	default:
+		req.Source = &endpoint{Branch: branch{Name: p.Head}}
+		req.Destination = &endpoint{Branch: branch{Name: p.Base}}
+	}
+
+	var pr pullRequest
+	if err := s.do(ctx, http.MethodPost, s.pullRequestsPath(), req, &pr); err != nil {
+		return nil, err
+	}
+
+	proposal := pr.proposal(p.ID)
+	// the response does not always include the branches
+	proposal.Head, proposal.Base = p.Head, p.Base
+
+	return proposal, nil
+}
+
+func (s *SCM) pullRequestsPath() string {
+	switch s.flavor {
+	case Server:
+		return fmt.Sprintf("/rest/api/1.0/projects/%s/repos/%s/pull-requests",
+			url.PathEscape(s.owner), url.PathEscape(s.repository))
+	default:
+		return fmt.Sprintf("/2.0/repositories/%s/%s/pullrequests",
+			url.PathEscape(s.owner), url.PathEscape(s.repository))
+	}
+}
+
+// do performs a JSON request against the Bitbucket API and decodes the response into v.
+// The path is relative to the base URL unless it is an absolute URL (e.g. a next page link).
+func (s *SCM) do(ctx context.Context, method, path string, body, v any) error {
+	var rd io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+
+		rd = bytes.NewReader(data)
+	}
+
+	target := path
+	if !strings.HasPrefix(path, "http://") && !strings.HasPrefix(path, "https://") {
+		target = s.baseURL + path
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, rd)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	switch {
+	case s.token != "":
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	case s.user != "" || s.pass != "":
+		req.SetBasicAuth(s.user, s.pass)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		data, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("%s %s: unexpected status %q: %s", method, path, resp.Status, bytes.TrimSpace(data))
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,277 @@
+package bitbucket_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.flipt.io/cup/pkg/api"
+	"go.flipt.io/cup/pkg/source/git"
+	"go.flipt.io/cup/pkg/source/git/scm/bitbucket"
+)
+
+func Test_SCM_Propose_Merge(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		flavor bitbucket.Flavor
+		prefix string
+		url    string
+	}{
+		{
+			name:   "cloud",
+			flavor: bitbucket.Cloud,
+			prefix: "/2.0/repositories/workspace/repo/pullrequests",
+			url:    "/workspace/repo/pull-requests/1",
+		},
+		{
+			name:   "server",
+			flavor: bitbucket.Server,
+			prefix: "/rest/api/1.0/projects/PROJ/repos/repo/pull-requests",
+			url:    "/projects/PROJ/repos/repo/pull-requests/1/overview",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var (
+				ctx   = context.Background()
+				fake  = newFakeBitbucket(t, test.flavor, test.prefix)
+				id    = ulid.Make()
+				head  = fmt.Sprintf("cup/proposal/%s", id)
+				owner = "workspace"
+			)
+
+			if test.flavor == bitbucket.Server {
+				owner = "PROJ"
+			}
+
+			scm := bitbucket.New(test.flavor, fake.URL, owner, "repo",
+				bitbucket.WithBasicAuth("user", "pass"))
+
+			proposal, err := scm.Propose(ctx, git.Proposal{
+				ID:    id,
+				Head:  head,
+				Base:  "main",
+				Title: "feat: update resource",
+				Body:  "some description",
+			})
+			require.NoError(t, err)
+
+			assert.Equal(t, "bitbucket", proposal.Source)
+			assert.Equal(t, fake.URL+test.url, proposal.URL)
+
+			require.Len(t, fake.prs, 1)
+			pr := fake.prs[0]
+			assert.Equal(t, head, pr.head)
+			assert.Equal(t, "main", pr.base)
+			assert.Equal(t, "feat: update resource", pr.title)
+			assert.Equal(t, "some description", pr.description)
+
+			user, pass, _ := fake.last.BasicAuth()
+			assert.Equal(t, "user", user)
+			assert.Equal(t, "pass", pass)
+
+			assert.Equal(t, api.ProposalStatusOpen, proposal.Status)
+			assert.Equal(t, head, proposal.Head)
+			assert.Equal(t, "main", proposal.Base)
+
+			// a second proposal which is closed
+			closedID := ulid.Make()
+			_, err = scm.Propose(ctx, git.Proposal{ID: closedID, Head: git.ProposalBranch(closedID), Base: "main"})
+			require.NoError(t, err)
+			require.NoError(t, scm.Close(ctx, closedID))
+
+			closed, err := scm.Get(ctx, closedID)
+			require.NoError(t, err)
+			assert.Equal(t, api.ProposalStatusClosed, closed.Status)
+
+			proposals, err := scm.List(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, []*api.Proposal{proposal}, proposals)
+
+			require.NoError(t, scm.Merge(ctx, id))
+			assert.Equal(t, "MERGED", fake.prs[0].state)
+
+			merged, err := scm.Get(ctx, id)
+			require.NoError(t, err)
+			assert.Equal(t, api.ProposalStatusMerged, merged.Status)
+
+			err = scm.Merge(ctx, ulid.Make())
+			require.ErrorIs(t, err, api.ErrProposalNotFound)
+		})
+	}
+}
+
+type fakePR struct {
+	head, base         string
+	title, description string
+	state              string
+}
+
+// fakeBitbucket is a minimal stand-in for the Bitbucket Cloud and Server pull request APIs.
+type fakeBitbucket struct {
+	*httptest.Server
+
+	flavor bitbucket.Flavor
+	prefix string
+
+	mu   sync.Mutex
+	last *http.Request
+	prs  []*fakePR
+}
+
+func Test_SCM_Token(t *testing.T) {
+	fake := newFakeBitbucket(t, bitbucket.Cloud, "/2.0/repositories/workspace/repo/pullrequests")
+
+	scm := bitbucket.New(bitbucket.Cloud, fake.URL, "workspace", "repo",
+		bitbucket.WithToken("token"))
+
+	id := ulid.Make()
+	_, err := scm.Propose(context.Background(), git.Proposal{ID: id, Head: git.ProposalBranch(id), Base: "main"})
+	require.NoError(t, err)
+
+	assert.Equal(t, "Bearer token", fake.last.Header.Get("Authorization"))
+}
+
+func newFakeBitbucket(t *testing.T, flavor bitbucket.Flavor, prefix string) *fakeBitbucket {
+	t.Helper()
+
+	f := &fakeBitbucket{flavor: flavor, prefix: prefix}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.Close)
+
+	return f
+}
+
+func (f *fakeBitbucket) serve(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.last = r
+
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == f.prefix:
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		pr := &fakePR{
+			title:       body["title"].(string),
+			description: body["description"].(string),
+			state:       "OPEN",
+		}
+
+		if f.flavor == bitbucket.Server {
+			pr.head = strings.TrimPrefix(body["fromRef"].(map[string]any)["id"].(string), "refs/heads/")
+			pr.base = strings.TrimPrefix(body["toRef"].(map[string]any)["id"].(string), "refs/heads/")
+		} else {
+			pr.head = body["source"].(map[string]any)["branch"].(map[string]any)["name"].(string)
+			pr.base = body["destination"].(map[string]any)["branch"].(map[string]any)["name"].(string)
+		}
+
+		f.prs = append(f.prs, pr)
+
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(f.render(len(f.prs), pr))
+	case r.Method == http.MethodGet && r.URL.Path == f.prefix:
+		values := []any{}
+		for i, pr := range f.prs {
+			if f.matches(r.URL.Query(), pr) {
+				values = append(values, f.render(i+1, pr))
+			}
+		}
+
+		_ = json.NewEncoder(w).Encode(map[string]any{"values": values, "isLastPage": true})
+	case r.Method == http.MethodPost && (strings.HasSuffix(r.URL.Path, "/merge") || strings.HasSuffix(r.URL.Path, "/decline")):
+		action := path.Base(r.URL.Path)
+		id, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, f.prefix+"/"), "/"+action))
+		if err != nil || id < 1 || id > len(f.prs) {
+			http.NotFound(w, r)
+			return
+		}
+
+		if f.flavor == bitbucket.Server && r.URL.Query().Get("version") != "0" {
+			http.Error(w, "version mismatch", http.StatusConflict)
+			return
+		}
+
+		pr := f.prs[id-1]
+		pr.state = "MERGED"
+		if action == "decline" {
+			pr.state = "DECLINED"
+		}
+
+		_ = json.NewEncoder(w).Encode(f.render(id, pr))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// matches emulates the filtering performed by each flavor of the API.
+func (f *fakeBitbucket) matches(query url.Values, pr *fakePR) bool {
+	if f.flavor == bitbucket.Server {
+		return query.Get("state") == "ALL" || query.Get("state") == pr.state
+	}
+
+	q := query.Get("q")
+	if _, contains, ok := strings.Cut(q, "source.branch.name ~ "); ok {
+		substr, _, _ := strings.Cut(contains, " ")
+		if !strings.Contains(pr.head, strings.Trim(substr, `"`)) {
+			return false
+		}
+	} else if strings.Contains(q, "source.branch.name = ") && !strings.Contains(q, fmt.Sprintf("source.branch.name = %q", pr.head)) {
+		return false
+	}
+
+	if strings.Contains(q, `state = "OPEN"`) {
+		return pr.state == "OPEN"
+	}
+
+	// only open pull requests are returned unless requested otherwise
+	states := query["state"]
+	if len(states) == 0 {
+		states = []string{"OPEN"}
+	}
+
+	return slices.Contains(states, pr.state)
+}
+
+func (f *fakeBitbucket) render(id int, pr *fakePR) map[string]any {
+	if f.flavor == bitbucket.Server {
+		return map[string]any{
+			"id":      id,
+			"version": 0,
+			"state":   pr.state,
+			"fromRef": map[string]any{"id": "refs/heads/" + pr.head},
+			"toRef":   map[string]any{"id": "refs/heads/" + pr.base},
+			"links": map[string]any{
+				"self": []any{map[string]any{
+					"href": fmt.Sprintf("%s/projects/PROJ/repos/repo/pull-requests/%d/overview", f.URL, id),
+				}},
+			},
+		}
+	}
+
+	return map[string]any{
+		"id":          id,
+		"state":       pr.state,
+		"source":      map[string]any{"branch": map[string]any{"name": pr.head}},
+		"destination": map[string]any{"branch": map[string]any{"name": pr.base}},
+		"links": map[string]any{
+			"self": map[string]any{"href": fmt.Sprintf("%s%s/%d", f.URL, f.prefix, id)},
+			"html": map[string]any{"href": fmt.Sprintf("%s/workspace/repo/pull-requests/%d", f.URL, id)},
+		},
+	}
+}
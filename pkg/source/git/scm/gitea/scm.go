@@ -3,13 +3,22 @@ package gitea
 import (
 	"context"
 	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
 
 	"code.gitea.io/sdk/gitea"
 	"github.com/oklog/ulid/v2"
 	"go.flipt.io/cup/pkg/api"
+	"go.flipt.io/cup/pkg/api/core"
 	"go.flipt.io/cup/pkg/source/git"
 )
 
+// pageSize is the number of pull requests requested per page when listing.
+const pageSize = 50
+
+var _ git.Checker = (*SCM)(nil)
+
 type SCM struct {
 	client     *gitea.Client
 	owner      string
@@ -25,26 +34,11 @@ func New(client *gitea.Client, owner, repository string) *SCM {
 }
 
 func (s *SCM) Merge(_ context.Context, id ulid.ULID) error {
-	prs, _, err := s.client.ListRepoPullRequests(s.owner, s.repository, gitea.ListPullRequestsOptions{})
+	pr, err := s.find(id, gitea.StateOpen)
 	if err != nil {
 		return fmt.Errorf("merging: %w", err)
 	}
 
-	var (
-		pr    *gitea.PullRequest
-		found bool
-	)
-	for _, p := range prs {
-		if found = p.Head.Name == fmt.Sprintf("cup/proposal/%s", id); found {
-			pr = p
-			break
-		}
-	}
-
-	if !found {
-		return fmt.Errorf("proposal %q not found", id)
-	}
-
 	ok, _, err := s.client.MergePullRequest(s.owner, s.repository, pr.Index, gitea.MergePullRequestOption{
 		Style: gitea.MergeStyleMerge,
 	})
@@ -59,19 +53,268 @@ func (s *SCM) Merge(_ context.Context, id ulid.ULID) error {
 	return nil
 }
 
+// Checks returns the combined commit status of the head of the pull request
+// for the open proposal identified by id.
+// Only the status checks required by the protection of the base branch are considered when it has any,
+// otherwise all of them must pass and a head without any statuses is pending.
+func (s *SCM) Checks(_ context.Context, id ulid.ULID) (git.CheckStatus, error) {
+	pr, err := s.find(id, gitea.StateOpen)
+	if err != nil {
+		return "", fmt.Errorf("checking: %w", err)
+	}
+
+	var required []string
+	protection, resp, err := s.client.GetBranchProtection(s.owner, s.repository, pr.Base.Ref)
+	switch {
+	case err == nil:
+		if protection.EnableStatusCheck {
+			required = protection.StatusCheckContexts
+		}
+	case resp == nil || resp.StatusCode != http.StatusNotFound:
+		return "", fmt.Errorf("checking: %w", err)
+	}
+
+	combined, _, err := s.client.GetCombinedStatus(s.owner, s.repository, pr.Head.Sha)
+	if err != nil {
+		return "", fmt.Errorf("checking: %w", err)
+	}
+
+	var checks []git.Check
+	for _, status := range combined.Statuses {
+		check := git.Check{Name: status.Context, Status: git.CheckStatusFailure}
+		switch status.State {
+		case gitea.StatusSuccess, gitea.StatusWarning:
+			check.Status = git.CheckStatusSuccess
+		case gitea.StatusPending:
+			check.Status = git.CheckStatusPending
+		}
+
+		checks = append(checks, check)
+	}
+
+	return git.CombineChecks(checks, required), nil
+}
+
+func (s *SCM) Close(_ context.Context, id ulid.ULID) error {
+	pr, err := s.find(id, gitea.StateOpen)
+	if err != nil {
+		return fmt.Errorf("closing: %w", err)
+	}
+
+	closed := gitea.StateClosed
+	if _, _, err := s.client.EditPullRequest(s.owner, s.repository, pr.Index, gitea.EditPullRequestOption{
+		State: &closed,
+	}); err != nil {
+		return fmt.Errorf("closing: %w", err)
+	}
+
+	return nil
+}
+
+func (s *SCM) Comment(_ context.Context, id ulid.ULID, body string) error {
+	pr, err := s.find(id, gitea.StateOpen)
+	if err != nil {
+		return fmt.Errorf("commenting: %w", err)
+	}
+
+	if _, _, err := s.client.CreateIssueComment(s.owner, s.repository, pr.Index, gitea.CreateIssueCommentOption{
+		Body: body,
+	}); err != nil {
+		return fmt.Errorf("commenting: %w", err)
+	}
+
+	return nil
+}
+
+func (s *SCM) Get(_ context.Context, id ulid.ULID) (*api.Proposal, error) {
+	pr, err := s.find(id, gitea.StateAll)
+	if err != nil {
+		return nil, err
+	}
+
+	return proposal(id, pr), nil
+}
+
+func (s *SCM) List(context.Context) (proposals []*api.Proposal, err error) {
+	err = s.each(gitea.StateOpen, func(pr *gitea.PullRequest) bool {
+		if id, ok := git.ProposalID(pr.Head.Ref); ok {
+			proposals = append(proposals, proposal(id, pr))
+		}
+
+		return true
+	})
+
+	return
+}
+
+// Propose opens a pull request for the proposal and then sets its labels, assignees
+// and milestone and requests its reviewers.
+// Gitea has no draft state, so drafts are marked as work in progress via their title.
+// The pull request is left in place when these details cannot be set,
+// as they are not essential to the proposal.
 func (s *SCM) Propose(_ context.Context, p git.Proposal) (*api.Proposal, error) {
+	title := p.Title
+	if p.Draft {
+		title = draftPrefix + title
+	}
+
 	pr, _, err := s.client.CreatePullRequest(s.owner, s.repository, gitea.CreatePullRequestOption{
 		Head:  p.Head,
 		Base:  p.Base,
-		Title: p.Title,
+		Title: title,
 		Body:  p.Body,
 	})
 	if err != nil {
 		return nil, err
 	}
 
-	return &api.Proposal{
+	if err := s.edit(pr.Index, p.ProposalDetails); err != nil {
+		slog.Warn("Setting proposal details", "proposal", p.ID, "error", err)
+	}
+
+	if len(p.Reviewers) > 0 || len(p.TeamReviewers) > 0 {
+		if _, err := s.client.CreateReviewRequests(s.owner, s.repository, pr.Index, gitea.PullReviewRequestOptions{
+			Reviewers:     p.Reviewers,
+			TeamReviewers: p.TeamReviewers,
+		}); err != nil {
+			slog.Warn("Requesting proposal reviewers", "proposal", p.ID, "error", err)
+		}
+	}
+
+	return proposal(p.ID, pr), nil
+}
+
+// draftPrefix marks the title of a pull request as a work in progress.
+const draftPrefix = "WIP: "
+
+// edit sets the labels, assignees and milestone of the pull request identified by index.
+func (s *SCM) edit(index int64, details core.ProposalDetails) error {
+	if len(details.Labels) == 0 && len(details.Assignees) == 0 && details.Milestone == "" {
+		return nil
+	}
+
+	opts := gitea.EditPullRequestOption{Assignees: details.Assignees}
+
+	if len(details.Labels) > 0 {
+		labels, err := s.labels(details.Labels)
+		if err != nil {
+			return err
+		}
+
+		opts.Labels = labels
+	}
+
+	if details.Milestone != "" {
+		milestone, _, err := s.client.GetMilestoneByName(s.owner, s.repository, details.Milestone)
+		if err != nil {
+			return fmt.Errorf("getting milestone %q: %w", details.Milestone, err)
+		}
+
+		opts.Milestone = milestone.ID
+	}
+
+	_, _, err := s.client.EditPullRequest(s.owner, s.repository, index, opts)
+	return err
+}
+
+// labels returns the IDs of the repository labels with the provided names.
+func (s *SCM) labels(names []string) (ids []int64, _ error) {
+	for page := 1; ; page++ {
+		labels, _, err := s.client.ListRepoLabels(s.owner, s.repository, gitea.ListLabelsOptions{
+			ListOptions: gitea.ListOptions{Page: page, PageSize: pageSize},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("listing labels: %w", err)
+		}
+
+		for _, label := range labels {
+			if slices.Contains(names, label.Name) {
+				ids = append(ids, label.ID)
+			}
+		}
+
+		if len(labels) < pageSize {
+			break
+		}
+	}
+
+	if len(ids) < len(names) {
+		return nil, fmt.Errorf("labels not found: %q", names)
+	}
+
+	return ids, nil
+}
+
+// find returns the pull request for the proposal identified by id in the provided state.
+func (s *SCM) find(id ulid.ULID, state gitea.StateType) (pr *gitea.PullRequest, err error) {
+	if err := s.each(state, func(p *gitea.PullRequest) bool {
+		if p.Head == nil {
+			return true
+		}
+
+		if match, ok := git.ProposalID(p.Head.Ref); ok && match == id {
+			pr = p
+			return false
+		}
+
+		return true
+	}); err != nil {
+		return nil, err
+	}
+
+	if pr == nil {
+		return nil, fmt.Errorf("%w: %s", api.ErrProposalNotFound, id)
+	}
+
+	return pr, nil
+}
+
+// each calls fn for every pull request in the provided state until fn returns false.
+func (s *SCM) each(state gitea.StateType, fn func(*gitea.PullRequest) bool) error {
+	for page := 1; ; page++ {
+		prs, _, err := s.client.ListRepoPullRequests(s.owner, s.repository, gitea.ListPullRequestsOptions{
+			ListOptions: gitea.ListOptions{Page: page, PageSize: pageSize},
+			State:       state,
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, pr := range prs {
+			if !fn(pr) {
+				return nil
+			}
+		}
+
+		if len(prs) < pageSize {
+			return nil
+		}
+	}
+}
+
+func proposal(id ulid.ULID, pr *gitea.PullRequest) *api.Proposal {
+	status := api.ProposalStatusOpen
+	switch {
+	case pr.HasMerged:
+		status = api.ProposalStatusMerged
+	case pr.State == gitea.StateClosed:
+		status = api.ProposalStatusClosed
+	}
+
+	p := &api.Proposal{
+		ID:     id,
 		Source: "gitea",
 		URL:    pr.HTMLURL,
-	}, nil
+		Status: status,
+	}
+
+	if pr.Head != nil {
+		p.Head = pr.Head.Ref
+	}
+
+	if pr.Base != nil {
+		p.Base = pr.Base.Ref
+	}
+
+	return p
 }
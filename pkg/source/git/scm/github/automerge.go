@@ -0,0 +1,163 @@
+package gitea
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/google/go-github/v53/github"
+	"github.com/oklog/ulid/v2"
+	"go.flipt.io/cup/pkg/source/git"
+)
+
+var (
+	_ git.AutoMerger = (*SCM)(nil)
+	_ git.Checker    = (*SCM)(nil)
+)
+
+// enableAutoMerge is the GraphQL mutation which enables auto-merge on a pull request.
+// Auto-merge is only available via GraphQL and it must be allowed in the settings of the repository.
+const enableAutoMerge = `mutation($id: ID!) {
+  enablePullRequestAutoMerge(input: {pullRequestId: $id, mergeMethod: MERGE}) {
+    clientMutationId
+  }
+}`
+
+// AutoMerge enables auto-merge on the pull request for the open proposal identified by id,
+// so that GitHub merges it once its required checks pass.
+func (s *SCM) AutoMerge(ctx context.Context, id ulid.ULID) error {
+	pr, err := s.find(ctx, id, "open")
+	if err != nil {
+		return fmt.Errorf("enabling auto-merge: %w", err)
+	}
+
+	req, err := s.client.NewRequest("POST", s.graphqlURL(), map[string]any{
+		"query":     enableAutoMerge,
+		"variables": map[string]any{"id": pr.GetNodeID()},
+	})
+	if err != nil {
+		return fmt.Errorf("enabling auto-merge: %w", err)
+	}
+
+	var resp struct {
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+
+	if _, err := s.client.Do(ctx, req, &resp); err != nil {
+		return fmt.Errorf("enabling auto-merge: %w", err)
+	}
+
+	if len(resp.Errors) > 0 {
+		msgs := make([]string, 0, len(resp.Errors))
+		for _, e := range resp.Errors {
+			msgs = append(msgs, e.Message)
+		}
+
+		return fmt.Errorf("enabling auto-merge: %w", errors.New(strings.Join(msgs, "; ")))
+	}
+
+	return nil
+}
+
+// graphqlURL returns the GraphQL endpoint alongside the REST API of the client.
+// The REST API of GitHub Enterprise Server is served from /api/v3/ and GraphQL from /api/graphql.
+func (s *SCM) graphqlURL() string {
+	u := *s.client.BaseURL
+	if prefix, ok := strings.CutSuffix(u.Path, "/api/v3/"); ok {
+		u.Path = prefix + "/api/graphql"
+	} else {
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/graphql"
+	}
+
+	return u.String()
+}
+
+// Checks returns the combined status of the commit statuses and check runs
+// on the head of the pull request for the open proposal identified by id.
+// Only the checks required by the protection of the base branch are considered when it has any,
+// otherwise all of them must pass and a head without any checks is pending.
+func (s *SCM) Checks(ctx context.Context, id ulid.ULID) (git.CheckStatus, error) {
+	pr, err := s.find(ctx, id, "open")
+	if err != nil {
+		return "", fmt.Errorf("checking: %w", err)
+	}
+
+	required, err := s.requiredChecks(ctx, pr.GetBase().GetRef())
+	if err != nil {
+		return "", fmt.Errorf("checking: %w", err)
+	}
+
+	sha := pr.GetHead().GetSHA()
+
+	var checks []git.Check
+
+	combined, _, err := s.client.Repositories.GetCombinedStatus(ctx, s.owner, s.repository, sha, &github.ListOptions{PerPage: 100})
+	if err != nil {
+		return "", fmt.Errorf("checking: %w", err)
+	}
+
+	for _, status := range combined.Statuses {
+		check := git.Check{Name: status.GetContext(), Status: git.CheckStatusFailure}
+		switch status.GetState() {
+		case "success":
+			check.Status = git.CheckStatusSuccess
+		case "pending":
+			check.Status = git.CheckStatusPending
+		}
+
+		checks = append(checks, check)
+	}
+
+	opts := &github.ListCheckRunsOptions{ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		runs, resp, err := s.client.Checks.ListCheckRunsForRef(ctx, s.owner, s.repository, sha, opts)
+		if err != nil {
+			return "", fmt.Errorf("checking: %w", err)
+		}
+
+		for _, run := range runs.CheckRuns {
+			check := git.Check{Name: run.GetName(), Status: git.CheckStatusFailure}
+			switch {
+			case run.GetStatus() != "completed":
+				check.Status = git.CheckStatusPending
+			case run.GetConclusion() == "success", run.GetConclusion() == "neutral", run.GetConclusion() == "skipped":
+				check.Status = git.CheckStatusSuccess
+			}
+
+			checks = append(checks, check)
+		}
+
+		if resp.NextPage == 0 {
+			return git.CombineChecks(checks, required), nil
+		}
+
+		opts.Page = resp.NextPage
+	}
+}
+
+// requiredChecks returns the names of the checks required by the protection of the branch.
+// Branches which are not protected (or whose protection cannot be read) require no checks.
+func (s *SCM) requiredChecks(ctx context.Context, branch string) ([]string, error) {
+	checks, resp, err := s.client.Repositories.GetRequiredStatusChecks(ctx, s.owner, s.repository, branch)
+	if err != nil {
+		if errors.Is(err, github.ErrBranchNotProtected) || resp != nil && resp.StatusCode == http.StatusNotFound {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	required := append([]string{}, checks.Contexts...)
+	for _, check := range checks.Checks {
+		if !slices.Contains(required, check.Context) {
+			required = append(required, check.Context)
+		}
+	}
+
+	return required, nil
+}
@@ -2,11 +2,15 @@ package gitea
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
+	"strings"
 
 	"github.com/google/go-github/v53/github"
 	"github.com/oklog/ulid/v2"
 	"go.flipt.io/cup/pkg/api"
+	"go.flipt.io/cup/pkg/api/core"
 	"go.flipt.io/cup/pkg/source/git"
 )
 
@@ -14,31 +18,24 @@ type SCM struct {
 	client     *github.Client
 	owner      string
 	repository string
-	actor      string
+	heads      git.ProposalHeads
 }
 
-func New(client *github.Client, owner, repository, actor string) *SCM {
+func New(client *github.Client, owner, repository string) *SCM {
 	return &SCM{
 		client:     client,
 		owner:      owner,
 		repository: repository,
-		actor:      actor,
 	}
 }
 
 func (s *SCM) Merge(ctx context.Context, id ulid.ULID) error {
-	prs, _, err := s.client.PullRequests.List(ctx, s.owner, s.repository, &github.PullRequestListOptions{
-		Head: fmt.Sprintf("%s:cup/proposal/%s", s.actor, id),
-	})
+	pr, err := s.find(ctx, id, "open")
 	if err != nil {
 		return fmt.Errorf("merging: %w", err)
 	}
 
-	if len(prs) < 0 {
-		return fmt.Errorf("proposal %q not found", id)
-	}
-
-	res, _, err := s.client.PullRequests.Merge(ctx, s.owner, s.repository, prs[0].GetNumber(), prs[0].GetTitle(), &github.PullRequestOptions{
+	res, _, err := s.client.PullRequests.Merge(ctx, s.owner, s.repository, pr.GetNumber(), pr.GetTitle(), &github.PullRequestOptions{
 		MergeMethod: "merge",
 	})
 	if err != nil {
@@ -52,19 +49,240 @@ func (s *SCM) Merge(ctx context.Context, id ulid.ULID) error {
 	return nil
 }
 
+func (s *SCM) Close(ctx context.Context, id ulid.ULID) error {
+	pr, err := s.find(ctx, id, "open")
+	if err != nil {
+		return fmt.Errorf("closing: %w", err)
+	}
+
+	if _, _, err := s.client.PullRequests.Edit(ctx, s.owner, s.repository, pr.GetNumber(), &github.PullRequest{
+		State: github.String("closed"),
+	}); err != nil {
+		return fmt.Errorf("closing: %w", err)
+	}
+
+	return nil
+}
+
+func (s *SCM) Comment(ctx context.Context, id ulid.ULID, body string) error {
+	pr, err := s.find(ctx, id, "open")
+	if err != nil {
+		return fmt.Errorf("commenting: %w", err)
+	}
+
+	if _, _, err := s.client.Issues.CreateComment(ctx, s.owner, s.repository, pr.GetNumber(), &github.IssueComment{
+		Body: github.String(body),
+	}); err != nil {
+		return fmt.Errorf("commenting: %w", err)
+	}
+
+	return nil
+}
+
+func (s *SCM) Get(ctx context.Context, id ulid.ULID) (*api.Proposal, error) {
+	pr, err := s.find(ctx, id, "all")
+	if err != nil {
+		return nil, err
+	}
+
+	return proposal(id, pr), nil
+}
+
+func (s *SCM) List(ctx context.Context) (proposals []*api.Proposal, _ error) {
+	opts := &github.PullRequestListOptions{
+		State:       "open",
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
+
+	for {
+		prs, resp, err := s.client.PullRequests.List(ctx, s.owner, s.repository, opts)
+		if err != nil {
+			return nil, fmt.Errorf("listing: %w", err)
+		}
+
+		for _, pr := range prs {
+			if id, ok := git.ProposalID(pr.GetHead().GetRef()); ok {
+				s.heads.Record(id, pr.GetHead().GetRef())
+				proposals = append(proposals, proposal(id, pr))
+			}
+		}
+
+		if resp.NextPage == 0 {
+			return proposals, nil
+		}
+
+		opts.Page = resp.NextPage
+	}
+}
+
+// Propose opens a pull request for the proposal and then requests its reviewers
+// and sets its labels, assignees and milestone.
+// The pull request is left in place when these details cannot be set,
+// as they are not essential to the proposal.
 func (s *SCM) Propose(ctx context.Context, p git.Proposal) (*api.Proposal, error) {
 	pr, _, err := s.client.PullRequests.Create(ctx, s.owner, s.repository, &github.NewPullRequest{
 		Head:  github.String(p.Head),
 		Base:  github.String(p.Base),
 		Title: github.String(p.Title),
 		Body:  github.String(p.Body),
+		Draft: github.Bool(p.Draft),
 	})
 	if err != nil {
 		return nil, err
 	}
 
-	return &api.Proposal{
+	s.heads.Record(p.ID, p.Head)
+
+	if len(p.Reviewers) > 0 || len(p.TeamReviewers) > 0 {
+		if _, _, err := s.client.PullRequests.RequestReviewers(ctx, s.owner, s.repository, pr.GetNumber(), github.ReviewersRequest{
+			Reviewers:     p.Reviewers,
+			TeamReviewers: p.TeamReviewers,
+		}); err != nil {
+			slog.Warn("Requesting proposal reviewers", "proposal", p.ID, "error", err)
+		}
+	}
+
+	if err := s.edit(ctx, pr.GetNumber(), p.ProposalDetails); err != nil {
+		slog.Warn("Setting proposal details", "proposal", p.ID, "error", err)
+	}
+
+	return proposal(p.ID, pr), nil
+}
+
+// edit sets the labels, assignees and milestone of the pull request identified by number.
+func (s *SCM) edit(ctx context.Context, number int, details core.ProposalDetails) error {
+	var (
+		req   = &github.IssueRequest{}
+		empty = true
+	)
+
+	if len(details.Labels) > 0 {
+		req.Labels, empty = &details.Labels, false
+	}
+
+	if len(details.Assignees) > 0 {
+		req.Assignees, empty = &details.Assignees, false
+	}
+
+	if details.Milestone != "" {
+		milestone, err := s.milestone(ctx, details.Milestone)
+		if err != nil {
+			return err
+		}
+
+		req.Milestone, empty = &milestone, false
+	}
+
+	if empty {
+		return nil
+	}
+
+	_, _, err := s.client.Issues.Edit(ctx, s.owner, s.repository, number, req)
+	return err
+}
+
+// milestone returns the number of the open milestone with the provided title.
+func (s *SCM) milestone(ctx context.Context, title string) (int, error) {
+	opts := &github.MilestoneListOptions{
+		State:       "open",
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
+
+	for {
+		milestones, resp, err := s.client.Issues.ListMilestones(ctx, s.owner, s.repository, opts)
+		if err != nil {
+			return 0, fmt.Errorf("listing milestones: %w", err)
+		}
+
+		for _, milestone := range milestones {
+			if milestone.GetTitle() == title {
+				return milestone.GetNumber(), nil
+			}
+		}
+
+		if resp.NextPage == 0 {
+			return 0, fmt.Errorf("milestone not found: %q", title)
+		}
+
+		opts.Page = resp.NextPage
+	}
+}
+
+// find returns the pull request for the proposal identified by id in the provided state.
+// Pull requests are first filtered by the heads the proposal is known or expected to be pushed to.
+// The name of a proposal branch depends on how it was templated, so when none of these match,
+// pull requests are matched on the proposal ID parsed from their head, starting with the most recent.
+func (s *SCM) find(ctx context.Context, id ulid.ULID, state string) (*github.PullRequest, error) {
+	for _, head := range s.heads.Candidates(id) {
+		pr, err := s.search(ctx, id, &github.PullRequestListOptions{
+			State:       state,
+			Head:        s.owner + ":" + head,
+			ListOptions: github.ListOptions{PerPage: 100},
+		})
+		if !errors.Is(err, api.ErrProposalNotFound) {
+			return pr, err
+		}
+	}
+
+	return s.search(ctx, id, &github.PullRequestListOptions{
+		State:       state,
+		Sort:        "created",
+		Direction:   "desc",
+		ListOptions: github.ListOptions{PerPage: 100},
+	})
+}
+
+// search pages through the pull requests listed with opts for the proposal identified by id
+// and records the head of the pull request when it is found.
+// Proposal branches are pushed to the target repository itself, so only heads
+// in the repository (rather than a fork) are considered.
+func (s *SCM) search(ctx context.Context, id ulid.ULID, opts *github.PullRequestListOptions) (*github.PullRequest, error) {
+	for {
+		prs, resp, err := s.client.PullRequests.List(ctx, s.owner, s.repository, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, pr := range prs {
+			if owner := pr.GetHead().GetRepo().GetOwner().GetLogin(); owner != "" && !strings.EqualFold(owner, s.owner) {
+				continue
+			}
+
+			if match, ok := git.ProposalID(pr.GetHead().GetRef()); ok && match == id {
+				s.heads.Record(id, pr.GetHead().GetRef())
+				return pr, nil
+			}
+		}
+
+		if resp.NextPage == 0 {
+			return nil, fmt.Errorf("%w: %s", api.ErrProposalNotFound, id)
+		}
+
+		opts.Page = resp.NextPage
+	}
+}
+
+func proposal(id ulid.ULID, pr *github.PullRequest) *api.Proposal {
+	status := api.ProposalStatusOpen
+	switch {
+	case pr.MergedAt != nil || pr.GetMerged():
+		status = api.ProposalStatusMerged
+	case strings.EqualFold(pr.GetState(), "closed"):
+		status = api.ProposalStatusClosed
+	}
+
+	proposal := &api.Proposal{
+		ID:     id,
 		Source: "github",
 		URL:    pr.GetHTMLURL(),
-	}, nil
+		Status: status,
+		Head:   pr.GetHead().GetRef(),
+		Base:   pr.GetBase().GetRef(),
+	}
+
+	if pr.AutoMerge != nil {
+		proposal.AutoMerge = &api.AutoMerge{State: api.AutoMergeStatePending, Native: true}
+	}
+
+	return proposal
 }
@@ -0,0 +1,359 @@
+package gitea_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/google/go-github/v53/github"
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.flipt.io/cup/pkg/api"
+	"go.flipt.io/cup/pkg/api/core"
+	"go.flipt.io/cup/pkg/source/git"
+	scmgithub "go.flipt.io/cup/pkg/source/git/scm/github"
+)
+
+func Test_SCM_Propose_Details(t *testing.T) {
+	var (
+		ctx  = context.Background()
+		fake = newFakeGitHub(t)
+		id   = ulid.Make()
+		head = git.ProposalBranch(id)
+	)
+
+	scm := scmgithub.New(fake.client(t), "owner", "repo")
+
+	proposal, err := scm.Propose(ctx, git.Proposal{
+		ID:    id,
+		Head:  head,
+		Base:  "main",
+		Title: "feat: update resource",
+		Body:  "some description",
+		ProposalDetails: core.ProposalDetails{
+			Reviewers:     []string{"alice"},
+			TeamReviewers: []string{"flags"},
+			Labels:        []string{"cup", "flags"},
+			Assignees:     []string{"bob"},
+			Milestone:     "v1.0",
+			Draft:         true,
+		},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, &api.Proposal{
+		ID:     id,
+		Source: "github",
+		URL:    "https://github.com/owner/repo/pull/1",
+		Status: api.ProposalStatusOpen,
+		Head:   head,
+		Base:   "main",
+	}, proposal)
+
+	assert.Equal(t, map[string]any{
+		"head":  head,
+		"base":  "main",
+		"title": "feat: update resource",
+		"body":  "some description",
+		"draft": true,
+	}, fake.requests["POST /repos/owner/repo/pulls"])
+
+	assert.Equal(t, map[string]any{
+		"reviewers":      []any{"alice"},
+		"team_reviewers": []any{"flags"},
+	}, fake.requests["POST /repos/owner/repo/pulls/1/requested_reviewers"])
+
+	assert.Equal(t, map[string]any{
+		"labels":    []any{"cup", "flags"},
+		"assignees": []any{"bob"},
+		"milestone": float64(3),
+	}, fake.requests["PATCH /repos/owner/repo/issues/1"])
+
+	t.Run("unknown milestone", func(t *testing.T) {
+		fake := newFakeGitHub(t)
+		scm := scmgithub.New(fake.client(t), "owner", "repo")
+
+		// the pull request is still opened without the details
+		_, err := scm.Propose(ctx, git.Proposal{
+			ID:              id,
+			Head:            head,
+			Base:            "main",
+			ProposalDetails: core.ProposalDetails{Milestone: "v2.0"},
+		})
+		require.NoError(t, err)
+
+		assert.Contains(t, fake.requests, "POST /repos/owner/repo/pulls")
+		assert.NotContains(t, fake.requests, "PATCH /repos/owner/repo/issues/1")
+	})
+}
+
+func Test_SCM_Get(t *testing.T) {
+	var (
+		ctx       = context.Background()
+		fake      = newFakeGitHub(t)
+		id        = ulid.Make()
+		templated = ulid.Make()
+		head      = fmt.Sprintf("flags/baz-%s", templated)
+	)
+
+	fake.pulls = []*github.PullRequest{
+		{Number: github.Int(1), State: github.String("open"), Head: &github.PullRequestBranch{Ref: github.String(git.ProposalBranch(id))}},
+		{Number: github.Int(2), State: github.String("open"), Head: &github.PullRequestBranch{Ref: github.String(head)}},
+	}
+
+	scm := scmgithub.New(fake.client(t), "owner", "repo")
+
+	t.Run("default branch is found by its head", func(t *testing.T) {
+		fake.lists = nil
+
+		proposal, err := scm.Get(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, git.ProposalBranch(id), proposal.Head)
+
+		require.Len(t, fake.lists, 1)
+		assert.Equal(t, "owner:"+git.ProposalBranch(id), fake.lists[0].Get("head"))
+	})
+
+	t.Run("templated branch is scanned for once", func(t *testing.T) {
+		fake.lists = nil
+
+		proposal, err := scm.Get(ctx, templated)
+		require.NoError(t, err)
+		assert.Equal(t, head, proposal.Head)
+
+		// the default branch misses, so every pull request is scanned
+		require.Len(t, fake.lists, 2)
+		assert.Empty(t, fake.lists[1].Get("head"))
+
+		fake.lists = nil
+
+		_, err = scm.Get(ctx, templated)
+		require.NoError(t, err)
+
+		// the head found is recorded for subsequent lookups
+		require.Len(t, fake.lists, 1)
+		assert.Equal(t, "owner:"+head, fake.lists[0].Get("head"))
+	})
+
+	t.Run("unknown proposal", func(t *testing.T) {
+		_, err := scm.Get(ctx, ulid.Make())
+		require.ErrorIs(t, err, api.ErrProposalNotFound)
+	})
+}
+
+func Test_SCM_AutoMerge(t *testing.T) {
+	var (
+		ctx  = context.Background()
+		fake = newFakeGitHub(t)
+		id   = ulid.Make()
+	)
+
+	fake.pulls = []*github.PullRequest{{
+		Number: github.Int(1),
+		NodeID: github.String("PR_1"),
+		State:  github.String("open"),
+		Head:   &github.PullRequestBranch{Ref: github.String(git.ProposalBranch(id)), SHA: github.String("abc")},
+	}}
+
+	scm := scmgithub.New(fake.client(t), "owner", "repo")
+
+	require.NoError(t, scm.AutoMerge(ctx, id))
+
+	graphql := fake.requests["POST /graphql"]
+	assert.Contains(t, graphql["query"], "enablePullRequestAutoMerge")
+	assert.Equal(t, map[string]any{"id": "PR_1"}, graphql["variables"])
+
+	fake.pulls[0].Base = &github.PullRequestBranch{Ref: github.String("main")}
+
+	for _, test := range []struct {
+		name     string
+		required []string
+		statuses []*github.RepoStatus
+		runs     []*github.CheckRun
+		expected git.CheckStatus
+	}{
+		{
+			name:     "no checks",
+			expected: git.CheckStatusPending,
+		},
+		{
+			name:     "pending status",
+			statuses: []*github.RepoStatus{{Context: github.String("ci"), State: github.String("pending")}},
+			runs: []*github.CheckRun{
+				{Name: github.String("test"), Status: github.String("completed"), Conclusion: github.String("success")},
+			},
+			expected: git.CheckStatusPending,
+		},
+		{
+			name:     "running check",
+			statuses: []*github.RepoStatus{{Context: github.String("ci"), State: github.String("success")}},
+			runs: []*github.CheckRun{
+				{Name: github.String("test"), Status: github.String("in_progress")},
+			},
+			expected: git.CheckStatusPending,
+		},
+		{
+			name:     "failed check",
+			statuses: []*github.RepoStatus{{Context: github.String("ci"), State: github.String("success")}},
+			runs: []*github.CheckRun{
+				{Name: github.String("test"), Status: github.String("in_progress")},
+				{Name: github.String("lint"), Status: github.String("completed"), Conclusion: github.String("failure")},
+			},
+			expected: git.CheckStatusFailure,
+		},
+		{
+			name:     "passed",
+			statuses: []*github.RepoStatus{{Context: github.String("ci"), State: github.String("success")}},
+			runs: []*github.CheckRun{
+				{Name: github.String("test"), Status: github.String("completed"), Conclusion: github.String("success")},
+				{Name: github.String("lint"), Status: github.String("completed"), Conclusion: github.String("skipped")},
+			},
+			expected: git.CheckStatusSuccess,
+		},
+		{
+			name:     "required check not yet reported",
+			required: []string{"test"},
+			statuses: []*github.RepoStatus{{Context: github.String("ci"), State: github.String("success")}},
+			expected: git.CheckStatusPending,
+		},
+		{
+			name:     "optional check failed",
+			required: []string{"test"},
+			runs: []*github.CheckRun{
+				{Name: github.String("test"), Status: github.String("completed"), Conclusion: github.String("success")},
+				{Name: github.String("lint"), Status: github.String("completed"), Conclusion: github.String("failure")},
+			},
+			expected: git.CheckStatusSuccess,
+		},
+		{
+			name:     "required check failed",
+			required: []string{"ci", "test"},
+			statuses: []*github.RepoStatus{{Context: github.String("ci"), State: github.String("error")}},
+			runs: []*github.CheckRun{
+				{Name: github.String("test"), Status: github.String("completed"), Conclusion: github.String("success")},
+			},
+			expected: git.CheckStatusFailure,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			fake.required, fake.statuses, fake.runs = test.required, test.statuses, test.runs
+
+			status, err := scm.Checks(ctx, id)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, status)
+		})
+	}
+}
+
+// fakeGitHub is a minimal stand-in for the GitHub pull requests, issues, milestones,
+// branch protection, statuses, checks and GraphQL APIs.
+// It records the body of each request by method and path.
+type fakeGitHub struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	requests map[string]map[string]any
+
+	pulls []*github.PullRequest
+	// lists are the queries with which pull requests have been listed
+	lists    []url.Values
+	required []string
+	statuses []*github.RepoStatus
+	runs     []*github.CheckRun
+}
+
+func newFakeGitHub(t *testing.T) *fakeGitHub {
+	t.Helper()
+
+	f := &fakeGitHub{requests: map[string]map[string]any{}}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.Close)
+
+	return f
+}
+
+func (f *fakeGitHub) client(t *testing.T) *github.Client {
+	t.Helper()
+
+	client := github.NewClient(nil)
+
+	var err error
+	client.BaseURL, err = url.Parse(f.URL + "/")
+	require.NoError(t, err)
+
+	return client
+}
+
+func (f *fakeGitHub) serve(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	key := r.Method + " " + r.URL.Path
+
+	if data, _ := io.ReadAll(r.Body); len(data) > 0 {
+		var body map[string]any
+		if err := json.Unmarshal(data, &body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		f.requests[key] = body
+	}
+
+	switch key {
+	case "POST /repos/owner/repo/pulls":
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(&github.PullRequest{
+			Number:  github.Int(1),
+			State:   github.String("open"),
+			HTMLURL: github.String("https://github.com/owner/repo/pull/1"),
+			Head:    &github.PullRequestBranch{Ref: github.String(f.requests[key]["head"].(string))},
+			Base:    &github.PullRequestBranch{Ref: github.String(f.requests[key]["base"].(string))},
+		})
+	case "POST /repos/owner/repo/pulls/1/requested_reviewers":
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(&github.PullRequest{Number: github.Int(1)})
+	case "GET /repos/owner/repo/milestones":
+		_ = json.NewEncoder(w).Encode([]*github.Milestone{
+			{Number: github.Int(2), Title: github.String("v0.9")},
+			{Number: github.Int(3), Title: github.String("v1.0")},
+		})
+	case "PATCH /repos/owner/repo/issues/1":
+		_ = json.NewEncoder(w).Encode(&github.Issue{Number: github.Int(1)})
+	case "GET /repos/owner/repo/pulls":
+		query := r.URL.Query()
+		f.lists = append(f.lists, query)
+
+		found := []*github.PullRequest{}
+		for _, pr := range f.pulls {
+			if head := query.Get("head"); head == "" || head == "owner:"+pr.GetHead().GetRef() {
+				found = append(found, pr)
+			}
+		}
+
+		_ = json.NewEncoder(w).Encode(found)
+	case "POST /graphql":
+		_ = json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{}})
+	case "GET /repos/owner/repo/branches/main/protection/required_status_checks":
+		if f.required == nil {
+			w.WriteHeader(http.StatusNotFound)
+			_ = json.NewEncoder(w).Encode(map[string]any{"message": "Branch not protected"})
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(&github.RequiredStatusChecks{Contexts: f.required})
+	case "GET /repos/owner/repo/commits/abc/status":
+		_ = json.NewEncoder(w).Encode(&github.CombinedStatus{TotalCount: github.Int(len(f.statuses)), Statuses: f.statuses})
+	case "GET /repos/owner/repo/commits/abc/check-runs":
+		_ = json.NewEncoder(w).Encode(&github.ListCheckRunsResults{Total: github.Int(len(f.runs)), CheckRuns: f.runs})
+	default:
+		http.NotFound(w, r)
+	}
+}
@@ -0,0 +1,220 @@
+package gitlab
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/xanzy/go-gitlab"
+	"go.flipt.io/cup/pkg/api"
+	"go.flipt.io/cup/pkg/source/git"
+)
+
+var _ git.AutoMerger = (*SCM)(nil)
+
+// SCM is an implementation of git.SCM which opens merge requests on GitLab.
+type SCM struct {
+	client  *gitlab.Client
+	project string
+	heads   git.ProposalHeads
+}
+
+// New constructs a GitLab SCM for the project identified by its owner (user or group path)
+// and repository name.
+func New(client *gitlab.Client, owner, repository string) *SCM {
+	return &SCM{
+		client:  client,
+		project: fmt.Sprintf("%s/%s", owner, repository),
+	}
+}
+
+func (s *SCM) Merge(ctx context.Context, id ulid.ULID) error {
+	mr, err := s.find(ctx, id, "opened")
+	if err != nil {
+		return fmt.Errorf("merging: %w", err)
+	}
+
+	mr, _, err = s.client.MergeRequests.AcceptMergeRequest(s.project, mr.IID, &gitlab.AcceptMergeRequestOptions{}, gitlab.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+
+	if mr.State != "merged" {
+		return fmt.Errorf("proposal %q could not be merged: %q", id, mr.MergeStatus)
+	}
+
+	return nil
+}
+
+// AutoMerge sets the merge request for the open proposal identified by id
+// to merge when its pipeline succeeds.
+func (s *SCM) AutoMerge(ctx context.Context, id ulid.ULID) error {
+	mr, err := s.find(ctx, id, "opened")
+	if err != nil {
+		return fmt.Errorf("enabling auto-merge: %w", err)
+	}
+
+	if _, _, err := s.client.MergeRequests.AcceptMergeRequest(s.project, mr.IID, &gitlab.AcceptMergeRequestOptions{
+		MergeWhenPipelineSucceeds: gitlab.Bool(true),
+	}, gitlab.WithContext(ctx)); err != nil {
+		return fmt.Errorf("enabling auto-merge: %w", err)
+	}
+
+	return nil
+}
+
+func (s *SCM) Close(ctx context.Context, id ulid.ULID) error {
+	mr, err := s.find(ctx, id, "opened")
+	if err != nil {
+		return fmt.Errorf("closing: %w", err)
+	}
+
+	if _, _, err := s.client.MergeRequests.UpdateMergeRequest(s.project, mr.IID, &gitlab.UpdateMergeRequestOptions{
+		StateEvent: gitlab.String("close"),
+	}, gitlab.WithContext(ctx)); err != nil {
+		return fmt.Errorf("closing: %w", err)
+	}
+
+	return nil
+}
+
+func (s *SCM) Comment(ctx context.Context, id ulid.ULID, body string) error {
+	mr, err := s.find(ctx, id, "opened")
+	if err != nil {
+		return fmt.Errorf("commenting: %w", err)
+	}
+
+	if _, _, err := s.client.Notes.CreateMergeRequestNote(s.project, mr.IID, &gitlab.CreateMergeRequestNoteOptions{
+		Body: gitlab.String(body),
+	}, gitlab.WithContext(ctx)); err != nil {
+		return fmt.Errorf("commenting: %w", err)
+	}
+
+	return nil
+}
+
+func (s *SCM) Get(ctx context.Context, id ulid.ULID) (*api.Proposal, error) {
+	mr, err := s.find(ctx, id, "all")
+	if err != nil {
+		return nil, err
+	}
+
+	return proposal(id, mr), nil
+}
+
+func (s *SCM) List(ctx context.Context) (proposals []*api.Proposal, _ error) {
+	opts := &gitlab.ListProjectMergeRequestsOptions{
+		State:       gitlab.String("opened"),
+		ListOptions: gitlab.ListOptions{PerPage: 100},
+	}
+
+	for {
+		mrs, resp, err := s.client.MergeRequests.ListProjectMergeRequests(s.project, opts, gitlab.WithContext(ctx))
+		if err != nil {
+			return nil, fmt.Errorf("listing: %w", err)
+		}
+
+		for _, mr := range mrs {
+			if id, ok := git.ProposalID(mr.SourceBranch); ok {
+				s.heads.Record(id, mr.SourceBranch)
+				proposals = append(proposals, proposal(id, mr))
+			}
+		}
+
+		if resp.NextPage == 0 {
+			return proposals, nil
+		}
+
+		opts.Page = resp.NextPage
+	}
+}
+
+func (s *SCM) Propose(ctx context.Context, p git.Proposal) (*api.Proposal, error) {
+	mr, _, err := s.client.MergeRequests.CreateMergeRequest(s.project, &gitlab.CreateMergeRequestOptions{
+		SourceBranch: gitlab.String(p.Head),
+		TargetBranch: gitlab.String(p.Base),
+		Title:        gitlab.String(p.Title),
+		Description:  gitlab.String(p.Body),
+	}, gitlab.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	s.heads.Record(p.ID, p.Head)
+
+	return proposal(p.ID, mr), nil
+}
+
+// find returns the merge request for the proposal identified by id in the provided state.
+// Merge requests are first filtered by the source branches the proposal is known or expected to be pushed to.
+// The name of a proposal branch depends on how it was templated, so when none of these match,
+// merge requests are matched on the proposal ID parsed from their source branch, starting with the most recent.
+func (s *SCM) find(ctx context.Context, id ulid.ULID, state string) (*gitlab.MergeRequest, error) {
+	for _, head := range s.heads.Candidates(id) {
+		mr, err := s.search(ctx, id, &gitlab.ListProjectMergeRequestsOptions{
+			State:        gitlab.String(state),
+			SourceBranch: gitlab.String(head),
+			ListOptions:  gitlab.ListOptions{PerPage: 100},
+		})
+		if !errors.Is(err, api.ErrProposalNotFound) {
+			return mr, err
+		}
+	}
+
+	return s.search(ctx, id, &gitlab.ListProjectMergeRequestsOptions{
+		State:       gitlab.String(state),
+		OrderBy:     gitlab.String("created_at"),
+		Sort:        gitlab.String("desc"),
+		ListOptions: gitlab.ListOptions{PerPage: 100},
+	})
+}
+
+// search pages through the merge requests listed with opts for the proposal identified by id
+// and records the source branch of the merge request when it is found.
+func (s *SCM) search(ctx context.Context, id ulid.ULID, opts *gitlab.ListProjectMergeRequestsOptions) (*gitlab.MergeRequest, error) {
+	for {
+		mrs, resp, err := s.client.MergeRequests.ListProjectMergeRequests(s.project, opts, gitlab.WithContext(ctx))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, mr := range mrs {
+			if match, ok := git.ProposalID(mr.SourceBranch); ok && match == id {
+				s.heads.Record(id, mr.SourceBranch)
+				return mr, nil
+			}
+		}
+
+		if resp.NextPage == 0 {
+			return nil, fmt.Errorf("%w: %s", api.ErrProposalNotFound, id)
+		}
+
+		opts.Page = resp.NextPage
+	}
+}
+
+func proposal(id ulid.ULID, mr *gitlab.MergeRequest) *api.Proposal {
+	status := api.ProposalStatusOpen
+	switch mr.State {
+	case "merged":
+		status = api.ProposalStatusMerged
+	case "closed", "locked":
+		status = api.ProposalStatusClosed
+	}
+
+	proposal := &api.Proposal{
+		ID:     id,
+		Source: "gitlab",
+		URL:    mr.WebURL,
+		Status: status,
+		Head:   mr.SourceBranch,
+		Base:   mr.TargetBranch,
+	}
+
+	if mr.MergeWhenPipelineSucceeds {
+		proposal.AutoMerge = &api.AutoMerge{State: api.AutoMergeStatePending, Native: true}
+	}
+
+	return proposal
+}
@@ -0,0 +1,200 @@
+package gitlab_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gogitlab "github.com/xanzy/go-gitlab"
+	"go.flipt.io/cup/pkg/api"
+	"go.flipt.io/cup/pkg/source/git"
+	"go.flipt.io/cup/pkg/source/git/scm/gitlab"
+)
+
+func Test_SCM_Propose_Merge(t *testing.T) {
+	var (
+		ctx  = context.Background()
+		fake = newFakeGitLab(t)
+		id   = ulid.Make()
+		head = fmt.Sprintf("cup/proposal/%s", id)
+	)
+
+	client, err := gogitlab.NewClient("token", gogitlab.WithBaseURL(fake.URL))
+	require.NoError(t, err)
+
+	scm := gitlab.New(client, "group/subgroup", "repo")
+
+	proposal, err := scm.Propose(ctx, git.Proposal{
+		ID:    id,
+		Head:  head,
+		Base:  "main",
+		Title: "feat: update resource",
+		Body:  "some description",
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, &api.Proposal{
+		ID:     id,
+		Source: "gitlab",
+		URL:    fake.URL + "/group/subgroup/repo/-/merge_requests/1",
+		Status: api.ProposalStatusOpen,
+		Head:   head,
+		Base:   "main",
+	}, proposal)
+
+	mr := fake.mrs[0]
+	assert.Equal(t, head, mr.SourceBranch)
+	assert.Equal(t, "main", mr.TargetBranch)
+	assert.Equal(t, "feat: update resource", mr.Title)
+	assert.Equal(t, "some description", mr.Description)
+	assert.Equal(t, "token", fake.token)
+
+	proposals, err := scm.List(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []*api.Proposal{proposal}, proposals)
+
+	require.NoError(t, scm.Merge(ctx, id))
+	assert.Equal(t, "merged", fake.mrs[0].State)
+
+	merged, err := scm.Get(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, api.ProposalStatusMerged, merged.Status)
+
+	proposals, err = scm.List(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, proposals)
+
+	err = scm.Merge(ctx, ulid.Make())
+	require.ErrorIs(t, err, api.ErrProposalNotFound)
+}
+
+func Test_SCM_Close(t *testing.T) {
+	var (
+		ctx  = context.Background()
+		fake = newFakeGitLab(t)
+		id   = ulid.Make()
+	)
+
+	client, err := gogitlab.NewClient("token", gogitlab.WithBaseURL(fake.URL))
+	require.NoError(t, err)
+
+	scm := gitlab.New(client, "group/subgroup", "repo")
+
+	_, err = scm.Propose(ctx, git.Proposal{
+		ID:   id,
+		Head: git.ProposalBranch(id),
+		Base: "main",
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, scm.Close(ctx, id))
+
+	closed, err := scm.Get(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, api.ProposalStatusClosed, closed.Status)
+
+	_, err = scm.Get(ctx, ulid.Make())
+	require.ErrorIs(t, err, api.ErrProposalNotFound)
+}
+
+// fakeGitLab is a minimal stand-in for the GitLab merge requests API.
+type fakeGitLab struct {
+	*httptest.Server
+
+	mu    sync.Mutex
+	token string
+	mrs   []*gogitlab.MergeRequest
+}
+
+func newFakeGitLab(t *testing.T) *fakeGitLab {
+	t.Helper()
+
+	f := &fakeGitLab{}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.Close)
+
+	return f
+}
+
+func (f *fakeGitLab) serve(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.token = r.Header.Get("Private-Token")
+
+	const prefix = "/api/v4/projects/group%2Fsubgroup%2Frepo/merge_requests"
+
+	path := r.URL.EscapedPath()
+	switch {
+	case r.Method == http.MethodPost && path == prefix:
+		var opts gogitlab.CreateMergeRequestOptions
+		if err := json.NewDecoder(r.Body).Decode(&opts); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		iid := len(f.mrs) + 1
+		mr := &gogitlab.MergeRequest{
+			IID:          iid,
+			State:        "opened",
+			SourceBranch: *opts.SourceBranch,
+			TargetBranch: *opts.TargetBranch,
+			Title:        *opts.Title,
+			Description:  *opts.Description,
+			WebURL:       fmt.Sprintf("%s/group/subgroup/repo/-/merge_requests/%d", f.URL, iid),
+		}
+
+		f.mrs = append(f.mrs, mr)
+
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(mr)
+	case r.Method == http.MethodGet && path == prefix:
+		var (
+			source = r.URL.Query().Get("source_branch")
+			state  = r.URL.Query().Get("state")
+			found  = []*gogitlab.MergeRequest{}
+		)
+
+		for _, mr := range f.mrs {
+			if (source == "" || mr.SourceBranch == source) && (state == "all" || mr.State == state) {
+				found = append(found, mr)
+			}
+		}
+
+		_ = json.NewEncoder(w).Encode(found)
+	case r.Method == http.MethodPut:
+		iid, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(path, prefix+"/"), "/merge"))
+		if err != nil || iid < 1 || iid > len(f.mrs) {
+			http.NotFound(w, r)
+			return
+		}
+
+		mr := f.mrs[iid-1]
+		if strings.HasSuffix(path, "/merge") {
+			mr.State = "merged"
+		} else {
+			var opts gogitlab.UpdateMergeRequestOptions
+			if err := json.NewDecoder(r.Body).Decode(&opts); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			if opts.StateEvent != nil && *opts.StateEvent == "close" {
+				mr.State = "closed"
+			}
+		}
+
+		_ = json.NewEncoder(w).Encode(mr)
+	default:
+		http.NotFound(w, r)
+	}
+}
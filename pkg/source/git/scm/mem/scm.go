@@ -2,27 +2,179 @@ package mem
 
 import (
 	"context"
+	"fmt"
+	"sync"
 
 	"github.com/oklog/ulid/v2"
 	"go.flipt.io/cup/pkg/api"
 	"go.flipt.io/cup/pkg/source/git"
 )
 
+var (
+	_ git.SCM     = (*SCM)(nil)
+	_ git.Checker = (*SCM)(nil)
+)
+
 // SCM is an in-memory representation of the git.SCM interface.
 // For now it simply stores proposals in a map and it primarily used
 // for unit testing.
+// Merging and closing only update the status of a proposal.
+// The status of the checks on each proposal is set via SetChecks.
 type SCM struct {
-	proposals map[ulid.ULID]git.Proposal
+	mu        sync.Mutex
+	proposals map[ulid.ULID]*api.Proposal
+	proposed  map[ulid.ULID]git.Proposal
+	comments  map[ulid.ULID][]string
+	checks    map[ulid.ULID]git.CheckStatus
 }
 
 // New constructs and configures a new instance of SCM.
 func New() *SCM {
-	return &SCM{proposals: map[ulid.ULID]git.Proposal{}}
+	return &SCM{
+		proposals: map[ulid.ULID]*api.Proposal{},
+		proposed:  map[ulid.ULID]git.Proposal{},
+		comments:  map[ulid.ULID][]string{},
+		checks:    map[ulid.ULID]git.CheckStatus{},
+	}
+}
+
+// Propose stores the provided proposal in a map and returns it as open.
+func (s *SCM) Propose(_ context.Context, p git.Proposal) (*api.Proposal, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	proposal := &api.Proposal{
+		ID:     p.ID,
+		Source: "mem",
+		Status: api.ProposalStatusOpen,
+		Head:   p.Head,
+		Base:   p.Base,
+	}
+
+	s.proposals[p.ID] = proposal
+	s.proposed[p.ID] = p
+
+	return copyProposal(proposal), nil
+}
+
+// List returns all the open proposals.
+func (s *SCM) List(context.Context) (proposals []*api.Proposal, _ error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, p := range s.proposals {
+		if p.Status == api.ProposalStatusOpen {
+			proposals = append(proposals, copyProposal(p))
+		}
+	}
+
+	return proposals, nil
+}
+
+// Get returns the proposal identified by id.
+func (s *SCM) Get(_ context.Context, id ulid.ULID) (*api.Proposal, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.proposals[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", api.ErrProposalNotFound, id)
+	}
+
+	return copyProposal(p), nil
+}
+
+// Merge marks the open proposal identified by id as merged.
+func (s *SCM) Merge(_ context.Context, id ulid.ULID) error {
+	return s.transition(id, api.ProposalStatusMerged)
+}
+
+// Close marks the open proposal identified by id as closed.
+func (s *SCM) Close(_ context.Context, id ulid.ULID) error {
+	return s.transition(id, api.ProposalStatusClosed)
+}
+
+// Comment records the comment against the open proposal identified by id.
+func (s *SCM) Comment(_ context.Context, id ulid.ULID, body string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.proposals[id]
+	if !ok {
+		return fmt.Errorf("%w: %s", api.ErrProposalNotFound, id)
+	}
+
+	if p.Status != api.ProposalStatusOpen {
+		return fmt.Errorf("proposal %q is %s", id, p.Status)
+	}
+
+	s.comments[id] = append(s.comments[id], body)
+
+	return nil
+}
+
+// Comments returns the comments made on the proposal identified by id.
+func (s *SCM) Comments(id ulid.ULID) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string(nil), s.comments[id]...)
+}
+
+// Proposed returns the proposal as it was requested (including its title and body).
+func (s *SCM) Proposed(id ulid.ULID) (git.Proposal, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.proposed[id]
+	return p, ok
+}
+
+// Checks returns the status of the checks on the open proposal identified by id.
+// Proposals without a status set via SetChecks have yet to report any checks and so are pending.
+func (s *SCM) Checks(_ context.Context, id ulid.ULID) (git.CheckStatus, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.proposals[id]
+	if !ok || p.Status != api.ProposalStatusOpen {
+		return "", fmt.Errorf("%w: %s", api.ErrProposalNotFound, id)
+	}
+
+	if status, ok := s.checks[id]; ok {
+		return status, nil
+	}
+
+	return git.CombineChecks(nil, nil), nil
+}
+
+// SetChecks sets the status of the checks on the proposal identified by id.
+func (s *SCM) SetChecks(id ulid.ULID, status git.CheckStatus) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.checks[id] = status
 }
 
-// Propose stores the provided proposal in a map and returns a nil error and empty response.
-func (s *SCM) Propose(_ context.Context, p git.Proposal) (*api.Result, error) {
-	s.proposals[p.ID] = p
+func (s *SCM) transition(id ulid.ULID, status api.ProposalStatus) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.proposals[id]
+	if !ok {
+		return fmt.Errorf("%w: %s", api.ErrProposalNotFound, id)
+	}
+
+	if p.Status != api.ProposalStatusOpen {
+		return fmt.Errorf("proposal %q is %s", id, p.Status)
+	}
+
+	p.Status = status
+
+	return nil
+}
 
-	return &api.Result{ID: p.ID}, nil
+func copyProposal(p *api.Proposal) *api.Proposal {
+	c := *p
+	return &c
 }
@@ -0,0 +1,188 @@
+// Package signing provides the signers used to sign the commits created by a git.Source.
+// Both OpenPGP and SSH keys are supported, producing signatures which git itself can verify
+// (with gpg.format set to openpgp or ssh respectively).
+package signing
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"go.flipt.io/cup/pkg/config"
+	"go.flipt.io/cup/pkg/source/git"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	// FormatOpenPGP signs commits using an armored OpenPGP private key.
+	FormatOpenPGP = "openpgp"
+	// FormatSSH signs commits using an SSH private key.
+	FormatSSH = "ssh"
+
+	// sshNamespace is the namespace git uses when signing commits with SSH keys.
+	sshNamespace = "git"
+	// sshHashAlgorithm is the hash of the message which is signed.
+	sshHashAlgorithm = "sha512"
+)
+
+// Load returns the signer configured by cfg.
+// It returns nil when no signing key is configured.
+func Load(cfg config.GitSigning) (git.Signer, error) {
+	if cfg.KeyFile == "" {
+		return nil, nil
+	}
+
+	key, err := os.ReadFile(cfg.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("reading signing key: %w", err)
+	}
+
+	var passphrase string
+	if cfg.PassphraseEnv != "" {
+		passphrase = os.Getenv(cfg.PassphraseEnv)
+	}
+
+	switch cfg.Format {
+	case FormatOpenPGP, "":
+		return NewOpenPGP(key, passphrase)
+	case FormatSSH:
+		return NewSSH(key, passphrase)
+	default:
+		return nil, fmt.Errorf("signing format not supported: %q", cfg.Format)
+	}
+}
+
+// OpenPGP signs messages with an OpenPGP private key.
+type OpenPGP struct {
+	entity *openpgp.Entity
+}
+
+// NewOpenPGP constructs an OpenPGP signer from the first key in the provided
+// armored private key ring, decrypting it with passphrase when necessary.
+func NewOpenPGP(armored []byte, passphrase string) (*OpenPGP, error) {
+	entities, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(armored))
+	if err != nil {
+		return nil, fmt.Errorf("reading openpgp key: %w", err)
+	}
+
+	if len(entities) == 0 {
+		return nil, errors.New("openpgp key ring contains no keys")
+	}
+
+	entity := entities[0]
+	if entity.PrivateKey == nil {
+		return nil, errors.New("openpgp key has no private key")
+	}
+
+	if entity.PrivateKey.Encrypted {
+		if err := entity.DecryptPrivateKeys([]byte(passphrase)); err != nil {
+			return nil, fmt.Errorf("decrypting openpgp key: %w", err)
+		}
+	}
+
+	return &OpenPGP{entity: entity}, nil
+}
+
+// Sign returns the armored detached signature of the message.
+func (s *OpenPGP) Sign(message io.Reader) (string, error) {
+	buf := &bytes.Buffer{}
+	if err := openpgp.ArmoredDetachSign(buf, s.entity, message, nil); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
+// SSH signs messages with an SSH private key using the SSHSIG format.
+type SSH struct {
+	signer ssh.Signer
+}
+
+// NewSSH constructs an SSH signer from the provided PEM encoded private key,
+// decrypting it with passphrase when non-empty.
+func NewSSH(pemKey []byte, passphrase string) (*SSH, error) {
+	var (
+		signer ssh.Signer
+		err    error
+	)
+
+	if passphrase != "" {
+		signer, err = ssh.ParsePrivateKeyWithPassphrase(pemKey, []byte(passphrase))
+	} else {
+		signer, err = ssh.ParsePrivateKey(pemKey)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("reading ssh key: %w", err)
+	}
+
+	return &SSH{signer: signer}, nil
+}
+
+// Sign returns the armored SSHSIG signature of the message in the git namespace.
+// See https://github.com/openssh/openssh-portable/blob/master/PROTOCOL.sshsig
+func (s *SSH) Sign(message io.Reader) (string, error) {
+	h := sha512.New()
+	if _, err := io.Copy(h, message); err != nil {
+		return "", err
+	}
+
+	signed := append([]byte("SSHSIG"), ssh.Marshal(struct {
+		Namespace     string
+		Reserved      string
+		HashAlgorithm string
+		Hash          []byte
+	}{sshNamespace, "", sshHashAlgorithm, h.Sum(nil)})...)
+
+	var (
+		signature *ssh.Signature
+		err       error
+	)
+
+	// RSA keys must not use the legacy SHA-1 based ssh-rsa signature algorithm
+	if algSigner, ok := s.signer.(ssh.AlgorithmSigner); ok && s.signer.PublicKey().Type() == ssh.KeyAlgoRSA {
+		signature, err = algSigner.SignWithAlgorithm(rand.Reader, signed, ssh.KeyAlgoRSASHA512)
+	} else {
+		signature, err = s.signer.Sign(rand.Reader, signed)
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	blob := append([]byte("SSHSIG"), ssh.Marshal(struct {
+		Version       uint32
+		PublicKey     []byte
+		Namespace     string
+		Reserved      string
+		HashAlgorithm string
+		Signature     []byte
+	}{1, s.signer.PublicKey().Marshal(), sshNamespace, "", sshHashAlgorithm, ssh.Marshal(signature)})...)
+
+	return armor("SSH SIGNATURE", blob), nil
+}
+
+// armor encodes data as base64 wrapped at 70 columns between BEGIN and END markers,
+// matching the output of ssh-keygen -Y sign.
+func armor(typ string, data []byte) string {
+	encoded := base64.StdEncoding.EncodeToString(data)
+
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "-----BEGIN %s-----\n", typ)
+	for len(encoded) > 70 {
+		fmt.Fprintln(b, encoded[:70])
+		encoded = encoded[70:]
+	}
+
+	fmt.Fprintln(b, encoded)
+	fmt.Fprintf(b, "-----END %s-----\n", typ)
+
+	return b.String()
+}
@@ -4,8 +4,12 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-git/go-billy/v5/osfs"
@@ -13,16 +17,33 @@ import (
 	"github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
 	"github.com/go-git/go-git/v5/plumbing/transport"
-	"github.com/go-git/go-git/v5/storage/memory"
+	"github.com/go-git/go-git/v5/storage"
 	"github.com/oklog/ulid/v2"
 	"go.flipt.io/cup/pkg/api"
+	"go.flipt.io/cup/pkg/api/core"
 	"go.flipt.io/cup/pkg/containers"
 	"go.flipt.io/cup/pkg/controllers"
 	"go.flipt.io/cup/pkg/gitfs"
 )
 
-var _ api.Source = (*Source)(nil)
+var (
+	_ api.WatchableSource   = (*Source)(nil)
+	_ api.ProposalSource    = (*Source)(nil)
+	_ api.RefreshableSource = (*Source)(nil)
+)
+
+const (
+	// proposalBranchPrefix is the prefix of all branches created for proposals.
+	proposalBranchPrefix = "cup/proposal/"
+	// trailerProposal is the commit trailer identifying the proposal a commit belongs to.
+	trailerProposal = "Cup-Proposal"
+	// trailerResource is the commit trailer identifying a resource affected by a commit.
+	trailerResource = "Cup-Resource"
+	// trailerCoAuthor is the commit trailer crediting the caller who requested a commit.
+	trailerCoAuthor = "Co-authored-by"
+)
 
 // Proposal is the internal representation of what becomes a pull or merge request
 // on a target SCM.
@@ -34,26 +55,157 @@ type Proposal struct {
 	Base  string
 	Title string
 	Body  string
+	// ProposalDetails are the reviewers, labels and other details set on the proposal.
+	core.ProposalDetails
 }
 
 // SCM is an abstraction around repositories and source control providers.
 type SCM interface {
+	// Propose opens a new proposal (pull or merge request) from the head to the base branch.
+	// Details of the proposal which the SCM does not support are ignored.
 	Propose(context.Context, Proposal) (*api.Proposal, error)
+	// List returns all the open proposals made by cup.
+	List(context.Context) ([]*api.Proposal, error)
+	// Get returns the proposal identified by id in any state.
+	// It returns an error wrapping api.ErrProposalNotFound when it does not exist.
+	Get(_ context.Context, id ulid.ULID) (*api.Proposal, error)
+	// Merge merges the open proposal identified by id.
+	Merge(_ context.Context, id ulid.ULID) error
+	// Close closes the open proposal identified by id without merging.
+	Close(_ context.Context, id ulid.ULID) error
+	// Comment adds a comment with the provided (markdown) body to the open proposal identified by id.
+	Comment(_ context.Context, id ulid.ULID, body string) error
+}
+
+// Signer signs the commits created by a Source.
+type Signer interface {
+	// Sign returns the armored signature of the encoded commit read from message.
+	Sign(message io.Reader) (string, error)
+}
+
+// ProposalBranch returns the name of the branch for the proposal identified by id.
+func ProposalBranch(id ulid.ULID) string {
+	return proposalBranchPrefix + id.String()
+}
+
+// ProposalHeads records the branch each proposal was pushed to, so that an SCM can look up
+// the pull or merge request for a proposal by its head before scanning every one of them.
+// The zero value is ready to use.
+type ProposalHeads struct {
+	mu    sync.Mutex
+	heads map[ulid.ULID]string
+}
+
+// Record records head as the branch of the proposal identified by id.
+func (h *ProposalHeads) Record(id ulid.ULID, head string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.heads == nil {
+		h.heads = map[ulid.ULID]string{}
+	}
+
+	h.heads[id] = head
+}
+
+// Candidates returns the branches the proposal identified by id is most likely pushed to:
+// the recorded head (if any) followed by the default proposal branch.
+func (h *ProposalHeads) Candidates(id ulid.ULID) []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	candidates := []string{ProposalBranch(id)}
+	if head, ok := h.heads[id]; ok && head != candidates[0] {
+		candidates = append([]string{head}, candidates...)
+	}
+
+	return candidates
+}
+
+// ProposalID parses the proposal ID from the provided branch name.
+// Proposal branches contain the ID of the proposal as one of the path segments,
+// or one of the words separated by "-", "_" or "." within them
+// (e.g. cup/proposal/01H5... or flags/01H5...-enable-new-ui).
+// It returns false when the branch is not a proposal branch.
+func ProposalID(branch string) (ulid.ULID, bool) {
+	for _, word := range strings.FieldsFunc(branch, func(r rune) bool {
+		return r == '/' || r == '-' || r == '_' || r == '.'
+	}) {
+		if len(word) != ulid.EncodedSize {
+			continue
+		}
+
+		if parsed, err := ulid.ParseStrict(word); err == nil {
+			return parsed, true
+		}
+	}
+
+	return ulid.ULID{}, false
+}
+
+// validateBranch returns an error when the provided name is not a valid branch name
+// according to the rules of git check-ref-format.
+func validateBranch(name string) error {
+	if name == "" || name == "@" || strings.HasPrefix(name, "/") || strings.HasSuffix(name, "/") ||
+		strings.HasSuffix(name, ".") || strings.Contains(name, "..") || strings.Contains(name, "@{") {
+		return fmt.Errorf("invalid branch name %q", name)
+	}
+
+	if i := strings.IndexFunc(name, func(r rune) bool {
+		return r < 0x20 || r == 0x7f || strings.ContainsRune(" ~^:?*[\\", r)
+	}); i >= 0 {
+		return fmt.Errorf("invalid branch name %q: contains %q", name, name[i])
+	}
+
+	for _, component := range strings.Split(name, "/") {
+		if component == "" || strings.HasPrefix(component, ".") || strings.HasSuffix(component, ".lock") {
+			return fmt.Errorf("invalid branch name %q", name)
+		}
+	}
+
+	return nil
 }
 
 // Source is an implementation of api.Source
 // This implementation is backed by a Git repository and it tracks an upstream reference.
 // When subscribing to this source, the upstream reference is tracked
-// by polling the upstream on a configurable interval.
+// by polling the upstream on a configurable interval and on calls to Refresh.
 type Source struct {
 	logger  *slog.Logger
 	repo    *git.Repository
-	storage *memory.Storage
+	storage storage.Storer
+
+	mu   sync.Mutex
+	subs map[string]map[chan<- struct{}]struct{}
+
+	// fetchMu serializes fetches made by polling, refreshes and updates
+	fetchMu sync.Mutex
+
+	url       string
+	scm       SCM
+	interval  time.Duration
+	auth      transport.AuthMethod
+	cacheDir  string
+	depth     int
+	branches  []string
+	signer    Signer
+	templates core.Templates
+
+	// tracked are the open proposals reconciled against their base after each fetch
+	trackMu     sync.Mutex
+	tracked     map[ulid.ULID]*trackedProposal
+	reconcileMu sync.Mutex
 
-	url      string
-	scm      SCM
-	interval time.Duration
-	auth     transport.AuthMethod
+	// local is true for sources which commit to a repository on disk instead of a remote
+	local bool
+	// seen are the hashes subscribed revisions of a local source last resolved to
+	seen map[string]plumbing.Hash
+	// amendMu guards amends, which serializes changes made to the branch of each proposal
+	amendMu sync.Mutex
+	amends  map[ulid.ULID]*proposalLock
+
+	// branchMu serializes moving the branches of a local source
+	branchMu sync.Mutex
 
 	// notify is used for informing listeners
 	// during tests that a fetch was performed
@@ -63,6 +215,7 @@ type Source struct {
 
 // WithPollInterval configures the interval in which origin is polled to
 // discover any updates to the target reference.
+// Polling is disabled when the interval is zero or less.
 func WithPollInterval(tick time.Duration) containers.Option[Source] {
 	return func(s *Source) {
 		s.interval = tick
@@ -77,29 +230,98 @@ func WithAuth(auth transport.AuthMethod) containers.Option[Source] {
 	}
 }
 
+// WithCacheDir configures the source to store the repository on disk in the provided directory.
+// A repository previously cloned into the directory is reused and incrementally fetched on start,
+// instead of cloning the entire repository into memory.
+func WithCacheDir(dir string) containers.Option[Source] {
+	return func(s *Source) {
+		s.cacheDir = dir
+	}
+}
+
+// WithDepth configures the source to make shallow clones and fetches
+// limited to the provided number of commits.
+// Revisions and proposal history beyond the depth cannot be resolved.
+func WithDepth(depth int) containers.Option[Source] {
+	return func(s *Source) {
+		s.depth = depth
+	}
+}
+
+// WithBranches configures the source to only track the provided branches
+// (alongside the proposal branches) instead of every branch on origin.
+// Other branches cannot be resolved as revisions.
+func WithBranches(branches ...string) containers.Option[Source] {
+	return func(s *Source) {
+		s.branches = append(s.branches, branches...)
+	}
+}
+
+// WithSigner configures the source to sign the commits it creates.
+func WithSigner(signer Signer) containers.Option[Source] {
+	return func(s *Source) {
+		s.signer = signer
+	}
+}
+
+// WithTemplates configures the default templates which render the branch,
+// commit message, title and body of changes made to the source.
+// They are overridden by any templates provided to Update.
+func WithTemplates(templates core.Templates) containers.Option[Source] {
+	return func(s *Source) {
+		s.templates = templates
+	}
+}
+
 // NewSource constructs and configures a Git backend Source.
 // The implementation uses the connection and credential details provided to support
 // view and update requests for use in the api server.
+// The SCM may be nil for remotes without an SCM API, in which case only
+// direct and dry-run updates are supported.
 func NewSource(ctx context.Context, scm SCM, url string, opts ...containers.Option[Source]) (_ *Source, err error) {
+	fs, err := newSource(scm, url, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := fs.open(ctx); err != nil {
+		return nil, err
+	}
+
+	if scm != nil {
+		fs.trackOpen(ctx)
+	}
+
+	if fs.interval > 0 {
+		go fs.pollRefs(ctx)
+	}
+
+	return fs, nil
+}
+
+// newSource constructs a Source for the repository at url configured with the provided options.
+func newSource(scm SCM, url string, opts ...containers.Option[Source]) (*Source, error) {
 	fs := &Source{
-		logger:   slog.With(slog.String("repository", url)),
-		url:      url,
+		logger:   slog.With(slog.String("repository", stripUserInfo(url))),
+		url:      stripUserInfo(url),
 		scm:      scm,
 		interval: 10 * time.Second,
+		subs:     map[string]map[chan<- struct{}]struct{}{},
+		tracked:  map[ulid.ULID]*trackedProposal{},
+		amends:   map[ulid.ULID]*proposalLock{},
 		notify:   make(chan struct{}, 1),
 	}
 	containers.ApplyAll(fs, opts...)
 
-	fs.storage = memory.NewStorage()
-	fs.repo, err = git.Clone(fs.storage, nil, &git.CloneOptions{
-		Auth: fs.auth,
-		URL:  fs.url,
-	})
-	if err != nil {
-		return nil, err
+	// credentials embedded in the URL are only ever supplied through the auth method
+	// so that they are not written to the configuration of the (cached) repository
+	if fs.auth == nil {
+		fs.auth = urlAuth(url)
 	}
 
-	go fs.pollRefs(ctx)
+	if err := api.ParseTemplates(fs.templates); err != nil {
+		return nil, err
+	}
 
 	return fs, nil
 }
@@ -113,6 +335,10 @@ func (s *Source) View(ctx context.Context, rev string, fn api.ViewFunc) error {
 	}
 
 	fs, err := gitfs.NewFromRepoHash(s.repo, hash)
+	if errors.Is(err, plumbing.ErrObjectNotFound) {
+		return fmt.Errorf("resolving revision %q: %w: %w", rev, api.ErrRevisionNotFound, err)
+	}
+
 	if err != nil {
 		return err
 	}
@@ -125,19 +351,257 @@ func (s *Source) View(ctx context.Context, rev string, fn api.ViewFunc) error {
 // Any changes made during the function call to the underlying worktree are added commit and pushed to the
 // target Git repository.
 // Once pushed a proposal is made on the configured SCM.
-func (s *Source) Update(ctx context.Context, rev, message string, fn api.UpdateFunc) (*api.Result, error) {
+// When configured to auto-merge, the proposal is merged natively by the SCM once its checks pass
+// or, failing that, its checks are polled and it is merged by the source once they pass.
+// When configured as a dry-run, the changes are committed locally and the resulting diff
+// is returned without being pushed or proposed.
+// When configured as direct, the changes are pushed straight to the revision branch instead.
+// If the branch has moved in the meantime, the source is fetched and the update is re-applied
+// over the new head of the branch.
+// When configured with an existing proposal, the worktree is checked out from the proposal branch
+// and the changes are pushed to it as a new commit, leaving the existing proposal in place.
+// The commit message, along with the branch, title and body of a new proposal, are rendered
+// from the templates in the options, falling back to those of the source and then the message.
+func (s *Source) Update(ctx context.Context, rev, message string, fn api.UpdateFunc, opts ...containers.Option[api.UpdateOptions]) (*api.Result, error) {
+	var options api.UpdateOptions
+	containers.ApplyAll(&options, opts...)
+
+	if !options.DryRun && !options.Direct && s.scm == nil {
+		return nil, fmt.Errorf("proposing change: no SCM configured: %w", errors.ErrUnsupported)
+	}
+
+	if options.AutoMerge && !options.DryRun && !options.Direct && s.scm != nil {
+		if err := s.canAutoMerge(); err != nil {
+			return nil, err
+		}
+	}
+
+	var (
+		amending = options.Proposal != (ulid.ULID{})
+		existing *api.Proposal
+		base     = rev
+	)
+
+	if amending {
+		if options.Direct {
+			return nil, fmt.Errorf("amending proposal: direct updates cannot amend a proposal: %w", errors.ErrUnsupported)
+		}
+
+		if s.scm == nil {
+			return nil, fmt.Errorf("amending proposal: no SCM configured: %w", errors.ErrUnsupported)
+		}
+
+		// amendments of the same proposal recreate and push the same branch
+		defer s.lockProposal(options.Proposal)()
+
+		var err error
+		existing, err = s.amendable(ctx, options.Proposal, rev)
+		if err != nil {
+			return nil, fmt.Errorf("amending proposal: %w", err)
+		}
+
+		base = existing.Head
+		if base == "" {
+			base = s.proposalBranch(options.Proposal)
+		}
+	}
+
+	templates := options.Templates.Or(s.templates)
+
+	for attempt := 1; ; attempt++ {
+		change, err := s.commit(ctx, base, message, fn, options, templates, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		if change == nil {
+			return &api.Result{Empty: true}, nil
+		}
+
+		result := &api.Result{ID: change.id}
+
+		if options.DryRun {
+			result.DryRun = true
+			result.Diff, err = s.dryRun(change)
+			if err != nil {
+				return nil, fmt.Errorf("dry run: %w", err)
+			}
+
+			return result, nil
+		}
+
+		if options.Direct || amending {
+			target := rev
+			if amending {
+				target = change.branch
+			}
+
+			err := s.push(ctx, change, target, false)
+			if isNonFastForward(err) && attempt < directAttempts {
+				s.logger.Debug("Branch moved during update, retrying", "branch", target, "attempt", attempt)
+
+				if err := s.fetch(ctx); err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+					return nil, fmt.Errorf("fetching changes: %w", err)
+				}
+
+				continue
+			}
+
+			if isNonFastForward(err) {
+				return nil, fmt.Errorf("pushing changes: %w: %w", api.ErrRevisionMoved, err)
+			}
+
+			if err != nil {
+				return nil, fmt.Errorf("pushing changes: %w", err)
+			}
+
+			result.Commit = change.commit.String()
+			result.Applied = true
+			result.Proposal = existing
+
+			if amending {
+				checked, _ := s.resolve(rev)
+				s.track(change.id, rev, checked, change.commit, operation{message: message, fn: fn, options: options})
+
+				if options.AutoMerge {
+					s.enableAutoMerge(ctx, change.id)
+				}
+			}
+
+			return result, nil
+		}
+
+		if err := s.push(ctx, change, change.branch, false); err != nil {
+			return nil, fmt.Errorf("pushing changes: %w", err)
+		}
+
+		result.Proposal, err = s.scm.Propose(ctx, Proposal{
+			ID:    change.id,
+			Head:  change.branch,
+			Base:  rev,
+			Title: change.title,
+			Body:  change.body,
+
+			ProposalDetails: options.ProposalDetails,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("proposing change: %w", err)
+		}
+
+		s.track(change.id, rev, change.base, change.commit, operation{message: message, fn: fn, options: options})
+
+		if options.AutoMerge {
+			s.enableAutoMerge(ctx, change.id)
+		}
+
+		return result, nil
+	}
+}
+
+// amendable returns the proposal identified by id once it has been established
+// that it is open and targets the provided base revision.
+// The source is fetched so that the proposal branch reflects its latest state on origin.
+func (s *Source) amendable(ctx context.Context, id ulid.ULID, base string) (*api.Proposal, error) {
+	proposal, err := s.scm.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if proposal.Status != "" && proposal.Status != api.ProposalStatusOpen {
+		return nil, fmt.Errorf("%w: %s is %s", api.ErrProposalNotOpen, id, proposal.Status)
+	}
+
+	if proposal.Base != "" && proposal.Base != base {
+		return nil, fmt.Errorf("%w: %s targets %q not %q", api.ErrProposalBaseMismatch, id, proposal.Base, base)
+	}
+
+	var specs []config.RefSpec
+	if proposal.Head != "" && !s.matches(proposal.Head) {
+		specs = append(s.refSpecs(), branchSpec(proposal.Head))
+	}
+
+	if err := s.fetch(ctx, specs...); err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return nil, fmt.Errorf("fetching changes: %w", err)
+	}
+
+	return proposal, nil
+}
+
+// proposalLock serializes changes made to the branch of a single proposal.
+// It is released from the source once no changes are waiting on it.
+type proposalLock struct {
+	sync.Mutex
+	waiting int
+}
+
+// lockProposal waits for any other change to the branch of the proposal identified by id
+// and returns a function which releases it.
+func (s *Source) lockProposal(id ulid.ULID) (unlock func()) {
+	s.amendMu.Lock()
+	lock, ok := s.amends[id]
+	if !ok {
+		lock = &proposalLock{}
+		s.amends[id] = lock
+	}
+
+	lock.waiting++
+	s.amendMu.Unlock()
+
+	lock.Lock()
+
+	return func() {
+		lock.Unlock()
+
+		s.amendMu.Lock()
+		defer s.amendMu.Unlock()
+
+		if lock.waiting--; lock.waiting == 0 {
+			delete(s.amends, id)
+		}
+	}
+}
+
+// directAttempts is the number of times a direct or amending update is attempted
+// when the target branch moves before the change can be pushed.
+const directAttempts = 3
+
+// change is a commit made locally by Update which has not yet been pushed.
+// The title and body describe the proposal made for the change (if any).
+type change struct {
+	id     ulid.ULID
+	repo   *git.Repository
+	branch string
+	base   plumbing.Hash
+	commit plumbing.Hash
+	title  string
+	body   string
+}
+
+// commit checks out rev into a temporary worktree on a new proposal branch,
+// invokes fn and commits the resulting changes.
+// The commit message, along with the branch, title and body of a new proposal,
+// are rendered from the provided templates once the changes are known.
+// Any co-authors are recorded on the commit alongside the caller (if any).
+// It returns nil when fn produces no changes.
+func (s *Source) commit(ctx context.Context, rev, message string, fn api.UpdateFunc, options api.UpdateOptions, templates core.Templates, coAuthors []string) (*change, error) {
 	hash, err := s.resolve(rev)
 	if err != nil {
 		return nil, err
 	}
 
-	// shallow copy the store without the existing index
-	store := &memory.Storage{
-		ReferenceStorage: s.storage.ReferenceStorage,
-		ConfigStorage:    s.storage.ConfigStorage,
-		ShallowStorage:   s.storage.ShallowStorage,
-		ObjectStorage:    s.storage.ObjectStorage,
-		ModuleStorage:    s.storage.ModuleStorage,
+	// preconditions are checked against the same commit the change is made on top of
+	// so a change which is retried after the target moves is checked again
+	if err := api.CheckPreconditions(ctx, s, hash.String(), options.Preconditions); err != nil {
+		return nil, err
+	}
+
+	// share the store without the existing index
+	var store storage.Storer = &worktreeStorage{Storer: s.storage}
+	if s.local {
+		// the branches on disk only move once the change is complete
+		if store, err = newOverlayStorage(s.storage); err != nil {
+			return nil, err
+		}
 	}
 
 	dir, err := os.MkdirTemp("", "cup-proposal-*")
@@ -160,21 +624,31 @@ func (s *Source) Update(ctx context.Context, rev, message string, fn api.UpdateF
 		return nil, fmt.Errorf("open worktree: %w", err)
 	}
 
-	result := &api.Result{
-		ID: ulid.Make(),
+	change := &change{
+		id:   ulid.Make(),
+		repo: repo,
+		base: hash,
 	}
 
 	// create proposal branch (cup/proposal/$id)
-	branch := fmt.Sprintf("cup/proposal/%s", result.ID)
+	// a new proposal branch is renamed once its template has been rendered
+	change.branch = ProposalBranch(change.id)
+	if options.Proposal != (ulid.ULID{}) {
+		change.id = options.Proposal
+		change.branch = s.proposalBranch(change.id)
+	}
+
+	// an amended proposal branch is recreated from the resolved head of the proposal
+	s.removeBranch(change)
 	if err := repo.CreateBranch(&config.Branch{
-		Name:   branch,
+		Name:   change.branch,
 		Remote: "origin",
 	}); err != nil {
 		return nil, fmt.Errorf("create branch: %w", err)
 	}
 
 	if err := work.Checkout(&git.CheckoutOptions{
-		Branch: plumbing.NewBranchReferenceName(branch),
+		Branch: plumbing.NewBranchReferenceName(change.branch),
 		Create: true,
 		Hash:   hash,
 	}); err != nil {
@@ -197,7 +671,15 @@ func (s *Source) Update(ctx context.Context, rev, message string, fn api.UpdateF
 	}
 
 	if len(status) == 0 {
-		return &api.Result{Empty: true}, nil
+		return nil, nil
+	}
+
+	data := api.NewTemplateData(ctx, change.id, rev, message, options.Changes)
+	data.Diff = diffSummary(status)
+
+	message, err = api.RenderTemplate("message", templates.Message, data, message)
+	if err != nil {
+		return nil, err
 	}
 
 	if err := work.AddWithOptions(&git.AddOptions{All: true}); err != nil {
@@ -206,72 +688,401 @@ func (s *Source) Update(ctx context.Context, rev, message string, fn api.UpdateF
 
 	var (
 		now       = time.Now().UTC()
-		signature = &object.Signature{
+		committer = &object.Signature{
 			Email: "cup@flipt.io",
 			Name:  "cup",
 			When:  now,
 		}
+		author    = committer
+		caller, _ = api.CallerFromContext(ctx)
 	)
-	_, err = work.Commit(message, &git.CommitOptions{
-		Author:    signature,
-		Committer: signature,
+
+	// changes made on behalf of a caller are authored by them and committed by cup
+	if caller != nil {
+		author = &object.Signature{
+			Email: caller.Email,
+			Name:  caller.Name,
+			When:  now,
+		}
+
+		if !slices.Contains(coAuthors, caller.String()) {
+			coAuthors = append(slices.Clone(coAuthors), caller.String())
+		}
+	}
+
+	change.commit, err = work.Commit(commitMessage(message, change.id, coAuthors, options), &git.CommitOptions{
+		Author:    author,
+		Committer: committer,
 	})
 	if err != nil {
 		// NOTE: currently with go-git we can see https://github.com/go-git/go-git/issues/723
 		// This occurs when the result of the removal leads to an empty repository.
 		// Just and FYI why a delete might fail silently when the result is the target repo is empty.
 		if errors.Is(err, git.ErrEmptyCommit) {
-			return &api.Result{Empty: true}, nil
+			return nil, nil
 		}
 
 		return nil, fmt.Errorf("committing changes: %w", err)
 	}
 
-	s.logger.Debug("Pushing Changes", slog.String("branch", branch))
+	if s.signer != nil {
+		change.commit, err = s.sign(change)
+		if err != nil {
+			return nil, fmt.Errorf("signing commit: %w", err)
+		}
+	}
 
-	b, err := repo.Branch(branch)
-	if err != nil {
+	// only new proposals are named and described
+	if options.DryRun || options.Direct || options.Proposal != (ulid.ULID{}) {
+		return change, nil
+	}
+
+	if err := s.describe(ctx, change, templates, data, message); err != nil {
 		return nil, err
 	}
-	s.logger.Debug("Branch", slog.String("name", b.Name), slog.String("remote", b.Remote))
 
-	// push to proposed branch
-	if err := repo.PushContext(ctx, &git.PushOptions{
+	return change, nil
+}
+
+// describe renders the branch, title and body of the proposal for a new change.
+// The title defaults to the commit message and the body to the commit message
+// along with the caller on whose behalf it was made.
+// The proposal branch is renamed when a branch template is configured.
+func (s *Source) describe(ctx context.Context, change *change, templates core.Templates, data *api.TemplateData, message string) (err error) {
+	change.title, err = api.RenderTemplate("title", templates.Title, data, message)
+	if err != nil {
+		return err
+	}
+
+	change.body, err = api.RenderTemplate("body", templates.Body, data, proposalBody(ctx, message))
+	if err != nil {
+		return err
+	}
+
+	branch, err := api.RenderTemplate("branch", templates.Branch, data, change.branch)
+	if err != nil {
+		return err
+	}
+
+	branch = strings.TrimSpace(branch)
+	if branch == change.branch {
+		return nil
+	}
+
+	if err := validateBranch(branch); err != nil {
+		return fmt.Errorf("branch template: %w", err)
+	}
+
+	if id, ok := ProposalID(branch); !ok || id != change.id {
+		return fmt.Errorf("branch template: %q must contain the proposal ID %s", branch, change.id)
+	}
+
+	renamed := *change
+	renamed.branch = branch
+
+	if err := change.repo.CreateBranch(&config.Branch{
+		Name:   branch,
+		Remote: "origin",
+	}); err != nil {
+		return fmt.Errorf("create branch: %w", err)
+	}
+
+	ref := plumbing.NewHashReference(plumbing.NewBranchReferenceName(branch), change.commit)
+	if err := change.repo.Storer.SetReference(ref); err != nil {
+		return fmt.Errorf("rename branch: %w", err)
+	}
+
+	s.removeBranch(change)
+
+	*change = renamed
+
+	return nil
+}
+
+// diffSummary summarizes the files added, modified and deleted in the worktree status.
+func diffSummary(status git.Status) (diff api.DiffSummary) {
+	paths := make([]string, 0, len(status))
+	for path := range status {
+		paths = append(paths, path)
+	}
+
+	slices.Sort(paths)
+
+	for _, path := range paths {
+		code := status[path].Worktree
+		if code == git.Unmodified {
+			code = status[path].Staging
+		}
+
+		switch code {
+		case git.Untracked, git.Added:
+			diff.Added = append(diff.Added, path)
+		case git.Deleted:
+			diff.Deleted = append(diff.Deleted, path)
+		default:
+			diff.Modified = append(diff.Modified, path)
+		}
+	}
+
+	return diff
+}
+
+// proposalBranch returns the name of the branch of the existing proposal identified by id.
+// The name depends on the template the proposal was made with, so it is found amongst
+// the branches fetched from origin and pushed from this source.
+// It falls back to the default name when no such branch exists.
+func (s *Source) proposalBranch(id ulid.ULID) (branch string) {
+	branch = ProposalBranch(id)
+
+	refs, err := s.repo.References()
+	if err != nil {
+		return branch
+	}
+
+	_ = refs.ForEach(func(ref *plumbing.Reference) error {
+		name, ok := strings.CutPrefix(ref.Name().String(), "refs/remotes/origin/")
+		if !ok {
+			name, ok = strings.CutPrefix(ref.Name().String(), "refs/heads/")
+		}
+
+		if !ok {
+			return nil
+		}
+
+		if found, ok := ProposalID(name); ok && found == id {
+			branch = name
+			return storer.ErrStop
+		}
+
+		return nil
+	})
+
+	return branch
+}
+
+// sign replaces the commit of the change with a signed copy
+// and moves the proposal branch onto it.
+func (s *Source) sign(change *change) (plumbing.Hash, error) {
+	commit, err := change.repo.CommitObject(change.commit)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	unsigned := &plumbing.MemoryObject{}
+	if err := commit.EncodeWithoutSignature(unsigned); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	message, err := unsigned.Reader()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	commit.PGPSignature, err = s.signer.Sign(message)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	obj := change.repo.Storer.NewEncodedObject()
+	if err := commit.Encode(obj); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	hash, err := change.repo.Storer.SetEncodedObject(obj)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	ref := plumbing.NewHashReference(plumbing.NewBranchReferenceName(change.branch), hash)
+	if err := change.repo.Storer.SetReference(ref); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	return hash, nil
+}
+
+// proposalBody returns the description of a proposal for message
+// which mentions the caller on whose behalf it was made (if any).
+func proposalBody(ctx context.Context, message string) string {
+	caller, ok := api.CallerFromContext(ctx)
+	if !ok {
+		return message
+	}
+
+	return fmt.Sprintf("%s\n\nProposed by %s.", message, caller)
+}
+
+// commitMessage appends trailers to message which identify the proposal (when not direct),
+// the affected resources, whether the proposal is merged once its checks pass
+// and any co-authors (such as the caller).
+func commitMessage(message string, id ulid.ULID, coAuthors []string, options api.UpdateOptions) string {
+	var trailers []string
+	if !options.Direct {
+		trailers = append(trailers, fmt.Sprintf("%s: %s", trailerProposal, id))
+	}
+
+	for _, resource := range options.Resources {
+		trailers = append(trailers, fmt.Sprintf("%s: %s", trailerResource, resource))
+	}
+
+	if options.AutoMerge && !options.Direct {
+		trailers = append(trailers, fmt.Sprintf("%s: %s", trailerAutoMerge, "true"))
+	}
+
+	for _, coAuthor := range coAuthors {
+		trailers = append(trailers, fmt.Sprintf("%s: %s", trailerCoAuthor, coAuthor))
+	}
+
+	if len(trailers) == 0 {
+		return message
+	}
+
+	return message + "\n\n" + strings.Join(trailers, "\n")
+}
+
+// push pushes the commit for the provided change to the target branch on origin.
+// Unless forced, the push fails when it is not a fast-forward of the target.
+// The local proposal branch is removed from the shared repository once pushed (or rejected)
+// as the branch is tracked through origin from then on.
+func (s *Source) push(ctx context.Context, change *change, target string, force bool) error {
+	defer s.removeBranch(change)
+
+	if s.local {
+		return s.setBranch(ctx, change, target, force)
+	}
+
+	s.logger.Debug("Pushing Changes", slog.String("branch", change.branch), slog.String("target", target), slog.Bool("force", force))
+
+	spec := fmt.Sprintf("refs/heads/%s:refs/heads/%s", change.branch, target)
+	if force {
+		spec = "+" + spec
+	}
+
+	if err := change.repo.PushContext(ctx, &git.PushOptions{
 		Auth:       s.auth,
 		RemoteName: "origin",
-		RefSpecs: []config.RefSpec{
-			config.RefSpec(fmt.Sprintf("%s:refs/heads/%s", branch, branch)),
-			config.RefSpec(fmt.Sprintf("refs/heads/%s:refs/heads/%s", branch, branch)),
-		},
+		RefSpecs:   []config.RefSpec{config.RefSpec(spec)},
 	}); err != nil {
-		return nil, fmt.Errorf("pushing changes: %w", err)
+		return err
 	}
 
-	result.Proposal, err = s.scm.Propose(ctx, Proposal{
-		Head:  branch,
-		Base:  rev,
-		Title: message,
-		Body:  message,
-	})
+	// templated proposal branches are not always matched by the refspecs of origin
+	// so they are fetched explicitly in order to be found once pushed
+	if !s.matches(target) {
+		if err := s.fetch(ctx, branchSpec(target)); err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+			return fmt.Errorf("fetching pushed branch: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// isNonFastForward returns true when the push was rejected
+// because the target branch has moved on the remote.
+func isNonFastForward(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	return errors.Is(err, git.ErrForceNeeded) ||
+		errors.Is(err, git.ErrNonFastForwardUpdate) ||
+		strings.Contains(err.Error(), "non-fast-forward")
+}
+
+// dryRun returns the unified diff between the base and the locally committed change.
+// The proposal branch is removed as it is never pushed.
+func (s *Source) dryRun(change *change) (string, error) {
+	defer s.removeBranch(change)
+
+	repo := change.repo
+
+	from, err := repo.CommitObject(change.base)
 	if err != nil {
-		return nil, fmt.Errorf("proposing change: %w", err)
+		return "", fmt.Errorf("retrieving base commit: %w", err)
+	}
 
+	to, err := repo.CommitObject(change.commit)
+	if err != nil {
+		return "", fmt.Errorf("retrieving change commit: %w", err)
 	}
 
-	return result, nil
+	patch, err := from.Patch(to)
+	if err != nil {
+		return "", fmt.Errorf("computing diff: %w", err)
+	}
+
+	return patch.String(), nil
 }
 
+// removeBranch removes the local proposal branch of a change once it has been pushed (or when it never will be).
+func (s *Source) removeBranch(change *change) {
+	if err := change.repo.DeleteBranch(change.branch); err != nil {
+		s.logger.Debug("Removing branch config", "branch", change.branch, "error", err)
+	}
+
+	if err := change.repo.Storer.RemoveReference(plumbing.NewBranchReferenceName(change.branch)); err != nil {
+		s.logger.Debug("Removing branch", "branch", change.branch, "error", err)
+	}
+}
+
+// resolve returns the commit hash for the provided revision.
+// Branch names are resolved against the tracked origin references first.
+// Otherwise, the revision is resolved as a tag, (abbreviated) commit hash
+// or any other revision expression supported by go-git.
 func (s *Source) resolve(r string) (plumbing.Hash, error) {
 	if plumbing.IsHash(r) {
 		return plumbing.NewHash(r), nil
 	}
 
-	ref, err := s.repo.Reference(plumbing.NewRemoteReferenceName("origin", r), true)
+	// local sources resolve the branches of the repository itself
+	if !s.local {
+		ref, err := s.repo.Reference(plumbing.NewRemoteReferenceName("origin", r), true)
+		if err == nil {
+			return ref.Hash(), nil
+		}
+
+		if !errors.Is(err, plumbing.ErrReferenceNotFound) {
+			return plumbing.ZeroHash, err
+		}
+	}
+
+	hash, err := s.repo.ResolveRevision(plumbing.Revision(r))
 	if err != nil {
-		return plumbing.ZeroHash, err
+		return plumbing.ZeroHash, fmt.Errorf("resolving revision %q: %w: %w", r, api.ErrRevisionNotFound, err)
+	}
+
+	return *hash, nil
+}
+
+// Subscribe registers ch to be signalled each time a fetch moves the provided revision.
+// The subscription is removed once the provided context is done.
+func (s *Source) Subscribe(ctx context.Context, rev string, ch chan<- struct{}) {
+	if s.local {
+		s.see(rev)
 	}
 
-	return ref.Hash(), nil
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	subs, ok := s.subs[rev]
+	if !ok {
+		subs = map[chan<- struct{}]struct{}{}
+		s.subs[rev] = subs
+	}
+
+	subs[ch] = struct{}{}
+
+	go func() {
+		<-ctx.Done()
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		delete(s.subs[rev], ch)
+		if len(s.subs[rev]) == 0 {
+			delete(s.subs, rev)
+		}
+	}()
 }
 
 func (s *Source) pollRefs(ctx context.Context) {
@@ -281,9 +1092,13 @@ func (s *Source) pollRefs(ctx context.Context) {
 		case <-ctx.Done():
 			return
 		case <-ticker.C:
-			if err := s.repo.FetchContext(ctx, &git.FetchOptions{
-				Auth: s.auth,
-			}); err != nil {
+			err := s.fetch(ctx)
+
+			// the base of a proposal may have moved during this or a previous fetch
+			s.reconcile(ctx)
+			s.autoMerge(ctx)
+
+			if err != nil {
 				if errors.Is(err, git.NoErrAlreadyUpToDate) {
 					slog.Debug("References are all up to date")
 
@@ -303,3 +1118,176 @@ func (s *Source) pollRefs(ctx context.Context) {
 		}
 	}
 }
+
+// Refresh fetches the provided branches from origin and signals any subscribers
+// for revisions which have moved as a result.
+// All tracked branches and tags are fetched when no branches are provided.
+// Branches which are not tracked by the source are ignored
+// and branches which have been deleted from origin are pruned.
+func (s *Source) Refresh(ctx context.Context, branches ...string) error {
+	specs := make([]config.RefSpec, 0, len(branches))
+	for _, branch := range branches {
+		if !s.tracks(branch) {
+			continue
+		}
+
+		specs = append(specs, branchSpec(branch))
+	}
+
+	// none of the requested branches are tracked by this source
+	if len(branches) > 0 && len(specs) == 0 {
+		return nil
+	}
+
+	err := s.fetch(ctx, specs...)
+	if errors.Is(err, git.NoMatchingRefSpecError{}) {
+		// one or more of the branches have been deleted from origin (e.g. a push deleting the branch)
+		// so their remote references are pruned and only the remaining branches are fetched
+		if specs, err = s.prune(ctx, branches); err == nil && len(specs) > 0 {
+			err = s.fetch(ctx, specs...)
+		}
+	}
+
+	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return fmt.Errorf("refreshing: %w", err)
+	}
+
+	s.reconcile(ctx)
+	s.autoMerge(ctx)
+
+	return nil
+}
+
+// prune removes the remote references for the provided branches which no longer exist on origin.
+// It returns the refspecs for the tracked branches which do still exist.
+func (s *Source) prune(ctx context.Context, branches []string) ([]config.RefSpec, error) {
+	remote, err := s.repo.Remote("origin")
+	if err != nil {
+		return nil, err
+	}
+
+	refs, err := remote.ListContext(ctx, &git.ListOptions{Auth: s.auth})
+	if err != nil {
+		return nil, err
+	}
+
+	exists := map[string]struct{}{}
+	for _, ref := range refs {
+		if ref.Name().IsBranch() {
+			exists[ref.Name().Short()] = struct{}{}
+		}
+	}
+
+	var specs []config.RefSpec
+	for _, branch := range branches {
+		if !s.tracks(branch) {
+			continue
+		}
+
+		if _, ok := exists[branch]; ok {
+			specs = append(specs, branchSpec(branch))
+			continue
+		}
+
+		s.logger.Debug("Pruning deleted branch", "branch", branch)
+
+		if err := s.repo.Storer.RemoveReference(plumbing.NewRemoteReferenceName("origin", branch)); err != nil {
+			return nil, err
+		}
+	}
+
+	return specs, nil
+}
+
+// fetch updates the references from origin and signals any subscribers
+// for revisions which resolve to a different hash as a result.
+// The default refspecs of origin are used when none are provided.
+// Local sources have no origin and instead signal subscribers for revisions
+// which have moved since they were last resolved.
+func (s *Source) fetch(ctx context.Context, specs ...config.RefSpec) error {
+	s.fetchMu.Lock()
+	defer s.fetchMu.Unlock()
+
+	before := s.resolveSubscribed()
+
+	if s.local {
+		// there is nothing to fetch, instead revisions are compared with when they were last resolved
+		// revisions which have not been resolved before are only signalled once they move
+		before = s.seen
+	} else if err := s.repo.FetchContext(ctx, &git.FetchOptions{
+		RefSpecs: specs,
+		Auth:     s.auth,
+		Tags:     git.AllTags,
+		Depth:    s.depth,
+	}); err != nil {
+		return err
+	}
+
+	after := s.resolveSubscribed()
+	if s.local {
+		s.seen = after
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for rev, hash := range after {
+		if prev, ok := before[rev]; prev == hash || (s.local && !ok) {
+			continue
+		}
+
+		s.logger.Debug("Revision updated", "revision", rev, "hash", hash)
+
+		for ch := range s.subs[rev] {
+			select {
+			case ch <- struct{}{}:
+			default:
+			}
+		}
+	}
+
+	return nil
+}
+
+// see records the hash rev currently resolves to for a local source (unless already recorded),
+// so that subscribers are signalled the first time it moves.
+func (s *Source) see(rev string) {
+	s.fetchMu.Lock()
+	defer s.fetchMu.Unlock()
+
+	if _, ok := s.seen[rev]; ok {
+		return
+	}
+
+	hash, err := s.resolve(rev)
+	if err != nil {
+		return
+	}
+
+	if s.seen == nil {
+		s.seen = map[string]plumbing.Hash{}
+	}
+
+	s.seen[rev] = hash
+}
+
+func (s *Source) resolveSubscribed() map[string]plumbing.Hash {
+	s.mu.Lock()
+	revs := make([]string, 0, len(s.subs))
+	for rev := range s.subs {
+		revs = append(revs, rev)
+	}
+	s.mu.Unlock()
+
+	hashes := make(map[string]plumbing.Hash, len(revs))
+	for _, rev := range revs {
+		hash, err := s.resolve(rev)
+		if err != nil {
+			continue
+		}
+
+		hashes[rev] = hash
+	}
+
+	return hashes
+}
@@ -1,26 +1,48 @@
 package git_test
 
 import (
+	"bytes"
 	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
 	"log/slog"
 	"net/url"
 	"os"
+	"os/exec"
+	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"code.gitea.io/sdk/gitea"
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
 	"github.com/go-git/go-git/v5/plumbing/transport/http"
 	"github.com/oklog/ulid/v2"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.flipt.io/cup/pkg/api"
+	"go.flipt.io/cup/pkg/api/core"
 	"go.flipt.io/cup/pkg/containers"
 	"go.flipt.io/cup/pkg/controllers"
 	"go.flipt.io/cup/pkg/source/git"
 	giteascm "go.flipt.io/cup/pkg/source/git/scm/gitea"
+	memscm "go.flipt.io/cup/pkg/source/git/scm/mem"
+	"go.flipt.io/cup/pkg/source/git/signing"
+	"golang.org/x/crypto/ssh"
 )
 
 var gitRepoURL = os.Getenv("TEST_GIT_REPO_URL")
@@ -129,12 +151,920 @@ func Test_Source_Update(t *testing.T) {
 	}), files)
 }
 
-type scm interface {
-	git.SCM
-	Merge(context.Context, ulid.ULID) error
+func Test_Source_Update_DryRun(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	fss, remote, skipped := testLocalSource(t, ctx, nil)
+	if skipped {
+		return
+	}
+
+	result, err := fss.Update(ctx, "main", "feat: add `baz` resource", func(f controllers.FSConfig) error {
+		fi, err := f.ToFS().Create(bazPath)
+		if err != nil {
+			return err
+		}
+
+		defer fi.Close()
+
+		_, err = fi.Write(bazContents)
+		return err
+	}, api.WithDryRun(true))
+	require.NoError(t, err)
+
+	assert.False(t, result.Empty)
+	assert.Nil(t, result.Proposal)
+	assert.Contains(t, result.Diff, "+++ b/"+bazPath)
+	assert.Contains(t, result.Diff, `+        "name": "baz",`)
+
+	// nothing should have been pushed to origin
+	origin, err := gogit.PlainOpen(remote.path)
+	require.NoError(t, err)
+
+	branches, err := origin.Branches()
+	require.NoError(t, err)
+
+	var names []string
+	require.NoError(t, branches.ForEach(func(r *plumbing.Reference) error {
+		names = append(names, r.Name().Short())
+		return nil
+	}))
+
+	assert.Equal(t, []string{"main"}, names)
+}
+
+func Test_Source_Update_Direct(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	fss, remote, skipped := testLocalSource(t, ctx, nil)
+	if skipped {
+		return
+	}
+
+	writeBaz := func(f controllers.FSConfig) error {
+		fi, err := f.ToFS().Create(bazPath)
+		if err != nil {
+			return err
+		}
+
+		defer fi.Close()
+
+		_, err = fi.Write(bazContents)
+		return err
+	}
+
+	t.Run("proposals require an SCM", func(t *testing.T) {
+		_, err := fss.Update(ctx, "main", "feat: add `baz` resource", writeBaz)
+		require.ErrorIs(t, err, errors.ErrUnsupported)
+	})
+
+	t.Run("checks preconditions again when the base branch moves", func(t *testing.T) {
+		const path = "default/precondition.json"
+
+		origin, err := gogit.PlainOpen(remote.path)
+		require.NoError(t, err)
+
+		head, err := origin.Reference(plumbing.Main, true)
+		require.NoError(t, err)
+
+		precondition := api.Precondition{
+			Version: head.Hash().String(),
+			Get: func(_ context.Context, f fs.FS) (*core.Resource, error) {
+				data, err := fs.ReadFile(f, path)
+				if err != nil {
+					return nil, err
+				}
+
+				var resource core.Resource
+				return &resource, json.Unmarshal(data, &resource)
+			},
+		}
+
+		var calls int
+		_, err = fss.Update(ctx, "main", "feat: add `baz` resource", func(f controllers.FSConfig) error {
+			calls++
+			// the resource is created on origin after the first attempt has checked it
+			if calls == 1 {
+				remote.commit(t, map[string]string{path: `{"spec":{"value":"concurrent"}}`})
+			}
+
+			return writeBaz(f)
+		}, api.WithDirect(true), api.WithPreconditions(precondition))
+		require.ErrorContains(t, err, "resource has been modified")
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("retries when the base branch moves", func(t *testing.T) {
+		var (
+			calls int
+			moved plumbing.Hash
+		)
+
+		result, err := fss.Update(ctx, "main", "feat: add `baz` resource", func(f controllers.FSConfig) error {
+			calls++
+			// move origin after the first attempt has resolved the base branch
+			if calls == 1 {
+				moved = remote.commit(t, map[string]string{"default/other.json": "{}"})
+			}
+
+			return writeBaz(f)
+		}, api.WithDirect(true))
+		require.NoError(t, err)
+
+		assert.Equal(t, 2, calls)
+		assert.Nil(t, result.Proposal)
+		require.NotEmpty(t, result.Commit)
+
+		origin, err := gogit.PlainOpen(remote.path)
+		require.NoError(t, err)
+
+		head, err := origin.Reference(plumbing.Main, true)
+		require.NoError(t, err)
+		assert.Equal(t, result.Commit, head.Hash().String())
+
+		commit, err := origin.CommitObject(head.Hash())
+		require.NoError(t, err)
+		assert.Equal(t, []plumbing.Hash{moved}, commit.ParentHashes)
+
+		_, err = commit.File(bazPath)
+		require.NoError(t, err)
+	})
+
+	t.Run("conflicts when the base branch keeps moving", func(t *testing.T) {
+		var calls int
+
+		_, err := fss.Update(ctx, "main", "feat: add `moving` resource", func(f controllers.FSConfig) error {
+			calls++
+			remote.commit(t, map[string]string{"default/other.json": fmt.Sprintf(`{"calls":%d}`, calls)})
+
+			fi, err := f.ToFS().Create(fmt.Sprintf("default/moving-%d.json", calls))
+			if err != nil {
+				return err
+			}
+
+			return fi.Close()
+		}, api.WithDirect(true))
+		require.ErrorIs(t, err, api.ErrRevisionMoved)
+
+		assert.Equal(t, 3, calls)
+	})
+
+	t.Run("attributes the commit to the caller", func(t *testing.T) {
+		ctx := api.WithCaller(ctx, &api.Caller{Name: "Jane Doe", Email: "jane@flipt.io"})
+
+		result, err := fss.Update(ctx, "main", "feat: add `qux` resource", func(f controllers.FSConfig) error {
+			fi, err := f.ToFS().Create("default/qux.json")
+			if err != nil {
+				return err
+			}
+
+			defer fi.Close()
+
+			_, err = fi.Write([]byte("{}"))
+			return err
+		}, api.WithDirect(true))
+		require.NoError(t, err)
+
+		origin, err := gogit.PlainOpen(remote.path)
+		require.NoError(t, err)
+
+		commit, err := origin.CommitObject(plumbing.NewHash(result.Commit))
+		require.NoError(t, err)
+
+		assert.Equal(t, "Jane Doe", commit.Author.Name)
+		assert.Equal(t, "jane@flipt.io", commit.Author.Email)
+		assert.Equal(t, "cup", commit.Committer.Name)
+		assert.Equal(t, "cup@flipt.io", commit.Committer.Email)
+		assert.Contains(t, commit.Message, "\n\nCo-authored-by: Jane Doe <jane@flipt.io>")
+	})
+}
+
+func Test_Source_Update_Signed(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	entity, err := openpgp.NewEntity("cup", "", "cup@flipt.io", nil)
+	require.NoError(t, err)
+
+	var private, public bytes.Buffer
+	w, err := armor.Encode(&private, openpgp.PrivateKeyType, nil)
+	require.NoError(t, err)
+	require.NoError(t, entity.SerializePrivate(w, nil))
+	require.NoError(t, w.Close())
+
+	w, err = armor.Encode(&public, openpgp.PublicKeyType, nil)
+	require.NoError(t, err)
+	require.NoError(t, entity.Serialize(w))
+	require.NoError(t, w.Close())
+
+	pgpSigner, err := signing.NewOpenPGP(private.Bytes(), "")
+	require.NoError(t, err)
+
+	_, sshKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	der, err := x509.MarshalPKCS8PrivateKey(sshKey)
+	require.NoError(t, err)
+
+	sshSigner, err := signing.NewSSH(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), "")
+	require.NoError(t, err)
+
+	sshPublic, err := ssh.NewPublicKey(sshKey.Public())
+	require.NoError(t, err)
+
+	for _, test := range []struct {
+		name   string
+		signer git.Signer
+		verify func(t *testing.T, remote *localRemote, commit *object.Commit)
+	}{
+		{
+			name:   "openpgp",
+			signer: pgpSigner,
+			verify: func(t *testing.T, _ *localRemote, commit *object.Commit) {
+				signer, err := commit.Verify(public.String())
+				require.NoError(t, err)
+				assert.Equal(t, entity.PrimaryKey.KeyId, signer.PrimaryKey.KeyId)
+			},
+		},
+		{
+			name:   "ssh",
+			signer: sshSigner,
+			verify: func(t *testing.T, remote *localRemote, commit *object.Commit) {
+				if _, err := exec.LookPath("ssh-keygen"); err != nil {
+					t.Skip("ssh-keygen required to verify ssh signatures")
+				}
+
+				require.True(t, strings.HasPrefix(commit.PGPSignature, "-----BEGIN SSH SIGNATURE-----"))
+
+				allowed := filepath.Join(t.TempDir(), "allowed_signers")
+				require.NoError(t, os.WriteFile(allowed,
+					[]byte("cup@flipt.io "+string(ssh.MarshalAuthorizedKey(sshPublic))), 0600))
+
+				// verify the signature exactly as git would on a protected branch
+				out, err := exec.Command("git", "-C", remote.path,
+					"-c", "gpg.format=ssh",
+					"-c", "gpg.ssh.allowedSignersFile="+allowed,
+					"verify-commit", commit.Hash.String(),
+				).CombinedOutput()
+				require.NoError(t, err, string(out))
+			},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			fss, remote, skipped := testLocalSource(t, ctx, nil, git.WithSigner(test.signer))
+			if skipped {
+				return
+			}
+
+			result, err := fss.Update(ctx, "main", "feat: add `baz` resource", func(f controllers.FSConfig) error {
+				fi, err := f.ToFS().Create(bazPath)
+				if err != nil {
+					return err
+				}
+
+				defer fi.Close()
+
+				_, err = fi.Write(bazContents)
+				return err
+			}, api.WithDirect(true))
+			require.NoError(t, err)
+
+			origin, err := gogit.PlainOpen(remote.path)
+			require.NoError(t, err)
+
+			head, err := origin.Reference(plumbing.Main, true)
+			require.NoError(t, err)
+			assert.Equal(t, result.Commit, head.Hash().String())
+
+			commit, err := origin.CommitObject(head.Hash())
+			require.NoError(t, err)
+			require.NotEmpty(t, commit.PGPSignature)
+
+			test.verify(t, remote, commit)
+		})
+	}
+}
+
+func Test_Source_Proposals(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	scm := memscm.New()
+	fss, _, skipped := testLocalSource(t, ctx, scm)
+	if skipped {
+		return
+	}
+
+	const resource = "test.cup.flipt.io/v1alpha1/Resource/default/baz"
+
+	result, err := fss.Update(ctx, "main", "feat: add `baz` resource", func(f controllers.FSConfig) error {
+		fi, err := f.ToFS().Create(bazPath)
+		if err != nil {
+			return err
+		}
+
+		defer fi.Close()
+
+		_, err = fi.Write(bazContents)
+		return err
+	}, api.WithResources(resource))
+	require.NoError(t, err)
+
+	expected := &api.Proposal{
+		ID:        result.ID,
+		Source:    "mem",
+		Status:    api.ProposalStatusOpen,
+		Head:      git.ProposalBranch(result.ID),
+		Base:      "main",
+		Resources: []string{resource},
+	}
+
+	proposals, err := fss.Proposals(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []*api.Proposal{expected}, proposals)
+
+	proposal, err := fss.Proposal(ctx, result.ID)
+	require.NoError(t, err)
+	assert.Equal(t, expected, proposal)
+
+	require.NoError(t, fss.MergeProposal(ctx, result.ID))
+
+	proposal, err = fss.Proposal(ctx, result.ID)
+	require.NoError(t, err)
+	assert.Equal(t, api.ProposalStatusMerged, proposal.Status)
+
+	proposals, err = fss.Proposals(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, proposals)
+
+	err = fss.CloseProposal(ctx, ulid.Make())
+	require.ErrorIs(t, err, api.ErrProposalNotFound)
+}
+
+func Test_Source_Update_Proposal(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	scm := memscm.New()
+	fss, remote, skipped := testLocalSource(t, ctx, scm)
+	if skipped {
+		return
+	}
+
+	write := func(contents string) api.UpdateFunc {
+		return func(f controllers.FSConfig) error {
+			fi, err := f.ToFS().Create(bazPath)
+			if err != nil {
+				return err
+			}
+
+			defer fi.Close()
+
+			_, err = fi.Write([]byte(contents))
+			return err
+		}
+	}
+
+	const (
+		baz      = "test.cup.flipt.io/v1alpha1/Resource/default/baz"
+		original = `{"spec":{"value":"original"}}`
+		amended  = `{"spec":{"value":"amended"}}`
+	)
+
+	proposed, err := fss.Update(ctx, "main", "feat: add `baz` resource", write(original), api.WithResources(baz))
+	require.NoError(t, err)
+
+	result, err := fss.Update(ctx, "main", "feat: update `baz` resource", write(amended),
+		api.WithResources(baz),
+		api.WithProposal(proposed.ID))
+	require.NoError(t, err)
+
+	assert.Equal(t, proposed.ID, result.ID)
+	require.NotNil(t, result.Proposal)
+	assert.Equal(t, proposed.ID, result.Proposal.ID)
+
+	// no new proposal is opened
+	proposals, err := fss.Proposals(ctx)
+	require.NoError(t, err)
+	require.Len(t, proposals, 1)
+	assert.Equal(t, []string{baz}, proposals[0].Resources)
+
+	origin, err := gogit.PlainOpen(remote.path)
+	require.NoError(t, err)
+
+	head, err := origin.Reference(plumbing.NewBranchReferenceName(git.ProposalBranch(proposed.ID)), true)
+	require.NoError(t, err)
+	assert.Equal(t, result.Commit, head.Hash().String())
+
+	commit, err := origin.CommitObject(head.Hash())
+	require.NoError(t, err)
+	require.Len(t, commit.ParentHashes, 1)
+
+	// the amendment is made on top of the original proposal commit
+	parent, err := origin.CommitObject(commit.ParentHashes[0])
+	require.NoError(t, err)
+	assert.Contains(t, parent.Message, "feat: add `baz` resource")
+
+	file, err := commit.File(bazPath)
+	require.NoError(t, err)
+	contents, err := file.Contents()
+	require.NoError(t, err)
+	assert.Equal(t, amended, contents)
+
+	t.Run("unknown proposal", func(t *testing.T) {
+		_, err := fss.Update(ctx, "main", "feat: update `baz` resource", write(amended), api.WithProposal(ulid.Make()))
+		require.ErrorIs(t, err, api.ErrProposalNotFound)
+	})
+
+	t.Run("different base", func(t *testing.T) {
+		_, err := fss.Update(ctx, "other", "feat: update `baz` resource", write(amended), api.WithProposal(proposed.ID))
+		require.ErrorIs(t, err, api.ErrProposalBaseMismatch)
+	})
+
+	t.Run("concurrent amendments", func(t *testing.T) {
+		var (
+			wg     sync.WaitGroup
+			errs   = make([]error, 3)
+			before = head.Hash()
+		)
+
+		for i := range errs {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+
+				path := fmt.Sprintf("default/test.cup.flipt.io-v1alpha1-Resource-amend-%d.json", i)
+				_, errs[i] = fss.Update(ctx, "main", "feat: add resource", func(f controllers.FSConfig) error {
+					fi, err := f.ToFS().Create(path)
+					if err != nil {
+						return err
+					}
+
+					defer fi.Close()
+
+					_, err = fi.Write([]byte("{}"))
+					return err
+				}, api.WithProposal(proposed.ID))
+			}(i)
+		}
+
+		wg.Wait()
+
+		for _, err := range errs {
+			require.NoError(t, err)
+		}
+
+		// each amendment is made on top of the last
+		head, err := origin.Reference(plumbing.NewBranchReferenceName(git.ProposalBranch(proposed.ID)), true)
+		require.NoError(t, err)
+
+		commit, err := origin.CommitObject(head.Hash())
+		require.NoError(t, err)
+
+		for i := range errs {
+			_, err := commit.File(fmt.Sprintf("default/test.cup.flipt.io-v1alpha1-Resource-amend-%d.json", i))
+			require.NoError(t, err)
+		}
+
+		for i := 0; i < len(errs); i++ {
+			commit, err = origin.CommitObject(commit.ParentHashes[0])
+			require.NoError(t, err)
+		}
+
+		assert.Equal(t, before, commit.Hash)
+	})
+
+	t.Run("closed proposal", func(t *testing.T) {
+		require.NoError(t, fss.CloseProposal(ctx, proposed.ID))
+
+		_, err := fss.Update(ctx, "main", "feat: update `baz` resource", write(original), api.WithProposal(proposed.ID))
+		require.ErrorIs(t, err, api.ErrProposalNotOpen)
+	})
+}
+
+func Test_Source_Update_Templates(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	scm := memscm.New()
+	fss, remote, skipped := testLocalSource(t, ctx, scm, git.WithTemplates(core.Templates{
+		Title: "{{ .Message }} ({{ .Diff }})",
+		Body:  "Changed {{ join .Diff.Files \", \" }} for {{ .Caller.Name }}.",
+	}))
+	if skipped {
+		return
+	}
+
+	write := func(contents string) api.UpdateFunc {
+		return func(f controllers.FSConfig) error {
+			fi, err := f.ToFS().Create(bazPath)
+			if err != nil {
+				return err
+			}
+
+			defer fi.Close()
+
+			_, err = fi.Write([]byte(contents))
+			return err
+		}
+	}
+
+	var (
+		change = api.Change{
+			Operation: api.OperationTypePut,
+			Group:     "test.cup.flipt.io",
+			Version:   "v1alpha1",
+			Kind:      "Resource",
+			Namespace: "default",
+			Name:      "baz",
+		}
+		templates = core.Templates{
+			Branch:  "cup/proposal/{{ .Resource.Kind | lower }}/{{ .Resource.Name | slug }}-{{ .ID }}",
+			Message: "feat({{ .Resource.Name }}): {{ .Operation }} {{ .Resource.Kind | lower }}\n\nRefs: CUP-123",
+		}
+	)
+
+	ctx = api.WithCaller(ctx, &api.Caller{Name: "Alice", Email: "alice@example.com"})
+
+	result, err := fss.Update(ctx, "main", "feat: add `baz` resource", write(`{"spec":{}}`),
+		api.WithResources(change.String()),
+		api.WithChanges(change),
+		api.WithTemplates(templates))
+	require.NoError(t, err)
+
+	branch := fmt.Sprintf("cup/proposal/resource/baz-%s", result.ID)
+
+	proposed, ok := scm.Proposed(result.ID)
+	require.True(t, ok)
+	assert.Equal(t, branch, proposed.Head)
+	assert.Equal(t, "feat: add `baz` resource (1 added)", proposed.Title)
+	assert.Equal(t, "Changed "+bazPath+" for Alice.", proposed.Body)
+
+	id, ok := git.ProposalID(branch)
+	require.True(t, ok)
+	assert.Equal(t, result.ID, id)
+
+	origin, err := gogit.PlainOpen(remote.path)
+	require.NoError(t, err)
+
+	head, err := origin.Reference(plumbing.NewBranchReferenceName(branch), true)
+	require.NoError(t, err)
+
+	commit, err := origin.CommitObject(head.Hash())
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(commit.Message, "feat(baz): put resource\n\nRefs: CUP-123\n\n"), commit.Message)
+	assert.Contains(t, commit.Message, "Cup-Proposal: "+result.ID.String())
+
+	// the default proposal branch is never pushed
+	_, err = origin.Reference(plumbing.NewBranchReferenceName(git.ProposalBranch(result.ID)), true)
+	require.ErrorIs(t, err, plumbing.ErrReferenceNotFound)
+
+	proposals, err := fss.Proposals(ctx)
+	require.NoError(t, err)
+	require.Len(t, proposals, 1)
+	assert.Equal(t, branch, proposals[0].Head)
+	assert.Equal(t, []string{change.String()}, proposals[0].Resources)
+
+	t.Run("amends the templated branch", func(t *testing.T) {
+		amended, err := fss.Update(ctx, "main", "feat: update `baz` resource", write(`{"spec":{"value":"amended"}}`),
+			api.WithResources(change.String()),
+			api.WithChanges(change),
+			api.WithTemplates(templates),
+			api.WithProposal(result.ID))
+		require.NoError(t, err)
+
+		head, err := origin.Reference(plumbing.NewBranchReferenceName(branch), true)
+		require.NoError(t, err)
+		assert.Equal(t, amended.Commit, head.Hash().String())
+	})
+
+	t.Run("rejects branches without the proposal ID", func(t *testing.T) {
+		_, err := fss.Update(ctx, "main", "feat: add `baz` resource", write(`{"spec":{"value":"other"}}`),
+			api.WithChanges(change),
+			api.WithTemplates(core.Templates{Branch: "feature/{{ .Resource.Name }}"}))
+		require.ErrorContains(t, err, "must contain the proposal ID")
+	})
+
+	t.Run("rejects invalid branch names", func(t *testing.T) {
+		for _, branch := range []string{
+			"feature/{{ .ID }}.lock",
+			"feature/{{ .Resource.Name }} {{ .ID }}",
+			"feature//{{ .ID }}",
+			"feature/{{ .ID }}..",
+		} {
+			_, err := fss.Update(ctx, "main", "feat: add `baz` resource", write(`{"spec":{"value":"other"}}`),
+				api.WithChanges(change),
+				api.WithTemplates(core.Templates{Branch: branch}))
+			require.ErrorContains(t, err, "invalid branch name", branch)
+		}
+	})
+
+	t.Run("branches without the default prefix", func(t *testing.T) {
+		// sources tracking specific branches do not fetch templated branches by default
+		scm := memscm.New()
+		fss, err := git.NewSource(ctx, scm, remote.path, git.WithBranches("main"))
+		require.NoError(t, err)
+
+		templates := core.Templates{Branch: "flags/{{ .Resource.Name | slug }}-{{ .ID }}"}
+
+		result, err := fss.Update(ctx, "main", "feat: add `baz` resource", write(`{"spec":{"value":"flags"}}`),
+			api.WithChanges(change),
+			api.WithTemplates(templates))
+		require.NoError(t, err)
+
+		branch := fmt.Sprintf("flags/baz-%s", result.ID)
+
+		proposed, ok := scm.Proposed(result.ID)
+		require.True(t, ok)
+		assert.Equal(t, branch, proposed.Head)
+
+		amended, err := fss.Update(ctx, "main", "feat: update `baz` resource", write(`{"spec":{"value":"amended flags"}}`),
+			api.WithChanges(change),
+			api.WithTemplates(templates),
+			api.WithProposal(result.ID))
+		require.NoError(t, err)
+
+		head, err := origin.Reference(plumbing.NewBranchReferenceName(branch), true)
+		require.NoError(t, err)
+		assert.Equal(t, amended.Commit, head.Hash().String())
+
+		commit, err := origin.CommitObject(head.Hash())
+		require.NoError(t, err)
+		require.Len(t, commit.ParentHashes, 1)
+
+		// the amendment is made on top of the templated branch
+		parent, err := origin.CommitObject(commit.ParentHashes[0])
+		require.NoError(t, err)
+		assert.Contains(t, parent.Message, "Cup-Proposal: "+result.ID.String())
+	})
+}
+
+func Test_Source_Subscribe(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	fss, remote, skipped := testLocalSource(t, ctx, nil)
+	if skipped {
+		return
+	}
+
+	ch := make(chan struct{}, 1)
+	fss.Subscribe(ctx, "main", ch)
+
+	remote.commit(t, map[string]string{bazPath: string(bazContents)})
+
+	select {
+	case <-ch:
+	case <-time.After(30 * time.Second):
+		t.Fatal("timed out waiting for subscription to be notified")
+	}
+
+	files := map[string][]byte{}
+	require.NoError(t, fss.View(ctx, "main", func(f fs.FS) error {
+		data, err := fs.ReadFile(f, bazPath)
+		files[bazPath] = data
+		return err
+	}))
+
+	assert.Equal(t, map[string][]byte{bazPath: bazContents}, files)
+}
+
+func Test_Source_Refresh(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	// disable polling so that changes are only observed on refresh
+	fss, remote, skipped := testLocalSource(t, ctx, nil, git.WithPollInterval(0))
+	if skipped {
+		return
+	}
+
+	ch := make(chan struct{}, 1)
+	fss.Subscribe(ctx, "main", ch)
+
+	remote.commit(t, map[string]string{bazPath: string(bazContents)})
+
+	require.NoError(t, fss.Refresh(ctx, "main"))
+
+	select {
+	case <-ch:
+	default:
+		t.Fatal("expected subscription to be notified by refresh")
+	}
+
+	files := map[string][]byte{}
+	require.NoError(t, fss.View(ctx, "main", func(f fs.FS) error {
+		data, err := fs.ReadFile(f, bazPath)
+		files[bazPath] = data
+		return err
+	}))
+
+	assert.Equal(t, map[string][]byte{bazPath: bazContents}, files)
+
+	// refreshing when nothing has changed is not an error
+	require.NoError(t, fss.Refresh(ctx))
+
+	t.Run("deleted branch", func(t *testing.T) {
+		origin, err := gogit.PlainOpen(remote.path)
+		require.NoError(t, err)
+
+		main, err := origin.Reference(plumbing.Main, true)
+		require.NoError(t, err)
+
+		feature := plumbing.NewBranchReferenceName("feature")
+		require.NoError(t, origin.Storer.SetReference(plumbing.NewHashReference(feature, main.Hash())))
+
+		require.NoError(t, fss.Refresh(ctx, "feature"))
+		require.NoError(t, fss.View(ctx, "feature", func(fs.FS) error { return nil }))
+
+		require.NoError(t, origin.Storer.RemoveReference(feature))
+
+		// refreshing a branch which has been deleted prunes it rather than failing
+		require.NoError(t, fss.Refresh(ctx, "feature", "main"))
+
+		err = fss.View(ctx, "feature", func(fs.FS) error { return nil })
+		assert.ErrorIs(t, err, api.ErrRevisionNotFound)
+	})
 }
 
-func testSource(t *testing.T, ctx context.Context, opts ...containers.Option[git.Source]) (*git.Source, scm, bool) {
+func Test_Source_CacheDir(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	cache := t.TempDir()
+
+	_, remote, skipped := testLocalSource(t, ctx, nil, git.WithCacheDir(cache), git.WithPollInterval(0))
+	if skipped {
+		return
+	}
+
+	_, err := os.Stat(filepath.Join(cache, "objects"))
+	require.NoError(t, err, "expected repository to be stored in cache directory")
+
+	remote.commit(t, map[string]string{bazPath: string(bazContents)})
+
+	// a new source reuses the cached repository and fetches the latest changes
+	fss, err := git.NewSource(ctx, nil, remote.path, git.WithCacheDir(cache), git.WithPollInterval(0))
+	require.NoError(t, err)
+
+	files := map[string][]byte{}
+	require.NoError(t, fss.View(ctx, "main", func(f fs.FS) error {
+		data, err := fs.ReadFile(f, bazPath)
+		files[bazPath] = data
+		return err
+	}))
+
+	assert.Equal(t, map[string][]byte{bazPath: bazContents}, files)
+
+	t.Run("different repository", func(t *testing.T) {
+		_, err := git.NewSource(ctx, nil, filepath.Join(t.TempDir(), "other.git"), git.WithCacheDir(cache), git.WithPollInterval(0))
+		require.ErrorContains(t, err, "cached repository tracks")
+	})
+
+	t.Run("pushed proposal branches", func(t *testing.T) {
+		cache := t.TempDir()
+
+		fss, _, skipped := testLocalSource(t, ctx, memscm.New(), git.WithCacheDir(cache), git.WithPollInterval(0))
+		if skipped {
+			return
+		}
+
+		result, err := fss.Update(ctx, "main", "feat: update `baz` resource", func(f controllers.FSConfig) error {
+			fi, err := f.ToFS().Create(bazPath)
+			if err != nil {
+				return err
+			}
+
+			defer fi.Close()
+
+			_, err = fi.Write(bazContents)
+			return err
+		})
+		require.NoError(t, err)
+
+		// the proposal branch is only tracked through origin once pushed
+		cached, err := gogit.PlainOpen(cache)
+		require.NoError(t, err)
+
+		_, err = cached.Reference(plumbing.NewBranchReferenceName(git.ProposalBranch(result.ID)), false)
+		require.ErrorIs(t, err, plumbing.ErrReferenceNotFound)
+
+		cfg, err := cached.Config()
+		require.NoError(t, err)
+		assert.NotContains(t, cfg.Branches, git.ProposalBranch(result.ID))
+	})
+}
+
+func Test_Source_Branches(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	scm := memscm.New()
+	fss, remote, skipped := testLocalSource(t, ctx, scm, git.WithBranches("main"), git.WithPollInterval(0))
+	if skipped {
+		return
+	}
+
+	// push a branch which is not tracked by the source
+	head, err := remote.repo.Head()
+	require.NoError(t, err)
+
+	other := plumbing.NewBranchReferenceName("other")
+	require.NoError(t, remote.repo.Storer.SetReference(plumbing.NewHashReference(other, head.Hash())))
+	require.NoError(t, remote.repo.Push(&gogit.PushOptions{
+		RemoteName: "origin",
+		RefSpecs:   []config.RefSpec{config.RefSpec(other + ":" + other)},
+	}))
+
+	require.NoError(t, fss.Refresh(ctx))
+	require.NoError(t, fss.Refresh(ctx, "other"))
+
+	err = fss.View(ctx, "other", func(fs.FS) error { return nil })
+	require.Error(t, err, "untracked branch should not be fetched")
+
+	// proposal branches are tracked
+	result, err := fss.Update(ctx, "main", "feat: add `baz` resource", func(f controllers.FSConfig) error {
+		fi, err := f.ToFS().Create(bazPath)
+		if err != nil {
+			return err
+		}
+
+		defer fi.Close()
+
+		_, err = fi.Write(bazContents)
+		return err
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, fss.Refresh(ctx, git.ProposalBranch(result.ID)))
+
+	require.NoError(t, fss.View(ctx, git.ProposalBranch(result.ID), func(f fs.FS) error {
+		_, err := fs.ReadFile(f, bazPath)
+		return err
+	}))
+}
+
+func Test_Source_View_Revision(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	fss, remote, skipped := testLocalSource(t, ctx, nil)
+	if skipped {
+		return
+	}
+
+	hash := remote.commit(t, map[string]string{bazPath: string(bazContents)})
+
+	_, err := remote.repo.CreateTag("v1.0.0", hash, &gogit.CreateTagOptions{
+		Tagger:  &object.Signature{Name: "test", Email: "test@flipt.io", When: time.Now()},
+		Message: "v1.0.0",
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, remote.repo.Push(&gogit.PushOptions{
+		RemoteName: "origin",
+		RefSpecs:   []config.RefSpec{"refs/tags/*:refs/tags/*"},
+	}))
+
+	// move main on so that it no longer includes baz
+	remote.commit(t, map[string]string{bazPath: ""})
+
+	// wait until both the tag and the latest commit on main have been fetched
+	require.Eventually(t, func() bool {
+		return fss.View(ctx, "v1.0.0", func(f fs.FS) error {
+			_, err := fs.Stat(f, bazPath)
+			return err
+		}) == nil && fss.View(ctx, "main", func(f fs.FS) error {
+			data, err := fs.ReadFile(f, bazPath)
+			if err == nil && len(data) > 0 {
+				return errors.New("main not yet updated")
+			}
+			return err
+		}) == nil
+	}, 30*time.Second, 100*time.Millisecond)
+
+	for _, rev := range []string{"v1.0.0", hash.String(), hash.String()[:7]} {
+		t.Run(rev, func(t *testing.T) {
+			require.NoError(t, fss.View(ctx, rev, func(f fs.FS) error {
+				data, err := fs.ReadFile(f, bazPath)
+				require.NoError(t, err)
+				assert.Equal(t, bazContents, data)
+				return nil
+			}))
+		})
+	}
+
+	t.Run("unknown revision", func(t *testing.T) {
+		for _, rev := range []string{"unknown", plumbing.ComputeHash(plumbing.CommitObject, []byte("unknown")).String()} {
+			err := fss.View(ctx, rev, func(fs.FS) error { return nil })
+			assert.ErrorIs(t, err, api.ErrRevisionNotFound, rev)
+		}
+	})
+}
+
+func testSource(t *testing.T, ctx context.Context, opts ...containers.Option[git.Source]) (*git.Source, git.SCM, bool) {
 	t.Helper()
 
 	if gitRepoURL == "" {
@@ -176,6 +1106,105 @@ func testSource(t *testing.T, ctx context.Context, opts ...containers.Option[git
 	return fs, scm, false
 }
 
+// localRemote is a bare repository on the local filesystem
+// which can be used as the origin for a Source.
+type localRemote struct {
+	path string
+	repo *gogit.Repository
+}
+
+// commit writes the provided files into the remote's working copy,
+// then commits and pushes them to the main branch.
+// The working copy is first reset to main on the remote, as it may have been moved by a source.
+func (r *localRemote) commit(t *testing.T, files map[string]string) plumbing.Hash {
+	t.Helper()
+
+	work, err := r.repo.Worktree()
+	require.NoError(t, err)
+
+	// the remote is empty until it is seeded by the first commit
+	if err := r.repo.Fetch(&gogit.FetchOptions{RemoteName: "origin"}); err != nil &&
+		!errors.Is(err, gogit.NoErrAlreadyUpToDate) && !errors.Is(err, transport.ErrEmptyRemoteRepository) {
+		require.NoError(t, err)
+	}
+
+	if main, err := r.repo.Reference(plumbing.NewRemoteReferenceName("origin", "main"), true); err == nil {
+		require.NoError(t, work.Reset(&gogit.ResetOptions{Commit: main.Hash(), Mode: gogit.HardReset}))
+	}
+
+	for path, contents := range files {
+		fi, err := work.Filesystem.Create(path)
+		require.NoError(t, err)
+
+		_, err = fi.Write([]byte(contents))
+		require.NoError(t, err)
+		require.NoError(t, fi.Close())
+	}
+
+	require.NoError(t, work.AddWithOptions(&gogit.AddOptions{All: true}))
+
+	hash, err := work.Commit("test: update resources", &gogit.CommitOptions{
+		Author: &object.Signature{Name: "test", Email: "test@flipt.io", When: time.Now()},
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, r.repo.Push(&gogit.PushOptions{RemoteName: "origin"}))
+
+	return hash
+}
+
+// testLocalSource configures a Source over a bare repository on the local filesystem.
+// The repository is seeded with the contents of testdata on a branch named main.
+// Pushing and fetching over the file transport requires the git binaries
+// so the test is skipped if they cannot be located.
+func testLocalSource(t *testing.T, ctx context.Context, scm git.SCM, opts ...containers.Option[git.Source]) (*git.Source, *localRemote, bool) {
+	t.Helper()
+
+	if _, err := exec.LookPath("git-upload-pack"); err != nil {
+		t.Skip("git binaries required to run local source tests")
+		return nil, nil, true
+	}
+
+	var (
+		dir    = t.TempDir()
+		bare   = filepath.Join(dir, "origin.git")
+		remote = &localRemote{path: bare}
+	)
+
+	head := plumbing.NewSymbolicReference(plumbing.HEAD, plumbing.Main)
+
+	origin, err := gogit.PlainInit(bare, true)
+	require.NoError(t, err)
+	require.NoError(t, origin.Storer.SetReference(head))
+
+	remote.repo, err = gogit.PlainInit(filepath.Join(dir, "work"), false)
+	require.NoError(t, err)
+	require.NoError(t, remote.repo.Storer.SetReference(head))
+
+	_, err = remote.repo.CreateRemote(&config.RemoteConfig{
+		Name: "origin",
+		URLs: []string{bare},
+	})
+	require.NoError(t, err)
+
+	seed := map[string]string{}
+	for path, contents := range testdataContents {
+		seed[path] = string(contents)
+	}
+
+	remote.commit(t, seed)
+
+	fs, err := git.NewSource(ctx, scm, bare,
+		append([]containers.Option[git.Source]{
+			git.WithPollInterval(100 * time.Millisecond),
+		},
+			opts...)...,
+	)
+	require.NoError(t, err)
+
+	return fs, remote, false
+}
+
 func testdata(extra ...[2]string) map[string][]byte {
 	res := map[string][]byte{}
 	for k, v := range testdataContents {
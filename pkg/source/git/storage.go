@@ -0,0 +1,408 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/cache"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/go-git/go-git/v5/storage"
+	"github.com/go-git/go-git/v5/storage/filesystem"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+// open initializes the repository for the source.
+// When a cache directory is configured, the repository is stored there
+// and any existing clone is reused and incrementally fetched.
+// Otherwise, the repository is cloned into memory.
+func (s *Source) open(ctx context.Context) (err error) {
+	s.storage = &syncStorage{Storer: memory.NewStorage()}
+	if s.cacheDir != "" {
+		s.storage = filesystem.NewStorage(osfs.New(s.cacheDir), cache.NewObjectLRUDefault())
+	}
+
+	s.repo, err = git.Open(s.storage, nil)
+	if errors.Is(err, git.ErrRepositoryNotExists) {
+		opts := &git.CloneOptions{
+			Auth:  s.auth,
+			URL:   s.url,
+			Tags:  git.AllTags,
+			Depth: s.depth,
+		}
+
+		// only the first tracked branch can be cloned
+		// the remaining tracked refs are fetched once the remote is configured
+		if len(s.branches) > 0 {
+			opts.SingleBranch = true
+			opts.ReferenceName = plumbing.NewBranchReferenceName(s.branches[0])
+		}
+
+		s.repo, err = git.CloneContext(ctx, s.storage, nil, opts)
+		if err != nil {
+			return err
+		}
+
+		if len(s.branches) == 0 {
+			return nil
+		}
+	} else if err != nil {
+		return fmt.Errorf("opening cached repository: %w", err)
+	} else {
+		s.logger.Debug("Reusing cached repository", "path", s.cacheDir)
+	}
+
+	if err := s.configureRemote(); err != nil {
+		return err
+	}
+
+	if err := s.fetch(ctx); err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return fmt.Errorf("fetching changes: %w", err)
+	}
+
+	return nil
+}
+
+// configureRemote updates the URL and fetched refs of origin to match the source configuration.
+// It fails when origin has been cached for a different repository.
+func (s *Source) configureRemote() error {
+	cfg, err := s.repo.Config()
+	if err != nil {
+		return err
+	}
+
+	remote, ok := cfg.Remotes["origin"]
+	if !ok {
+		return errors.New("cached repository has no origin remote")
+	}
+
+	if len(remote.URLs) > 0 && stripUserInfo(remote.URLs[0]) != stripUserInfo(s.url) {
+		return fmt.Errorf("cached repository tracks %q not %q", stripUserInfo(remote.URLs[0]), stripUserInfo(s.url))
+	}
+
+	remote.URLs = []string{s.url}
+	remote.Fetch = s.refSpecs()
+
+	return s.repo.SetConfig(cfg)
+}
+
+// refSpecs returns the refs fetched from origin.
+// These are all the branches unless the source is configured to track specific branches.
+// In which case, only those branches and the proposal branches are fetched.
+func (s *Source) refSpecs() []config.RefSpec {
+	if len(s.branches) == 0 {
+		return []config.RefSpec{config.RefSpec(fmt.Sprintf(config.DefaultFetchRefSpec, "origin"))}
+	}
+
+	specs := make([]config.RefSpec, 0, len(s.branches)+1)
+	for _, branch := range append(slices.Clone(s.branches), proposalBranchPrefix+"*") {
+		specs = append(specs, branchSpec(branch))
+	}
+
+	return specs
+}
+
+// tracks returns true when the provided branch is fetched from origin by the source.
+// Proposal branches are always tracked, including those which are not matched by the refspecs
+// because they are named by a branch template without the default prefix.
+func (s *Source) tracks(branch string) bool {
+	if _, ok := ProposalID(branch); ok {
+		return true
+	}
+
+	return s.matches(branch)
+}
+
+// matches returns true when the provided branch is matched by the refspecs of origin.
+func (s *Source) matches(branch string) bool {
+	if len(s.branches) == 0 || strings.HasPrefix(branch, proposalBranchPrefix) {
+		return true
+	}
+
+	return slices.Contains(s.branches, branch)
+}
+
+// branchSpec returns the refspec which fetches the provided branch from origin.
+func branchSpec(branch string) config.RefSpec {
+	return config.RefSpec(fmt.Sprintf("+refs/heads/%[1]s:refs/remotes/origin/%[1]s", branch))
+}
+
+// stripUserInfo removes any credentials from the provided repository URL.
+// The username of SSH URLs is retained, as it is not a secret and is required to connect.
+func stripUserInfo(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.User == nil {
+		return raw
+	}
+
+	if u.Scheme == "ssh" {
+		u.User = url.User(u.User.Username())
+	} else {
+		u.User = nil
+	}
+
+	return u.String()
+}
+
+// urlAuth returns basic auth using any credentials embedded in the provided HTTP(S) repository URL.
+// These are used in place of the URL, which is stored without them, when no auth method is configured.
+func urlAuth(raw string) transport.AuthMethod {
+	u, err := url.Parse(raw)
+	if err != nil || u.User == nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil
+	}
+
+	password, _ := u.User.Password()
+
+	return &githttp.BasicAuth{Username: u.User.Username(), Password: password}
+}
+
+// syncStorage guards a storage which is not safe for concurrent use (such as memory.Storage),
+// so that references and objects can be read while they are written by fetches, pushes and commits.
+type syncStorage struct {
+	storage.Storer
+	mu sync.RWMutex
+}
+
+func (s *syncStorage) SetEncodedObject(obj plumbing.EncodedObject) (plumbing.Hash, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.Storer.SetEncodedObject(obj)
+}
+
+func (s *syncStorage) EncodedObject(t plumbing.ObjectType, h plumbing.Hash) (plumbing.EncodedObject, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.Storer.EncodedObject(t, h)
+}
+
+func (s *syncStorage) IterEncodedObjects(t plumbing.ObjectType) (storer.EncodedObjectIter, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.Storer.IterEncodedObjects(t)
+}
+
+func (s *syncStorage) HasEncodedObject(h plumbing.Hash) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.Storer.HasEncodedObject(h)
+}
+
+func (s *syncStorage) EncodedObjectSize(h plumbing.Hash) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.Storer.EncodedObjectSize(h)
+}
+
+func (s *syncStorage) SetReference(ref *plumbing.Reference) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.Storer.SetReference(ref)
+}
+
+func (s *syncStorage) CheckAndSetReference(ref, old *plumbing.Reference) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.Storer.CheckAndSetReference(ref, old)
+}
+
+func (s *syncStorage) Reference(name plumbing.ReferenceName) (*plumbing.Reference, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.Storer.Reference(name)
+}
+
+func (s *syncStorage) IterReferences() (storer.ReferenceIter, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.Storer.IterReferences()
+}
+
+func (s *syncStorage) RemoveReference(name plumbing.ReferenceName) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.Storer.RemoveReference(name)
+}
+
+func (s *syncStorage) CountLooseRefs() (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.Storer.CountLooseRefs()
+}
+
+func (s *syncStorage) PackRefs() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.Storer.PackRefs()
+}
+
+func (s *syncStorage) SetShallow(commits []plumbing.Hash) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.Storer.SetShallow(commits)
+}
+
+func (s *syncStorage) Shallow() ([]plumbing.Hash, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.Storer.Shallow()
+}
+
+func (s *syncStorage) SetIndex(idx *index.Index) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.Storer.SetIndex(idx)
+}
+
+func (s *syncStorage) Index() (*index.Index, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.Storer.Index()
+}
+
+func (s *syncStorage) SetConfig(cfg *config.Config) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.Storer.SetConfig(cfg)
+}
+
+func (s *syncStorage) Config() (*config.Config, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.Storer.Config()
+}
+
+func (s *syncStorage) Module(name string) (storage.Storer, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.Storer.Module(name)
+}
+
+// worktreeStorage shares the references, objects and configuration of the source repository
+// while keeping a separate index, so that concurrent updates do not interfere with one another.
+type worktreeStorage struct {
+	storage.Storer
+	index memory.IndexStorage
+}
+
+func (w *worktreeStorage) SetIndex(idx *index.Index) error {
+	return w.index.SetIndex(idx)
+}
+
+func (w *worktreeStorage) Index() (*index.Index, error) {
+	return w.index.Index()
+}
+
+// overlayStorage shares the objects of the source repository while keeping the references,
+// configuration and index written by an update separate from it.
+// Local sources commit over an overlay so that the branches and HEAD of the repository
+// on disk are only moved once an update has been committed successfully.
+type overlayStorage struct {
+	worktreeStorage
+	refs   memory.ReferenceStorage
+	config memory.ConfigStorage
+}
+
+// newOverlayStorage returns an overlay over the current references and configuration of s.
+func newOverlayStorage(s storage.Storer) (*overlayStorage, error) {
+	o := &overlayStorage{
+		worktreeStorage: worktreeStorage{Storer: s},
+		refs:            memory.ReferenceStorage{},
+	}
+
+	cfg, err := s.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := o.config.SetConfig(cfg); err != nil {
+		return nil, err
+	}
+
+	refs, err := s.IterReferences()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := refs.ForEach(func(ref *plumbing.Reference) error {
+		o.refs[ref.Name()] = ref
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	head, err := s.Reference(plumbing.HEAD)
+	if err != nil {
+		return nil, err
+	}
+
+	o.refs[plumbing.HEAD] = head
+
+	return o, nil
+}
+
+func (o *overlayStorage) SetReference(ref *plumbing.Reference) error {
+	return o.refs.SetReference(ref)
+}
+
+func (o *overlayStorage) CheckAndSetReference(ref, old *plumbing.Reference) error {
+	return o.refs.CheckAndSetReference(ref, old)
+}
+
+func (o *overlayStorage) Reference(name plumbing.ReferenceName) (*plumbing.Reference, error) {
+	return o.refs.Reference(name)
+}
+
+func (o *overlayStorage) IterReferences() (storer.ReferenceIter, error) {
+	return o.refs.IterReferences()
+}
+
+func (o *overlayStorage) RemoveReference(name plumbing.ReferenceName) error {
+	return o.refs.RemoveReference(name)
+}
+
+func (o *overlayStorage) CountLooseRefs() (int, error) {
+	return o.refs.CountLooseRefs()
+}
+
+func (o *overlayStorage) PackRefs() error {
+	return o.refs.PackRefs()
+}
+
+func (o *overlayStorage) SetConfig(cfg *config.Config) error {
+	return o.config.SetConfig(cfg)
+}
+
+func (o *overlayStorage) Config() (*config.Config, error) {
+	return o.config.Config()
+}
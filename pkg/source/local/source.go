@@ -2,10 +2,13 @@ package local
 
 import (
 	"context"
+	"errors"
+	"fmt"
 
 	"github.com/go-git/go-billy/v5/osfs"
 	"go.flipt.io/cup/pkg/api"
 	"go.flipt.io/cup/pkg/billyfs"
+	"go.flipt.io/cup/pkg/containers"
 	"go.flipt.io/cup/pkg/controllers"
 )
 
@@ -31,7 +34,18 @@ func (f *Source) View(_ context.Context, revision string, fn api.ViewFunc) error
 // Update invokes the provided function with an FSConfig which can be written to
 // Any writes performed to the target during the execution of fn will be added,
 // comitted, pushed and proposed for review on a target SCM.
-func (f *Source) Update(_ context.Context, revision string, message string, fn api.UpdateFunc) (*api.Result, error) {
+// Dry-run updates are not supported.
+func (f *Source) Update(ctx context.Context, revision string, message string, fn api.UpdateFunc, opts ...containers.Option[api.UpdateOptions]) (*api.Result, error) {
+	var options api.UpdateOptions
+	containers.ApplyAll(&options, opts...)
+	if options.DryRun {
+		return nil, fmt.Errorf("update: dry run: %w", errors.ErrUnsupported)
+	}
+
+	if err := api.CheckPreconditions(ctx, f, revision, options.Preconditions); err != nil {
+		return nil, fmt.Errorf("update: %w", err)
+	}
+
 	return &api.Result{}, fn(controllers.FSConfig{
 		FS:  osfs.New(f.path),
 		Dir: &f.path,
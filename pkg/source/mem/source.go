@@ -2,7 +2,9 @@ package mem
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sync"
 
 	"github.com/go-git/go-billy/v5"
 	"go.flipt.io/cup/pkg/api"
@@ -11,32 +13,50 @@ import (
 	"go.flipt.io/cup/pkg/controllers"
 )
 
-var _ api.Source = (*Source)(nil)
+var _ api.WatchableSource = (*Source)(nil)
 
 // Source is primarily used for testing.
 // The implementations are indexed by revision internally.
 // It supports writes through the billyfs abstraction.
 // However, instead of proposals, these are direct writes to the underlying filesystem.
 type Source struct {
+	mu   sync.RWMutex
 	revs containers.MapStore[string, billy.Filesystem]
+	subs map[string]map[chan<- struct{}]struct{}
 }
 
 // New constructs a new instance of a Source
 func New() *Source {
-	return &Source{revs: containers.MapStore[string, billy.Filesystem]{}}
+	return &Source{
+		revs: containers.MapStore[string, billy.Filesystem]{},
+		subs: map[string]map[chan<- struct{}]struct{}{},
+	}
 }
 
-// AddFS registers a new fs.FS to be supplied on calls to View and Update
+// AddFS registers a new fs.FS to be supplied on calls to View and Update.
+// Any subscribers to the revision are notified.
 func (f *Source) AddFS(revision string, ffs billy.Filesystem) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
 	f.revs[revision] = ffs
+
+	for ch := range f.subs[revision] {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
 }
 
 // View invokes the provided function with an FSConfig which should enforce
 // a read-only view for the requested source and revision
 func (f *Source) View(_ context.Context, revision string, fn api.ViewFunc) error {
+	f.mu.RLock()
 	fs, err := f.revs.Get(revision)
+	f.mu.RUnlock()
 	if err != nil {
-		return fmt.Errorf("view: %w", err)
+		return fmt.Errorf("view: %w: %w", api.ErrRevisionNotFound, err)
 	}
 
 	return fn(billyfs.New(fs))
@@ -45,11 +65,48 @@ func (f *Source) View(_ context.Context, revision string, fn api.ViewFunc) error
 // Update invokes the provided function with an FSConfig which can be written to
 // Any writes performed to the target during the execution of fn will be added,
 // comitted, pushed and proposed for review on a target SCM
-func (f *Source) Update(_ context.Context, revision, _ string, fn api.UpdateFunc) (*api.Result, error) {
+// Dry-run updates are not supported.
+func (f *Source) Update(ctx context.Context, revision, _ string, fn api.UpdateFunc, opts ...containers.Option[api.UpdateOptions]) (*api.Result, error) {
+	var options api.UpdateOptions
+	containers.ApplyAll(&options, opts...)
+	if options.DryRun {
+		return nil, fmt.Errorf("update: dry run: %w", errors.ErrUnsupported)
+	}
+
+	if err := api.CheckPreconditions(ctx, f, revision, options.Preconditions); err != nil {
+		return nil, fmt.Errorf("update: %w", err)
+	}
+
+	f.mu.RLock()
 	fs, err := f.revs.Get(revision)
+	f.mu.RUnlock()
 	if err != nil {
-		return nil, fmt.Errorf("update: %w", err)
+		return nil, fmt.Errorf("update: %w: %w", api.ErrRevisionNotFound, err)
 	}
 
 	return &api.Result{}, fn(controllers.NewFSConfig(fs))
 }
+
+// Subscribe registers ch to be signalled whenever AddFS is called for the provided revision.
+// The subscription is removed once the provided context is done.
+func (f *Source) Subscribe(ctx context.Context, revision string, ch chan<- struct{}) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	subs, ok := f.subs[revision]
+	if !ok {
+		subs = map[chan<- struct{}]struct{}{}
+		f.subs[revision] = subs
+	}
+
+	subs[ch] = struct{}{}
+
+	go func() {
+		<-ctx.Done()
+
+		f.mu.Lock()
+		defer f.mu.Unlock()
+
+		delete(f.subs[revision], ch)
+	}()
+}
@@ -2,11 +2,13 @@ package sdk
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
 
 	"go.flipt.io/cup/pkg/encoding"
+	"go.flipt.io/cup/pkg/labels"
 )
 
 var ErrNotFound = errors.New("not found")
@@ -70,6 +72,16 @@ func (c Kind[T]) Run(ctx context.Context, args ...string) error {
 	case "get":
 		return c.runtime.Get(ctx, args[3], args[4], enc)
 	case "list":
+		// list <kind> <namespace> [<selector>]
+		if len(args) > 4 {
+			selector, err := labels.Parse(args[4])
+			if err != nil {
+				return err
+			}
+
+			return c.runtime.List(ctx, args[3], selectorEncoder[T]{enc, selector})
+		}
+
 		return c.runtime.List(ctx, args[3], enc)
 	case "put":
 		t, err := encoding.NewJSONDecoder[T](os.Stdin).Decode()
@@ -95,3 +107,34 @@ type KindController[T any] interface {
 	Put(ctx context.Context, namespace, name string, t *T) error
 	Delete(ctx context.Context, namespace, name string) error
 }
+
+// selectorEncoder wraps a TypedEncoder and only encodes the values
+// whose metadata labels match the configured selector.
+type selectorEncoder[T any] struct {
+	encoding.TypedEncoder[T]
+
+	selector labels.Selector
+}
+
+func (e selectorEncoder[T]) Encode(t *T) error {
+	data, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+
+	var resource struct {
+		Metadata struct {
+			Labels map[string]string `json:"labels"`
+		} `json:"metadata"`
+	}
+
+	if err := json.Unmarshal(data, &resource); err != nil {
+		return err
+	}
+
+	if !e.selector.Matches(resource.Metadata.Labels) {
+		return nil
+	}
+
+	return e.TypedEncoder.Encode(t)
+}